@@ -0,0 +1,408 @@
+package freezer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+var ErrFileNotInArtifact = errors.New("file not found in artifact")
+
+type ArchiveEntry struct {
+	Name string
+	Size int64
+}
+
+type ArchiveFormat string
+
+const (
+	ArchiveFormatGzip ArchiveFormat = "gzip"
+	ArchiveFormatTar  ArchiveFormat = "tar"
+)
+
+type BuildpackDependency struct {
+	ID      string `toml:"id"`
+	Version string `toml:"version"`
+	URI     string `toml:"uri"`
+	SHA256  string `toml:"sha256"`
+}
+
+// ReadFileFromArtifact streams the artifact at uri and returns the contents
+// of the single file at pathInArchive without extracting anything else.
+func ReadFileFromArtifact(uri, pathInArchive string) ([]byte, error) {
+	file, err := os.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tr, err := newArtifactReader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	target := cleanArchivePath(pathInArchive)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg || cleanArchivePath(hdr.Name) != target {
+			continue
+		}
+
+		return io.ReadAll(tr)
+	}
+
+	return nil, fmt.Errorf("%s: %w: %s", pathInArchive, ErrFileNotInArtifact, uri)
+}
+
+// ReadBuildpackDependencies returns the [[metadata.dependencies]] declared
+// in the buildpack.toml of the artifact at uri.
+func ReadBuildpackDependencies(uri string) ([]BuildpackDependency, error) {
+	content, err := ReadFileFromArtifact(uri, "buildpack.toml")
+	if err != nil {
+		return nil, err
+	}
+
+	var config struct {
+		Metadata struct {
+			Dependencies []BuildpackDependency `toml:"dependencies"`
+		} `toml:"metadata"`
+	}
+
+	_, err = toml.Decode(string(content), &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode buildpack.toml in %s: %w", uri, err)
+	}
+
+	return config.Metadata.Dependencies, nil
+}
+
+// ArtifactsEqual reports whether the artifacts at uriA and uriB are byte for
+// byte identical.
+func ArtifactsEqual(uriA, uriB string) (bool, error) {
+	sumA, err := fileSHA256(uriA)
+	if err != nil {
+		return false, err
+	}
+
+	sumB, err := fileSHA256(uriB)
+	if err != nil {
+		return false, err
+	}
+
+	return sumA == sumB, nil
+}
+
+// ArtifactContentsEqual reports whether the artifacts at uriA and uriB hold
+// the same entries with the same contents and permissions. Unlike
+// ArtifactsEqual it ignores timestamps, ownership, entry order and
+// compression, so a buildpack repackaged from the same source compares
+// equal.
+func ArtifactContentsEqual(uriA, uriB string) (bool, error) {
+	equal, err := ArtifactsEqual(uriA, uriB)
+	if err != nil || equal {
+		return equal, err
+	}
+
+	entriesA, err := normalizedEntries(uriA)
+	if err != nil {
+		return false, err
+	}
+
+	entriesB, err := normalizedEntries(uriB)
+	if err != nil {
+		return false, err
+	}
+
+	if len(entriesA) != len(entriesB) {
+		return false, nil
+	}
+
+	for name, entry := range entriesA {
+		if entriesB[name] != entry {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+type normalizedEntry struct {
+	typeflag byte
+	mode     int64
+	linkname string
+	sha256   [sha256.Size]byte
+}
+
+func normalizedEntries(uri string) (map[string]normalizedEntry, error) {
+	file, err := os.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tr, err := newArtifactReader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]normalizedEntry{}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", uri, err)
+		}
+
+		name := cleanArchivePath(hdr.Name)
+		if name == "" {
+			continue
+		}
+
+		entry := normalizedEntry{
+			typeflag: hdr.Typeflag,
+			mode:     hdr.Mode & 07777,
+			linkname: hdr.Linkname,
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			hash := sha256.New()
+			_, err = io.Copy(hash, tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", uri, err)
+			}
+			copy(entry.sha256[:], hash.Sum(nil))
+		}
+
+		entries[name] = entry
+	}
+
+	return entries, nil
+}
+
+// containsBuildpackTOML reports whether the artifact at uri has a
+// buildpack.toml at its root, or at the root of its only top-level
+// directory.
+func containsBuildpackTOML(uri string) (bool, error) {
+	file, err := os.Open(uri)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	tr, err := newArtifactReader(file)
+	if err != nil {
+		return false, err
+	}
+
+	topLevel := map[string]bool{}
+	nested := false
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return false, err
+		}
+
+		name := cleanArchivePath(hdr.Name)
+		if name == "" {
+			continue
+		}
+
+		if name == "buildpack.toml" && hdr.Typeflag == tar.TypeReg {
+			return true, nil
+		}
+
+		segments := strings.SplitN(name, "/", 2)
+		topLevel[segments[0]] = true
+
+		if len(segments) == 2 && segments[1] == "buildpack.toml" && hdr.Typeflag == tar.TypeReg {
+			nested = true
+		}
+	}
+
+	return nested && len(topLevel) == 1, nil
+}
+
+// readBuildpackTOML returns the buildpack.toml at the root of the archive in
+// r, or at the root of one of its top-level directories, reading no further
+// than that entry.
+func readBuildpackTOML(r io.Reader) ([]byte, error) {
+	tr, err := newArtifactReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		segments := strings.Split(cleanArchivePath(hdr.Name), "/")
+		if len(segments) <= 2 && segments[len(segments)-1] == "buildpack.toml" {
+			return io.ReadAll(tr)
+		}
+	}
+
+	return nil, errors.New("archive does not contain a buildpack.toml")
+}
+
+// newArtifactReader returns a tar reader over r, transparently
+// decompressing it when it is gzipped.
+func newArtifactReader(r io.Reader) (*tar.Reader, error) {
+	r, err := gunzipIfCompressed(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return tar.NewReader(r), nil
+}
+
+// gunzipIfCompressed sniffs the gzip magic number so that callers can read
+// both compressed and uncompressed streams.
+func gunzipIfCompressed(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+
+	magic, err := buffered.Peek(2)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(buffered)
+	}
+
+	return buffered, nil
+}
+
+// checkArchiveFormat sniffs the start of r and returns a reader over the
+// full content when it is one of the accepted formats.
+func checkArchiveFormat(r io.Reader, accepted []ArchiveFormat) (io.Reader, error) {
+	buffered := bufio.NewReaderSize(r, 512)
+
+	header, err := buffered.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+
+	format := detectArchiveFormat(header)
+	for _, a := range accepted {
+		if format == a {
+			return buffered, nil
+		}
+	}
+
+	if format == "" {
+		trimmed := bytes.TrimSpace(header)
+		if bytes.HasPrefix(trimmed, []byte("<")) {
+			return nil, errors.New("downloaded content is HTML, not an archive")
+		}
+
+		return nil, errors.New("downloaded content is not a recognized archive")
+	}
+
+	return nil, fmt.Errorf("downloaded content is a %s archive, which is not accepted", format)
+}
+
+func detectArchiveFormat(header []byte) ArchiveFormat {
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return ArchiveFormatGzip
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return ArchiveFormatTar
+	}
+
+	return ""
+}
+
+// archiveLister lists the regular files of an archive while it is being
+// read by someone else, so that the archive is only read once.
+type archiveLister struct {
+	writer  *io.PipeWriter
+	done    chan struct{}
+	once    sync.Once
+	entries []ArchiveEntry
+	err     error
+}
+
+// newArchiveLister returns a reader over r that also feeds everything read
+// through it to the lister.
+func newArchiveLister(r io.Reader) (io.Reader, *archiveLister) {
+	pr, pw := io.Pipe()
+	lister := &archiveLister{
+		writer: pw,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(lister.done)
+		// The reader side must keep draining so that writes never block
+		defer io.Copy(io.Discard, pr)
+
+		tr, err := newArtifactReader(pr)
+		if err != nil {
+			lister.err = err
+			return
+		}
+
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					lister.err = err
+				}
+				return
+			}
+
+			if hdr.Typeflag == tar.TypeReg {
+				lister.entries = append(lister.entries, ArchiveEntry{Name: cleanArchivePath(hdr.Name), Size: hdr.Size})
+			}
+		}
+	}()
+
+	return io.TeeReader(r, pw), lister
+}
+
+// Finish waits for the listing to complete. It is safe to call more than
+// once.
+func (l *archiveLister) Finish() ([]ArchiveEntry, error) {
+	l.once.Do(func() {
+		l.writer.Close()
+		<-l.done
+	})
+
+	return l.entries, l.err
+}
+
+func cleanArchivePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,283 @@
+package freezer_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testArtifact(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		artifactDir string
+		uri         string
+	)
+
+	it.Before(func() {
+		var err error
+		artifactDir, err = os.MkdirTemp("", "artifact")
+		Expect(err).NotTo(HaveOccurred())
+
+		uri = filepath.Join(artifactDir, "some-tag.tgz")
+
+		file, err := os.Create(uri)
+		Expect(err).NotTo(HaveOccurred())
+
+		gw := gzip.NewWriter(file)
+		tw := tar.NewWriter(gw)
+
+		Expect(tw.WriteHeader(&tar.Header{Name: "./bin", Mode: 0755, Typeflag: tar.TypeDir})).To(Succeed())
+
+		for name, content := range map[string]string{
+			"./buildpack.toml": `api = "0.7"`,
+			"./bin/build":      "some-build-content",
+		} {
+			Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})).To(Succeed())
+			_, err = tw.Write([]byte(content))
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(tw.Close()).To(Succeed())
+		Expect(gw.Close()).To(Succeed())
+		Expect(file.Close()).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(artifactDir)).To(Succeed())
+	})
+
+	context("ReadFileFromArtifact", func() {
+		it("returns the contents of a single file in the artifact", func() {
+			content, err := freezer.ReadFileFromArtifact(uri, "buildpack.toml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal(`api = "0.7"`))
+
+			content, err = freezer.ReadFileFromArtifact(uri, "./bin/build")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("some-build-content"))
+		})
+
+		context("failure cases", func() {
+			context("when the file is not in the artifact", func() {
+				it("returns an error", func() {
+					_, err := freezer.ReadFileFromArtifact(uri, "package.toml")
+					Expect(errors.Is(err, freezer.ErrFileNotInArtifact)).To(BeTrue())
+					Expect(err).To(MatchError(ContainSubstring("package.toml")))
+				})
+			})
+
+			context("when the artifact does not exist", func() {
+				it("returns an error", func() {
+					_, err := freezer.ReadFileFromArtifact(filepath.Join(artifactDir, "missing.tgz"), "buildpack.toml")
+					Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+				})
+			})
+
+			context("when the artifact is not an archive", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(uri, []byte("some-garbage-content"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := freezer.ReadFileFromArtifact(uri, "buildpack.toml")
+					Expect(err).To(HaveOccurred())
+				})
+			})
+		})
+	})
+
+	context("ReadBuildpackDependencies", func() {
+		var writeArtifact = func(buildpackTOML string) {
+			file, err := os.Create(uri)
+			Expect(err).NotTo(HaveOccurred())
+
+			gw := gzip.NewWriter(file)
+			tw := tar.NewWriter(gw)
+
+			Expect(tw.WriteHeader(&tar.Header{Name: "./buildpack.toml", Mode: 0644, Size: int64(len(buildpackTOML)), Typeflag: tar.TypeReg})).To(Succeed())
+			_, err = tw.Write([]byte(buildpackTOML))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(tw.Close()).To(Succeed())
+			Expect(gw.Close()).To(Succeed())
+			Expect(file.Close()).To(Succeed())
+		}
+
+		it.Before(func() {
+			writeArtifact(`
+api = "0.7"
+
+[buildpack]
+  id = "some-org/some-buildpack"
+
+[[metadata.dependencies]]
+  id = "some-dependency"
+  version = "1.2.3"
+  uri = "https://example.com/some-dependency-1.2.3.tgz"
+  sha256 = "some-sha256"
+  stacks = ["some-stack"]
+
+[[metadata.dependencies]]
+  id = "other-dependency"
+  version = "4.5.6"
+  uri = "https://example.com/other-dependency-4.5.6.tgz"
+  sha256 = "other-sha256"
+`)
+		})
+
+		it("returns the dependencies declared in buildpack.toml", func() {
+			dependencies, err := freezer.ReadBuildpackDependencies(uri)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dependencies).To(Equal([]freezer.BuildpackDependency{
+				{
+					ID:      "some-dependency",
+					Version: "1.2.3",
+					URI:     "https://example.com/some-dependency-1.2.3.tgz",
+					SHA256:  "some-sha256",
+				},
+				{
+					ID:      "other-dependency",
+					Version: "4.5.6",
+					URI:     "https://example.com/other-dependency-4.5.6.tgz",
+					SHA256:  "other-sha256",
+				},
+			}))
+		})
+
+		context("when the buildpack declares no dependencies", func() {
+			it.Before(func() {
+				writeArtifact(`api = "0.7"`)
+			})
+
+			it("returns an empty list", func() {
+				dependencies, err := freezer.ReadBuildpackDependencies(uri)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dependencies).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when buildpack.toml cannot be decoded", func() {
+				it.Before(func() {
+					writeArtifact(`%%%`)
+				})
+
+				it("returns an error", func() {
+					_, err := freezer.ReadBuildpackDependencies(uri)
+					Expect(err).To(MatchError(ContainSubstring("failed to decode buildpack.toml")))
+				})
+			})
+
+			context("when the artifact does not exist", func() {
+				it("returns an error", func() {
+					_, err := freezer.ReadBuildpackDependencies(filepath.Join(artifactDir, "missing.tgz"))
+					Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+				})
+			})
+		})
+	})
+
+	context("ArtifactsEqual", func() {
+		var writeArtifact func(name string, modTime time.Time, compress bool, files map[string]string) string
+
+		it.Before(func() {
+			writeArtifact = func(name string, modTime time.Time, compress bool, files map[string]string) string {
+				path := filepath.Join(artifactDir, name)
+
+				file, err := os.Create(path)
+				Expect(err).NotTo(HaveOccurred())
+				defer file.Close()
+
+				var w io.Writer = file
+				if compress {
+					gw := gzip.NewWriter(file)
+					defer gw.Close()
+					w = gw
+				}
+
+				tw := tar.NewWriter(w)
+				defer tw.Close()
+
+				names := make([]string, 0, len(files))
+				for n := range files {
+					names = append(names, n)
+				}
+				sort.Strings(names)
+
+				for _, n := range names {
+					Expect(tw.WriteHeader(&tar.Header{Name: n, Mode: 0644, Size: int64(len(files[n])), ModTime: modTime, Typeflag: tar.TypeReg})).To(Succeed())
+					_, err = tw.Write([]byte(files[n]))
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				return path
+			}
+		})
+
+		it("compares artifacts by digest", func() {
+			copyURI := filepath.Join(artifactDir, "copy.tgz")
+			content, err := os.ReadFile(uri)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(copyURI, content, 0644)).To(Succeed())
+
+			equal, err := freezer.ArtifactsEqual(uri, copyURI)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(equal).To(BeTrue())
+
+			other := writeArtifact("other.tgz", time.Unix(0, 0), true, map[string]string{"buildpack.toml": `api = "0.7"`})
+
+			equal, err = freezer.ArtifactsEqual(uri, other)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(equal).To(BeFalse())
+		})
+
+		context("ArtifactContentsEqual", func() {
+			it("ignores timestamps and compression", func() {
+				files := map[string]string{"buildpack.toml": `api = "0.7"`, "bin/build": "some-build-content"}
+				a := writeArtifact("a.tgz", time.Unix(0, 0), true, files)
+				b := writeArtifact("b.tar", time.Unix(1000000, 0), false, files)
+
+				equal, err := freezer.ArtifactsEqual(a, b)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(equal).To(BeFalse())
+
+				equal, err = freezer.ArtifactContentsEqual(a, b)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(equal).To(BeTrue())
+			})
+
+			it("detects differing contents", func() {
+				a := writeArtifact("a.tgz", time.Unix(0, 0), true, map[string]string{"buildpack.toml": `api = "0.7"`})
+				b := writeArtifact("b.tgz", time.Unix(0, 0), true, map[string]string{"buildpack.toml": `api = "0.8"`})
+				c := writeArtifact("c.tgz", time.Unix(0, 0), true, map[string]string{"buildpack.toml": `api = "0.7"`, "bin/build": "some-build-content"})
+
+				equal, err := freezer.ArtifactContentsEqual(a, b)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(equal).To(BeFalse())
+
+				equal, err = freezer.ArtifactContentsEqual(a, c)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(equal).To(BeFalse())
+			})
+
+			context("when an artifact does not exist", func() {
+				it("returns an error", func() {
+					_, err := freezer.ArtifactContentsEqual(uri, filepath.Join(artifactDir, "missing.tgz"))
+					Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package freezer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errLocked = errors.New("lock is held by another process")
+
+// lockFile takes an exclusive flock on file without blocking.
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLocked
+	}
+
+	return err
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package freezer
+
+import (
+	"errors"
+	"os"
+)
+
+var errLocked = errors.New("lock is held by another process")
+
+// lockFile is not implemented on Windows, where CacheManager.WithLock makes
+// Open fail.
+func lockFile(file *os.File) error {
+	return errors.New("cache locking is not supported on windows")
+}
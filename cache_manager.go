@@ -1,38 +1,211 @@
 package freezer
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+type IndexFormat string
+
+const (
+	IndexFormatGob  IndexFormat = "gob"
+	IndexFormatJSON IndexFormat = "json"
+	IndexFormatTOML IndexFormat = "toml"
 )
 
 type CacheManager struct {
 	Cache CacheDB
 
-	cacheDir string
-	dbFile   *os.File
+	cacheDir    string
+	dbFile      *os.File
+	gracePeriod time.Duration
+	clock       Clock
+	retired     map[string]time.Time
+	requireDir  bool
+	dirPerm     os.FileMode
+	compress    bool
+	keepPrev    bool
+	previous    CacheDB
+	keepHistory bool
+	history     map[string][]CacheEntry
+	format      IndexFormat
+	roFallback  bool
+	readOnly    bool
+	lock        bool
+	lockTimeout time.Duration
+	lockFile    *os.File
 }
 
 type CacheDB map[string]CacheEntry
 
+// textIndex is the layout of JSON and TOML indexes. Format is written first
+// so that Open can tell which decoder to use.
+type textIndex struct {
+	Format  IndexFormat `json:"format" toml:"format"`
+	Entries CacheDB     `json:"entries" toml:"entries"`
+}
+
 type CacheEntry struct {
-	Version string
-	URI     string
+	// Key is the key the entry is stored under. It is only set on the
+	// entries returned by List.
+	Key string `json:"-" toml:"-"`
+
+	Version   string
+	URI       string
+	FetchedAt time.Time
+
+	// PublishedAt is when the upstream release was published, as opposed to
+	// FetchedAt, which is when it was cached.
+	PublishedAt time.Time
+
+	// LastUsedAt is when RecordUse last reported the entry as used. Prune
+	// evicts the least recently used entries first.
+	LastUsedAt time.Time
+
+	// SHA256 is the hex encoded digest of the artifact at URI when it was
+	// fetched.
+	SHA256 string
+
+	// PublishedSHA256 is the digest published alongside the release asset,
+	// in a .sha256 asset or the release notes, that the artifact was
+	// verified against when it was fetched. It is empty when the release
+	// published none.
+	PublishedSHA256 string
+
+	// Reference is the OCI image reference the artifact was published to, if
+	// any.
+	Reference string
+
+	Labels []string
 }
 
 func NewCacheManager(cacheDir string) CacheManager {
 	return CacheManager{
 		cacheDir: cacheDir,
+		clock:    NewSystemClock(),
 	}
 }
 
+// WithGracePeriod keeps the artifact of a replaced entry on disk for the
+// given duration so that readers still holding its path are not broken.
+// Deletion happens on a later Open, Set or Close once the period is over.
+func (c CacheManager) WithGracePeriod(gracePeriod time.Duration) CacheManager {
+	c.gracePeriod = gracePeriod
+	return c
+}
+
+// WithCreateDir controls whether Open creates a missing cache directory,
+// which is the default, or returns an error instead.
+func (c CacheManager) WithCreateDir(create bool) CacheManager {
+	c.requireDir = !create
+	return c
+}
+
+// WithDirPermissions sets the permissions used when Open creates the cache
+// directory. It defaults to os.ModePerm.
+func (c CacheManager) WithDirPermissions(perm os.FileMode) CacheManager {
+	c.dirPerm = perm
+	return c
+}
+
+// WithCompressedIndex makes Close write buildpacks-cache.db gzip compressed.
+// Open reads compressed and uncompressed databases regardless.
+func (c CacheManager) WithCompressedIndex(compress bool) CacheManager {
+	c.compress = compress
+	return c
+}
+
+// WithKeepPrevious makes Set keep the entry and artifact it replaces so
+// that Rollback can restore them. Only the immediately previous entry of a
+// key is kept.
+func (c CacheManager) WithKeepPrevious(keep bool) CacheManager {
+	c.keepPrev = keep
+	return c
+}
+
+// WithHistory makes Set keep every entry and artifact it replaces instead
+// of removing them, so that several versions of a buildpack coexist and
+// Checkout can make any of them current again. It takes precedence over
+// WithKeepPrevious and WithGracePeriod.
+func (c CacheManager) WithHistory(keep bool) CacheManager {
+	c.keepHistory = keep
+	return c
+}
+
+// WithIndexFormat sets the format Close writes buildpacks-cache.db in. It
+// defaults to IndexFormatGob; the text formats are easier to inspect by
+// hand. Open detects the format of an existing database on its own.
+func (c CacheManager) WithIndexFormat(format IndexFormat) CacheManager {
+	c.format = format
+	return c
+}
+
+// WithReadOnlyFallback lets Open load a cache that cannot be written to,
+// such as one baked into an image, instead of failing. Entries can then be
+// read but Set returns an error and Close writes nothing.
+func (c CacheManager) WithReadOnlyFallback(fallback bool) CacheManager {
+	c.roFallback = fallback
+	return c
+}
+
+// WithLock makes Open take an exclusive advisory lock on the cache directory
+// and hold it until Close, so that processes sharing the directory take
+// turns reading and writing buildpacks-cache.db instead of overwriting each
+// other's entries. Open gives up with an error when the lock is not free
+// within timeout.
+func (c CacheManager) WithLock(timeout time.Duration) CacheManager {
+	c.lock = true
+	c.lockTimeout = timeout
+	return c
+}
+
+func (c CacheManager) WithClock(clock Clock) CacheManager {
+	c.clock = clock
+	return c
+}
+
 func (c *CacheManager) Open() error {
+	if !c.lock {
+		return c.open()
+	}
+
+	err := c.ensureDir()
+	if err != nil {
+		return err
+	}
+
+	err = c.acquireLock()
+	if err != nil {
+		return err
+	}
+
+	err = c.open()
+	if err != nil {
+		c.releaseLock()
+		return err
+	}
+
+	return nil
+}
+
+func (c *CacheManager) open() error {
 	var err error
 	_, err = os.Stat(filepath.Join(c.cacheDir, "buildpacks-cache.db"))
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			err = os.MkdirAll(c.cacheDir, os.ModePerm)
+			err = c.ensureDir()
 			if err != nil {
 				return err
 			}
@@ -41,7 +214,7 @@ func (c *CacheManager) Open() error {
 				return err
 			}
 			c.Cache = CacheDB{}
-			return nil
+			return c.openSidecars()
 		}
 		return err
 	}
@@ -51,27 +224,67 @@ func (c *CacheManager) Open() error {
 		return err
 	}
 
-	err = gob.NewDecoder(loadFile).Decode(&c.Cache)
+	index, err := gunzipIfCompressed(loadFile)
 	if err != nil {
 		return err
 	}
 
-	c.dbFile, err = os.OpenFile(filepath.Join(c.cacheDir, "buildpacks-cache.db"), os.O_WRONLY|os.O_TRUNC, 0666)
+	c.Cache, err = decodeIndex(index)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	c.dbFile, err = os.OpenFile(filepath.Join(c.cacheDir, "buildpacks-cache.db"), os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		if !c.roFallback || !errors.Is(err, os.ErrPermission) {
+			return err
+		}
+		c.readOnly = true
+	}
+
+	return c.openSidecars()
 }
 
 func (c CacheManager) Close() error {
-	err := gob.NewEncoder(c.dbFile).Encode(&c.Cache)
+	defer c.releaseLock()
+
+	if c.readOnly {
+		return nil
+	}
+
+	err := c.writeIndex()
 	if err != nil {
 		return err
 	}
 	defer c.dbFile.Close()
 
-	return nil
+	err = c.sweep()
+	if err != nil {
+		return err
+	}
+
+	err = c.writePrevious()
+	if err != nil {
+		return err
+	}
+
+	err = c.writeHistory()
+	if err != nil {
+		return err
+	}
+
+	retiredPath := filepath.Join(c.cacheDir, "buildpacks-cache-retired.db")
+	if len(c.retired) == 0 {
+		return os.RemoveAll(retiredPath)
+	}
+
+	retiredFile, err := os.Create(retiredPath)
+	if err != nil {
+		return err
+	}
+	defer retiredFile.Close()
+
+	return gob.NewEncoder(retiredFile).Encode(c.retired)
 }
 
 //This function exists for two reasons  one is so that is could have a standard
@@ -93,23 +306,718 @@ func (c CacheManager) Get(key string) (CacheEntry, bool, error) {
 	return entry, ok, nil
 }
 
+// RecordUse notes that the entry of key was used just now, so that Prune
+// evicts it after the entries that were used less recently. RemoteFetcher
+// calls it on every cache hit. Like Set, it must not run concurrently with
+// other calls on the CacheManager.
+func (c *CacheManager) RecordUse(key string) {
+	entry, ok := c.Cache[key]
+	if !ok || c.clock == nil {
+		return
+	}
+
+	entry.LastUsedAt = c.clock.Now()
+	c.Cache[key] = entry
+}
+
 func (c *CacheManager) Set(key string, value CacheEntry) error {
-	//os.RemoveAll of a empty string is a noop if the entry does not exist then it will
-	//return and empty string
-	err := os.RemoveAll(c.Cache[key].URI)
-	if err != nil {
-		return err
+	if c.readOnly {
+		return fmt.Errorf("cannot set %s: the cache is read-only", key)
 	}
 
 	if c.Cache == nil {
 		return errors.New("the cache manager is not loaded properly")
 	}
 
+	current, ok := c.Cache[key]
+
+	// Labels describe the key rather than a particular artifact, so they
+	// survive a refetch
+	if value.Labels == nil {
+		value.Labels = current.Labels
+	}
+
 	c.Cache[key] = value
+	delete(c.retired, value.URI)
+
+	//os.RemoveAll of a empty string is a noop if the entry does not exist then it will
+	//return and empty string, a refetch to the same path must not remove the new file
+	if current.URI != value.URI {
+		return c.replace(key, current, ok, value)
+	}
 
 	return nil
 }
 
+// Rollback restores the entry that the last Set of key replaced and
+// discards the current one. It requires WithKeepPrevious.
+func (c *CacheManager) Rollback(key string) error {
+	previous, ok := c.previous[key]
+	if !ok {
+		return fmt.Errorf("no previous entry for %s", key)
+	}
+
+	current := c.Cache[key]
+	c.Cache[key] = previous
+	delete(c.previous, key)
+	delete(c.retired, previous.URI)
+
+	if current.URI != previous.URI {
+		return c.retire(current.URI)
+	}
+
+	return nil
+}
+
+// History returns the entries Set replaced for key while WithHistory was
+// enabled, oldest first. Their artifacts are still on disk.
+func (c CacheManager) History(key string) []CacheEntry {
+	return append([]CacheEntry(nil), c.history[key]...)
+}
+
+// Checkout makes the kept entry of key with the given version current again
+// and keeps the current one in its place.
+func (c *CacheManager) Checkout(key, version string) error {
+	for i, entry := range c.history[key] {
+		if entry.Version != version {
+			continue
+		}
+
+		c.history[key] = append(c.history[key][:i:i], c.history[key][i+1:]...)
+		if current, ok := c.Cache[key]; ok {
+			c.history[key] = append(c.history[key], current)
+		}
+		c.Cache[key] = entry
+		delete(c.retired, entry.URI)
+
+		return nil
+	}
+
+	return fmt.Errorf("no kept entry of %s has version %s", key, version)
+}
+
+// Prune removes the entries fetched longer than maxAge ago and then, least
+// recently used first, as many entries as it takes for the artifacts of the
+// rest to fit in maxBytes. The previous and kept entries of WithKeepPrevious
+// and WithHistory are removed the same way, and retired artifacts still in
+// their grace period count towards maxBytes and are deleted first. A zero
+// limit is not enforced. The current entries of the keys in keep are never
+// removed, and an artifact is only deleted once no entry refers to it. Prune
+// returns the number of bytes reclaimed.
+func (c *CacheManager) Prune(maxBytes int64, maxAge time.Duration, keep ...string) (int64, error) {
+	if c.readOnly {
+		return 0, errors.New("cannot prune: the cache is read-only")
+	}
+
+	kept := map[string]bool{}
+	for _, key := range keep {
+		kept[key] = true
+	}
+
+	var candidates []pruneCandidate
+	for key, entry := range c.Cache {
+		if !kept[key] {
+			candidates = append(candidates, pruneCandidate{key: key, kind: pruneCurrent, entry: entry})
+		}
+	}
+	for key, entry := range c.previous {
+		candidates = append(candidates, pruneCandidate{key: key, kind: prunePrevious, entry: entry})
+	}
+	for key, entries := range c.history {
+		for _, entry := range entries {
+			candidates = append(candidates, pruneCandidate{key: key, kind: pruneHistory, entry: entry})
+		}
+	}
+
+	var (
+		sizes = map[string]int64{}
+		total int64
+	)
+	measure := func(uri string) error {
+		if _, seen := sizes[uri]; seen {
+			return nil
+		}
+
+		info, err := os.Stat(uri)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if err == nil {
+			sizes[uri] = info.Size()
+			total += info.Size()
+		} else {
+			sizes[uri] = 0
+		}
+
+		return nil
+	}
+
+	for _, entry := range c.Cache {
+		err := measure(entry.URI)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, candidate := range candidates {
+		err := measure(candidate.entry.URI)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var retired []string
+	for uri := range c.retired {
+		if _, seen := sizes[uri]; seen {
+			continue
+		}
+
+		err := measure(uri)
+		if err != nil {
+			return 0, err
+		}
+		retired = append(retired, uri)
+	}
+	sort.Strings(retired)
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if !a.lastUsed().Equal(b.lastUsed()) {
+			return a.lastUsed().Before(b.lastUsed())
+		}
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.kind < b.kind
+	})
+
+	var reclaimed int64
+	removed := make([]bool, len(candidates))
+	remove := func(i int) error {
+		removed[i] = true
+
+		candidate := candidates[i]
+		switch candidate.kind {
+		case pruneCurrent:
+			delete(c.Cache, candidate.key)
+		case prunePrevious:
+			delete(c.previous, candidate.key)
+		case pruneHistory:
+			var rest []CacheEntry
+			for _, entry := range c.history[candidate.key] {
+				if entry.URI != candidate.entry.URI {
+					rest = append(rest, entry)
+				}
+			}
+
+			if len(rest) == 0 {
+				delete(c.history, candidate.key)
+			} else {
+				c.history[candidate.key] = rest
+			}
+		}
+
+		uri := candidate.entry.URI
+		if c.referenced(uri) {
+			return nil
+		}
+
+		total -= sizes[uri]
+		reclaimed += sizes[uri]
+		return c.retire(uri)
+	}
+
+	now := c.clock.Now()
+	for i, candidate := range candidates {
+		if maxAge <= 0 || now.Sub(candidate.entry.FetchedAt) <= maxAge {
+			continue
+		}
+
+		err := remove(i)
+		if err != nil {
+			return reclaimed, err
+		}
+	}
+
+	for _, uri := range retired {
+		if maxBytes <= 0 || total <= maxBytes {
+			break
+		}
+
+		err := os.RemoveAll(uri)
+		if err != nil {
+			return reclaimed, err
+		}
+		delete(c.retired, uri)
+
+		total -= sizes[uri]
+		reclaimed += sizes[uri]
+	}
+
+	for i := range candidates {
+		if maxBytes <= 0 || total <= maxBytes {
+			break
+		}
+
+		if removed[i] {
+			continue
+		}
+
+		err := remove(i)
+		if err != nil {
+			return reclaimed, err
+		}
+	}
+
+	return reclaimed, nil
+}
+
+type pruneKind int
+
+const (
+	pruneCurrent pruneKind = iota
+	prunePrevious
+	pruneHistory
+)
+
+// pruneCandidate is an entry that Prune may remove: the current, previous
+// or a kept entry of key.
+type pruneCandidate struct {
+	key   string
+	kind  pruneKind
+	entry CacheEntry
+}
+
+// lastUsed falls back to FetchedAt for entries that were never used.
+func (p pruneCandidate) lastUsed() time.Time {
+	if p.entry.LastUsedAt.After(p.entry.FetchedAt) {
+		return p.entry.LastUsedAt
+	}
+
+	return p.entry.FetchedAt
+}
+
+// Label attaches labels to an existing entry. Labels are saved with the
+// rest of the entry on Close.
+func (c *CacheManager) Label(key string, labels ...string) error {
+	entry, ok := c.Cache[key]
+	if !ok {
+		return fmt.Errorf("no cache entry for %s", key)
+	}
+
+	entry.Labels = addLabels(entry.Labels, labels)
+	c.Cache[key] = entry
+
+	return nil
+}
+
+// List returns every entry in the cache, with its Key set, sorted by key.
+func (c CacheManager) List() ([]CacheEntry, error) {
+	if c.Cache == nil {
+		return nil, errors.New("the cache manager is not loaded properly")
+	}
+
+	var entries []CacheEntry
+	for key, entry := range c.Cache {
+		entry.Key = key
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries, nil
+}
+
+// ListByLabel returns the sorted keys of all entries carrying label.
+func (c CacheManager) ListByLabel(label string) []string {
+	return keysWithLabel(c.Cache, label)
+}
+
+func addLabels(existing, labels []string) []string {
+	for _, label := range labels {
+		if !hasLabel(existing, label) {
+			existing = append(existing, label)
+		}
+	}
+
+	return existing
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+
+	return false
+}
+
+func keysWithLabel(db CacheDB, label string) []string {
+	var keys []string
+	for key, entry := range db {
+		if hasLabel(entry.Labels, label) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (c CacheManager) writeIndex() error {
+	if !c.compress {
+		return encodeIndex(c.dbFile, c.format, c.Cache)
+	}
+
+	gw := gzip.NewWriter(c.dbFile)
+	err := encodeIndex(gw, c.format, c.Cache)
+	if err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func encodeIndex(w io.Writer, format IndexFormat, db CacheDB) error {
+	switch format {
+	case "", IndexFormatGob:
+		return gob.NewEncoder(w).Encode(&db)
+	case IndexFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(textIndex{Format: format, Entries: db})
+	case IndexFormatTOML:
+		return toml.NewEncoder(w).Encode(textIndex{Format: format, Entries: db})
+	}
+
+	return fmt.Errorf("unknown index format %q", format)
+}
+
+// decodeIndex reads a database written in any IndexFormat. Text formats
+// start with their format marker, anything else is taken to be gob.
+func decodeIndex(r io.Reader) (CacheDB, error) {
+	buffered := bufio.NewReader(r)
+
+	header, err := buffered.Peek(16)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var index textIndex
+	switch {
+	case bytes.HasPrefix(header, []byte("{")):
+		err = json.NewDecoder(buffered).Decode(&index)
+	case bytes.HasPrefix(header, []byte("format = ")):
+		_, err = toml.NewDecoder(buffered).Decode(&index)
+	default:
+		var db CacheDB
+		err = gob.NewDecoder(buffered).Decode(&db)
+		return db, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if index.Entries == nil {
+		index.Entries = CacheDB{}
+	}
+
+	return index.Entries, nil
+}
+
+// lockPollInterval is how often acquireLock retries a held lock.
+const lockPollInterval = 50 * time.Millisecond
+
+// acquireLock takes the lock on buildpacks-cache.lock, retrying until the
+// lock timeout is over.
+func (c *CacheManager) acquireLock() error {
+	path := filepath.Join(c.cacheDir, "buildpacks-cache.lock")
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open cache lock: %w", err)
+	}
+
+	deadline := time.Now().Add(c.lockTimeout)
+	for {
+		err = lockFile(file)
+		if err == nil {
+			c.lockFile = file
+			return nil
+		}
+
+		if !errors.Is(err, errLocked) {
+			file.Close()
+			return fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+
+		if !time.Now().Before(deadline) {
+			file.Close()
+			return fmt.Errorf("timed out after %s waiting for the lock on %s, another process is using the cache", c.lockTimeout, path)
+		}
+
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// releaseLock gives up the lock taken by acquireLock, if any. Closing the
+// file releases it.
+func (c CacheManager) releaseLock() {
+	if c.lockFile != nil {
+		c.lockFile.Close()
+	}
+}
+
 func (c CacheManager) Dir() string {
 	return c.cacheDir
 }
+
+func (c CacheManager) ensureDir() error {
+	if !c.requireDir {
+		perm := c.dirPerm
+		if perm == 0 {
+			perm = os.ModePerm
+		}
+
+		return os.MkdirAll(c.cacheDir, perm)
+	}
+
+	info, err := os.Stat(c.cacheDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("cache directory %s does not exist: %w", c.cacheDir, err)
+		}
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("cache directory %s is not a directory", c.cacheDir)
+	}
+
+	return nil
+}
+
+// replace disposes of the artifact of the entry being replaced by value,
+// or keeps it as the previous entry for Rollback.
+func (c *CacheManager) replace(key string, current CacheEntry, ok bool, value CacheEntry) error {
+
+	if c.keepHistory {
+		var kept []CacheEntry
+		for _, entry := range c.history[key] {
+			if entry.URI != value.URI && entry.URI != current.URI {
+				kept = append(kept, entry)
+			}
+		}
+
+		if ok {
+			kept = append(kept, current)
+		}
+
+		if len(kept) == 0 {
+			delete(c.history, key)
+			return nil
+		}
+
+		if c.history == nil {
+			c.history = map[string][]CacheEntry{}
+		}
+		c.history[key] = kept
+
+		return nil
+	}
+
+	if previous, kept := c.previous[key]; kept {
+		delete(c.previous, key)
+
+		if previous.URI != current.URI && previous.URI != value.URI {
+			err := c.retire(previous.URI)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !c.keepPrev || !ok {
+		return c.retire(current.URI)
+	}
+
+	if c.previous == nil {
+		c.previous = CacheDB{}
+	}
+	c.previous[key] = current
+
+	return nil
+}
+
+// retire removes the artifact at uri, or schedules its removal when a grace
+// period is set. Artifacts that another entry still refers to, such as one
+// shared through identity keys, are left alone.
+func (c *CacheManager) retire(uri string) error {
+	if uri != "" && c.referenced(uri) {
+		return nil
+	}
+
+	if c.gracePeriod <= 0 || uri == "" {
+		return os.RemoveAll(uri)
+	}
+
+	if c.retired == nil {
+		c.retired = map[string]time.Time{}
+	}
+	c.retired[uri] = c.clock.Now().Add(c.gracePeriod)
+
+	return c.sweep()
+}
+
+// sweep removes every retired artifact whose grace period has run out. An
+// artifact that an entry refers to again is no longer retired.
+func (c CacheManager) sweep() error {
+	if len(c.retired) == 0 {
+		return nil
+	}
+
+	now := c.clock.Now()
+	for uri, deadline := range c.retired {
+		if now.Before(deadline) {
+			continue
+		}
+
+		if c.referenced(uri) {
+			delete(c.retired, uri)
+			continue
+		}
+
+		err := os.RemoveAll(uri)
+		if err != nil {
+			return err
+		}
+		delete(c.retired, uri)
+	}
+
+	return nil
+}
+
+// referenced reports whether a current, previous or kept entry refers to
+// uri.
+func (c CacheManager) referenced(uri string) bool {
+	for _, entry := range c.Cache {
+		if entry.URI == uri {
+			return true
+		}
+	}
+
+	for _, entry := range c.previous {
+		if entry.URI == uri {
+			return true
+		}
+	}
+
+	for _, entries := range c.history {
+		for _, entry := range entries {
+			if entry.URI == uri {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *CacheManager) openSidecars() error {
+	err := c.openRetired()
+	if err != nil {
+		return err
+	}
+
+	err = c.openPrevious()
+	if err != nil {
+		return err
+	}
+
+	return c.openHistory()
+}
+
+func (c *CacheManager) openHistory() error {
+	c.history = map[string][]CacheEntry{}
+
+	historyFile, err := os.Open(filepath.Join(c.cacheDir, "buildpacks-cache-history.db"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer historyFile.Close()
+
+	return gob.NewDecoder(historyFile).Decode(&c.history)
+}
+
+func (c CacheManager) writeHistory() error {
+	historyPath := filepath.Join(c.cacheDir, "buildpacks-cache-history.db")
+	if len(c.history) == 0 {
+		return os.RemoveAll(historyPath)
+	}
+
+	historyFile, err := os.Create(historyPath)
+	if err != nil {
+		return err
+	}
+	defer historyFile.Close()
+
+	return gob.NewEncoder(historyFile).Encode(c.history)
+}
+
+func (c *CacheManager) openPrevious() error {
+	c.previous = CacheDB{}
+
+	previousFile, err := os.Open(filepath.Join(c.cacheDir, "buildpacks-cache-previous.db"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer previousFile.Close()
+
+	return gob.NewDecoder(previousFile).Decode(&c.previous)
+}
+
+func (c CacheManager) writePrevious() error {
+	previousPath := filepath.Join(c.cacheDir, "buildpacks-cache-previous.db")
+	if len(c.previous) == 0 {
+		return os.RemoveAll(previousPath)
+	}
+
+	previousFile, err := os.Create(previousPath)
+	if err != nil {
+		return err
+	}
+	defer previousFile.Close()
+
+	return gob.NewEncoder(previousFile).Encode(c.previous)
+}
+
+func (c *CacheManager) openRetired() error {
+	c.retired = map[string]time.Time{}
+	if c.clock == nil {
+		c.clock = NewSystemClock()
+	}
+
+	retiredFile, err := os.Open(filepath.Join(c.cacheDir, "buildpacks-cache-retired.db"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer retiredFile.Close()
+
+	err = gob.NewDecoder(retiredFile).Decode(&c.retired)
+	if err != nil {
+		return err
+	}
+
+	return c.sweep()
+}
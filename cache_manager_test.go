@@ -2,12 +2,16 @@ package freezer_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/gob"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ForestEckhardt/freezer"
+	"github.com/ForestEckhardt/freezer/fakes"
 	"github.com/sclevine/spec"
 
 	. "github.com/onsi/gomega"
@@ -65,6 +69,107 @@ func testCacheManager(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when the cache directory does not exist", func() {
+			var missingDir string
+
+			it.Before(func() {
+				missingDir = filepath.Join(cacheDir, "some-missing-dir")
+			})
+
+			it("creates it with the configured permissions", func() {
+				cacheManager = freezer.NewCacheManager(missingDir).WithDirPermissions(0700)
+				Expect(cacheManager.Open()).To(Succeed())
+
+				info, err := os.Stat(missingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.IsDir()).To(BeTrue())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+
+				Expect(filepath.Join(missingDir, "buildpacks-cache.db")).To(BeAnExistingFile())
+			})
+
+			context("when directory creation is disabled", func() {
+				it("returns an error", func() {
+					cacheManager = freezer.NewCacheManager(missingDir).WithCreateDir(false)
+					err := cacheManager.Open()
+					Expect(err).To(MatchError(fmt.Sprintf("cache directory %s does not exist: stat %s: no such file or directory", missingDir, missingDir)))
+
+					Expect(missingDir).NotTo(BeADirectory())
+				})
+
+				it("opens a cache directory that already exists", func() {
+					cacheManager = freezer.NewCacheManager(cacheDir).WithCreateDir(false)
+					Expect(cacheManager.Open()).To(Succeed())
+					Expect(cacheManager.Cache).To(Equal(freezer.CacheDB{}))
+				})
+			})
+		})
+
+		context("when the cache is read-only and the read-only fallback is enabled", func() {
+			var uri string
+
+			it.Before(func() {
+				uri = filepath.Join(cacheDir, "some-tag.tgz")
+				Expect(os.WriteFile(uri, []byte("some-artifact"), 0644)).To(Succeed())
+
+				Expect(cacheManager.Open()).To(Succeed())
+				cacheManager.Cache = freezer.CacheDB{"some-buildpack": freezer.CacheEntry{Version: "some-tag", URI: uri}}
+				Expect(cacheManager.Close()).To(Succeed())
+
+				Expect(os.Chmod(filepath.Join(cacheDir, "buildpacks-cache.db"), 0444)).To(Succeed())
+				Expect(os.Chmod(cacheDir, 0555)).To(Succeed())
+
+				cacheManager = freezer.NewCacheManager(cacheDir).WithReadOnlyFallback(true)
+			})
+
+			it.After(func() {
+				Expect(os.Chmod(cacheDir, os.ModePerm)).To(Succeed())
+			})
+
+			it("serves the existing entries but refuses writes", func() {
+				Expect(cacheManager.Open()).To(Succeed())
+
+				entry, ok, err := cacheManager.Get("some-buildpack")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				Expect(entry.URI).To(Equal(uri))
+
+				err = cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "some-other-tag", URI: uri})
+				Expect(err).To(MatchError("cannot set some-buildpack: the cache is read-only"))
+
+				Expect(cacheManager.Close()).To(Succeed())
+			})
+
+			it("fails to open without the fallback", func() {
+				cacheManager = freezer.NewCacheManager(cacheDir)
+				Expect(cacheManager.Open()).To(MatchError(ContainSubstring("permission denied")))
+			})
+		})
+
+		context("when locking is enabled", func() {
+			var other freezer.CacheManager
+
+			it.Before(func() {
+				cacheManager = cacheManager.WithLock(100 * time.Millisecond)
+				other = freezer.NewCacheManager(cacheDir).WithLock(100 * time.Millisecond)
+			})
+
+			it("holds the lock from Open until Close", func() {
+				Expect(cacheManager.Open()).To(Succeed())
+
+				err := other.Open()
+				Expect(err).To(MatchError(fmt.Sprintf("timed out after 100ms waiting for the lock on %s, another process is using the cache", filepath.Join(cacheDir, "buildpacks-cache.lock"))))
+
+				Expect(cacheManager.Set("some-key", freezer.CacheEntry{Version: "1.2.3", URI: "some-uri"})).To(Succeed())
+				Expect(cacheManager.Close()).To(Succeed())
+
+				Expect(other.Open()).To(Succeed())
+				defer other.Close()
+
+				Expect(other.Cache).To(HaveKey("some-key"))
+			})
+		})
+
 		context("failure cases", func() {
 			context("the buildpacks-cache.db file is unable to be created", func() {
 				it.Before(func() {
@@ -125,6 +230,91 @@ func testCacheManager(t *testing.T, context spec.G, it spec.S) {
 				Expect(cacheCheck).To(Equal(cacheManager.Cache))
 			})
 		})
+
+		context("when the index is compressed", func() {
+			it.Before(func() {
+				cacheManager = freezer.NewCacheManager(cacheDir).WithCompressedIndex(true)
+				Expect(cacheManager.Open()).To(Succeed())
+				cacheManager.Cache = freezer.CacheDB{"some-buildpack": freezer.CacheEntry{Version: "1.2.3", URI: "some-uri"}}
+			})
+
+			it("writes a gzipped index that Open reads back", func() {
+				Expect(cacheManager.Close()).To(Succeed())
+
+				file, err := os.Open(filepath.Join(cacheDir, "buildpacks-cache.db"))
+				Expect(err).ToNot(HaveOccurred())
+				defer file.Close()
+
+				gr, err := gzip.NewReader(file)
+				Expect(err).ToNot(HaveOccurred())
+
+				var cacheCheck freezer.CacheDB
+				Expect(gob.NewDecoder(gr).Decode(&cacheCheck)).To(Succeed())
+				Expect(cacheCheck).To(Equal(cacheManager.Cache))
+
+				reopened := freezer.NewCacheManager(cacheDir)
+				Expect(reopened.Open()).To(Succeed())
+				Expect(reopened.Cache).To(Equal(cacheManager.Cache))
+				Expect(reopened.Close()).To(Succeed())
+			})
+		})
+		context("when an index format is configured", func() {
+			var entries freezer.CacheDB
+
+			it.Before(func() {
+				entries = freezer.CacheDB{
+					"some-org/some-buildpack": freezer.CacheEntry{
+						Version:     "1.2.3",
+						URI:         "some-uri",
+						FetchedAt:   time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC),
+						PublishedAt: time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+						SHA256:      "some-sha256",
+						Labels:      []string{"some-label"},
+					},
+				}
+			})
+
+			for _, format := range []freezer.IndexFormat{freezer.IndexFormatJSON, freezer.IndexFormatTOML} {
+				format := format
+
+				it(fmt.Sprintf("round-trips the index as %s", format), func() {
+					cacheManager = freezer.NewCacheManager(cacheDir).WithIndexFormat(format)
+					Expect(cacheManager.Open()).To(Succeed())
+					cacheManager.Cache = entries
+					Expect(cacheManager.Close()).To(Succeed())
+
+					content, err := os.ReadFile(filepath.Join(cacheDir, "buildpacks-cache.db"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(content)).To(ContainSubstring("some-org/some-buildpack"))
+					Expect(string(content)).To(ContainSubstring(string(format)))
+
+					reopened := freezer.NewCacheManager(cacheDir)
+					Expect(reopened.Open()).To(Succeed())
+					Expect(reopened.Cache).To(HaveLen(1))
+
+					entry := reopened.Cache["some-org/some-buildpack"]
+					Expect(entry.Version).To(Equal("1.2.3"))
+					Expect(entry.URI).To(Equal("some-uri"))
+					Expect(entry.FetchedAt).To(BeTemporally("==", entries["some-org/some-buildpack"].FetchedAt))
+					Expect(entry.PublishedAt).To(BeTemporally("==", entries["some-org/some-buildpack"].PublishedAt))
+					Expect(entry.SHA256).To(Equal("some-sha256"))
+					Expect(entry.Labels).To(Equal([]string{"some-label"}))
+					Expect(reopened.Close()).To(Succeed())
+				})
+			}
+
+			it("reads a compressed text index", func() {
+				cacheManager = freezer.NewCacheManager(cacheDir).WithIndexFormat(freezer.IndexFormatTOML).WithCompressedIndex(true)
+				Expect(cacheManager.Open()).To(Succeed())
+				cacheManager.Cache = entries
+				Expect(cacheManager.Close()).To(Succeed())
+
+				reopened := freezer.NewCacheManager(cacheDir)
+				Expect(reopened.Open()).To(Succeed())
+				Expect(reopened.Cache["some-org/some-buildpack"].Version).To(Equal("1.2.3"))
+				Expect(reopened.Close()).To(Succeed())
+			})
+		})
 	})
 
 	context("Get", func() {
@@ -150,6 +340,12 @@ func testCacheManager(t *testing.T, context spec.G, it spec.S) {
 					Expect(ok).To(BeTrue())
 					Expect(entry).To(Equal(freezer.CacheEntry{Version: "1.2.3", URI: uri}))
 				})
+
+				it("leaves the stored entry untouched", func() {
+					_, _, err := cacheManager.Get("some-buildpack")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(cacheManager.Cache["some-buildpack"]).To(Equal(freezer.CacheEntry{Version: "1.2.3", URI: uri}))
+				})
 			})
 
 			context("and the file in uri does not exists", func() {
@@ -215,6 +411,103 @@ func testCacheManager(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when another key shares the artifact of the replaced entry", func() {
+			it.Before(func() {
+				cacheManager.Cache["some-buildpack-id@1.2.3"] = freezer.CacheEntry{Version: "1.2.3", URI: uri}
+			})
+
+			it("keeps the artifact for the other key", func() {
+				err := cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "1.2.4", URI: "some-uri"})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(uri).To(BeAnExistingFile())
+
+				entry, ok, err := cacheManager.Get("some-buildpack-id@1.2.3")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				Expect(entry.URI).To(Equal(uri))
+			})
+
+			it("removes the artifact once the last key moves on", func() {
+				Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "1.2.4", URI: "some-uri"})).To(Succeed())
+				Expect(cacheManager.Set("some-buildpack-id@1.2.3", freezer.CacheEntry{Version: "1.2.4", URI: "some-uri"})).To(Succeed())
+
+				Expect(uri).NotTo(BeAnExistingFile())
+			})
+		})
+
+		context("when a grace period is configured", func() {
+			var (
+				clock *fakes.Clock
+				now   time.Time
+			)
+
+			it.Before(func() {
+				now = time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+				clock = &fakes.Clock{}
+				clock.NowCall.Stub = func() time.Time {
+					return now
+				}
+
+				Expect(cacheManager.Close()).To(Succeed())
+
+				cacheManager = freezer.NewCacheManager(cacheDir).WithGracePeriod(10 * time.Minute).WithClock(clock)
+				Expect(cacheManager.Open()).To(Succeed())
+				Expect(cacheManager.Cache).To(HaveKey("some-buildpack"))
+			})
+
+			it("keeps the previous file within the grace period and removes it afterwards", func() {
+				err := cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "1.2.4", URI: "some-uri"})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(uri).To(BeAnExistingFile())
+				Expect(cacheManager.Cache["some-buildpack"]).To(Equal(freezer.CacheEntry{Version: "1.2.4", URI: "some-uri"}))
+
+				now = now.Add(5 * time.Minute)
+				Expect(cacheManager.Close()).To(Succeed())
+				Expect(uri).To(BeAnExistingFile())
+
+				now = now.Add(10 * time.Minute)
+				reopened := freezer.NewCacheManager(cacheDir).WithGracePeriod(10 * time.Minute).WithClock(clock)
+				Expect(reopened.Open()).To(Succeed())
+				Expect(uri).NotTo(BeAnExistingFile())
+
+				Expect(reopened.Close()).To(Succeed())
+				Expect(filepath.Join(cacheDir, "buildpacks-cache-retired.db")).NotTo(BeAnExistingFile())
+			})
+
+			it("does not remove a retired file that is current again", func() {
+				v2 := filepath.Join(cacheDir, "some-other-file")
+				Expect(os.WriteFile(v2, []byte(`some other content`), 0644)).To(Succeed())
+
+				Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "1.2.4", URI: v2})).To(Succeed())
+				Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "1.2.3", URI: uri})).To(Succeed())
+
+				now = now.Add(15 * time.Minute)
+				Expect(cacheManager.Close()).To(Succeed())
+
+				Expect(uri).To(BeAnExistingFile())
+				Expect(v2).NotTo(BeAnExistingFile())
+
+				reopened := freezer.NewCacheManager(cacheDir).WithGracePeriod(10 * time.Minute).WithClock(clock)
+				Expect(reopened.Open()).To(Succeed())
+				Expect(reopened.Cache["some-buildpack"].URI).To(Equal(uri))
+				Expect(uri).To(BeAnExistingFile())
+				Expect(reopened.Close()).To(Succeed())
+			})
+		})
+
+		context("when the new entry points at the same file as the existing one", func() {
+			it("keeps the file and sets the new information", func() {
+				err := cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "1.2.3", URI: uri})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(uri).To(BeAnExistingFile())
+				Expect(cacheManager.Cache["some-buildpack"]).To(Equal(freezer.CacheEntry{Version: "1.2.3", URI: uri}))
+			})
+		})
+
 		context("when there is not an already existing entry", func() {
 			it("deletes the previous file and sets the new information", func() {
 				err := cacheManager.Set("some-buildpack-other", freezer.CacheEntry{Version: "1.2.4", URI: "some-uri"})
@@ -254,4 +547,326 @@ func testCacheManager(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 	})
+
+	context("Prune", func() {
+		var (
+			now   time.Time
+			clock *fakes.Clock
+		)
+
+		it.Before(func() {
+			now = time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+			clock = &fakes.Clock{}
+			clock.NowCall.Returns.Time = now
+			cacheManager = cacheManager.WithClock(clock)
+
+			Expect(cacheManager.Open()).To(Succeed())
+
+			for name, size := range map[string]int{"old": 10, "older": 20, "recent": 30, "current": 40} {
+				Expect(os.WriteFile(filepath.Join(cacheDir, name+".tgz"), make([]byte, size), 0644)).To(Succeed())
+			}
+
+			cacheManager.Cache = freezer.CacheDB{
+				"oldest-buildpack":  freezer.CacheEntry{Version: "1.0.0", URI: filepath.Join(cacheDir, "older.tgz"), FetchedAt: now.Add(-72 * time.Hour)},
+				"shared-buildpack":  freezer.CacheEntry{Version: "1.0.0", URI: filepath.Join(cacheDir, "older.tgz"), FetchedAt: now.Add(-72 * time.Hour)},
+				"old-buildpack":     freezer.CacheEntry{Version: "2.0.0", URI: filepath.Join(cacheDir, "old.tgz"), FetchedAt: now.Add(-48 * time.Hour)},
+				"recent-buildpack":  freezer.CacheEntry{Version: "3.0.0", URI: filepath.Join(cacheDir, "recent.tgz"), FetchedAt: now.Add(-2 * time.Hour)},
+				"current-buildpack": freezer.CacheEntry{Version: "4.0.0", URI: filepath.Join(cacheDir, "current.tgz"), FetchedAt: now.Add(-96 * time.Hour)},
+			}
+		})
+
+		it("removes entries older than the maximum age", func() {
+			reclaimed, err := cacheManager.Prune(0, 24*time.Hour, "current-buildpack")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaimed).To(Equal(int64(30)))
+
+			Expect(cacheManager.Cache).To(HaveLen(2))
+			Expect(cacheManager.Cache).To(HaveKey("recent-buildpack"))
+			Expect(cacheManager.Cache).To(HaveKey("current-buildpack"))
+
+			Expect(filepath.Join(cacheDir, "older.tgz")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(cacheDir, "old.tgz")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(cacheDir, "current.tgz")).To(BeAnExistingFile())
+		})
+
+		it("removes the least recently fetched entries until the rest fit", func() {
+			reclaimed, err := cacheManager.Prune(80, 0, "current-buildpack")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaimed).To(Equal(int64(20)))
+
+			Expect(cacheManager.Cache).To(HaveLen(3))
+			Expect(cacheManager.Cache).NotTo(HaveKey("oldest-buildpack"))
+			Expect(cacheManager.Cache).NotTo(HaveKey("shared-buildpack"))
+			Expect(filepath.Join(cacheDir, "older.tgz")).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(cacheDir, "old.tgz")).To(BeAnExistingFile())
+		})
+
+		it("keeps an artifact while another entry still refers to it", func() {
+			_, err := cacheManager.Prune(0, 24*time.Hour, "current-buildpack", "shared-buildpack")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cacheManager.Cache).NotTo(HaveKey("oldest-buildpack"))
+			Expect(cacheManager.Cache).To(HaveKey("shared-buildpack"))
+			Expect(filepath.Join(cacheDir, "older.tgz")).To(BeAnExistingFile())
+		})
+
+		it("removes the least recently used entries first", func() {
+			cacheManager.RecordUse("oldest-buildpack")
+			cacheManager.RecordUse("shared-buildpack")
+			Expect(cacheManager.Cache["oldest-buildpack"].LastUsedAt).To(Equal(now))
+
+			reclaimed, err := cacheManager.Prune(80, 0, "current-buildpack")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaimed).To(Equal(int64(40)))
+
+			Expect(cacheManager.Cache).To(HaveLen(3))
+			Expect(cacheManager.Cache).NotTo(HaveKey("old-buildpack"))
+			Expect(cacheManager.Cache).NotTo(HaveKey("recent-buildpack"))
+			Expect(filepath.Join(cacheDir, "older.tgz")).To(BeAnExistingFile())
+		})
+
+		context("when replaced entries are kept", func() {
+			var v1, v2, v3 string
+
+			it.Before(func() {
+				Expect(cacheManager.Close()).To(Succeed())
+
+				cacheManager = freezer.NewCacheManager(cacheDir).WithHistory(true).WithClock(clock)
+				Expect(cacheManager.Open()).To(Succeed())
+				cacheManager.Cache = freezer.CacheDB{}
+
+				for i, version := range []string{"v1", "v2", "v3"} {
+					path := filepath.Join(cacheDir, version+".tgz")
+					Expect(os.WriteFile(path, make([]byte, 10), 0644)).To(Succeed())
+					Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: version, URI: path, FetchedAt: now.Add(time.Duration(i-3) * time.Hour)})).To(Succeed())
+				}
+
+				v1 = filepath.Join(cacheDir, "v1.tgz")
+				v2 = filepath.Join(cacheDir, "v2.tgz")
+				v3 = filepath.Join(cacheDir, "v3.tgz")
+			})
+
+			it("counts and removes them, oldest first", func() {
+				reclaimed, err := cacheManager.Prune(20, 0, "some-buildpack")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reclaimed).To(Equal(int64(10)))
+
+				Expect(cacheManager.Cache["some-buildpack"].Version).To(Equal("v3"))
+				Expect(cacheManager.History("some-buildpack")).To(HaveLen(1))
+				Expect(cacheManager.History("some-buildpack")[0].Version).To(Equal("v2"))
+
+				Expect(v1).NotTo(BeAnExistingFile())
+				Expect(v2).To(BeAnExistingFile())
+				Expect(v3).To(BeAnExistingFile())
+			})
+
+			it("removes them by age", func() {
+				_, err := cacheManager.Prune(0, 90*time.Minute, "some-buildpack")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(cacheManager.History("some-buildpack")).To(BeEmpty())
+				Expect(v1).NotTo(BeAnExistingFile())
+				Expect(v2).NotTo(BeAnExistingFile())
+				Expect(v3).To(BeAnExistingFile())
+			})
+		})
+	})
+
+	context("List", func() {
+		it("returns every entry with its key in key order", func() {
+			Expect(cacheManager.Open()).To(Succeed())
+			cacheManager.Cache = freezer.CacheDB{
+				"some-org:some-repo":        freezer.CacheEntry{Version: "1.2.3", URI: "some-uri"},
+				"other-org:other-repo":      freezer.CacheEntry{Version: "4.5.6", URI: "other-uri"},
+				"some-org:some-repo:cached": freezer.CacheEntry{Version: "1.2.3", URI: "some-cached-uri"},
+			}
+
+			entries, err := cacheManager.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(Equal([]freezer.CacheEntry{
+				{Key: "other-org:other-repo", Version: "4.5.6", URI: "other-uri"},
+				{Key: "some-org:some-repo", Version: "1.2.3", URI: "some-uri"},
+				{Key: "some-org:some-repo:cached", Version: "1.2.3", URI: "some-cached-uri"},
+			}))
+		})
+
+		context("when the cache has not been opened", func() {
+			it("returns an error", func() {
+				_, err := cacheManager.List()
+				Expect(err).To(MatchError("the cache manager is not loaded properly"))
+			})
+		})
+	})
+
+	context("Label", func() {
+		it.Before(func() {
+			Expect(cacheManager.Open()).To(Succeed())
+			cacheManager.Cache = freezer.CacheDB{
+				"some-buildpack":  freezer.CacheEntry{Version: "1.2.3", URI: "some-uri"},
+				"other-buildpack": freezer.CacheEntry{Version: "4.5.6", URI: "other-uri"},
+			}
+		})
+
+		it("attaches labels that can be queried and persist across Close and Open", func() {
+			Expect(cacheManager.Label("some-buildpack", "golden", "pr-123")).To(Succeed())
+			Expect(cacheManager.Label("other-buildpack", "pr-123", "pr-123")).To(Succeed())
+
+			Expect(cacheManager.ListByLabel("pr-123")).To(Equal([]string{"other-buildpack", "some-buildpack"}))
+			Expect(cacheManager.ListByLabel("golden")).To(Equal([]string{"some-buildpack"}))
+			Expect(cacheManager.ListByLabel("nightly")).To(BeEmpty())
+			Expect(cacheManager.Cache["other-buildpack"].Labels).To(Equal([]string{"pr-123"}))
+
+			Expect(cacheManager.Close()).To(Succeed())
+
+			reopened := freezer.NewCacheManager(cacheDir)
+			Expect(reopened.Open()).To(Succeed())
+			Expect(reopened.ListByLabel("pr-123")).To(Equal([]string{"other-buildpack", "some-buildpack"}))
+			Expect(reopened.Close()).To(Succeed())
+		})
+
+		it("keeps labels when the entry is replaced", func() {
+			Expect(cacheManager.Label("some-buildpack", "golden")).To(Succeed())
+			Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "1.2.4", URI: "some-new-uri"})).To(Succeed())
+
+			Expect(cacheManager.ListByLabel("golden")).To(Equal([]string{"some-buildpack"}))
+		})
+
+		context("failure cases", func() {
+			context("when the entry does not exist", func() {
+				it("returns an error", func() {
+					err := cacheManager.Label("some-missing-buildpack", "golden")
+					Expect(err).To(MatchError("no cache entry for some-missing-buildpack"))
+				})
+			})
+		})
+	})
+
+	context("Rollback", func() {
+		var v1, v2 string
+
+		it.Before(func() {
+			cacheManager = freezer.NewCacheManager(cacheDir).WithKeepPrevious(true)
+			Expect(cacheManager.Open()).To(Succeed())
+
+			v1 = filepath.Join(cacheDir, "v1.tgz")
+			v2 = filepath.Join(cacheDir, "v2.tgz")
+			Expect(os.WriteFile(v1, []byte("v1"), 0644)).To(Succeed())
+			Expect(os.WriteFile(v2, []byte("v2"), 0644)).To(Succeed())
+
+			Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "v1", URI: v1})).To(Succeed())
+			Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "v2", URI: v2})).To(Succeed())
+		})
+
+		it("restores the previous entry and removes the replaced artifact", func() {
+			Expect(v1).To(BeAnExistingFile())
+
+			Expect(cacheManager.Rollback("some-buildpack")).To(Succeed())
+
+			entry, ok, err := cacheManager.Get("some-buildpack")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(entry).To(Equal(freezer.CacheEntry{Version: "v1", URI: v1}))
+
+			Expect(v2).NotTo(BeAnExistingFile())
+
+			err = cacheManager.Rollback("some-buildpack")
+			Expect(err).To(MatchError("no previous entry for some-buildpack"))
+		})
+
+		it("keeps the previous entry across Close and Open", func() {
+			Expect(cacheManager.Close()).To(Succeed())
+
+			reopened := freezer.NewCacheManager(cacheDir).WithKeepPrevious(true)
+			Expect(reopened.Open()).To(Succeed())
+			Expect(reopened.Rollback("some-buildpack")).To(Succeed())
+			Expect(reopened.Cache["some-buildpack"].Version).To(Equal("v1"))
+			Expect(reopened.Close()).To(Succeed())
+
+			Expect(filepath.Join(cacheDir, "buildpacks-cache-previous.db")).NotTo(BeAnExistingFile())
+		})
+
+		it("only keeps the immediately previous artifact", func() {
+			v3 := filepath.Join(cacheDir, "v3.tgz")
+			Expect(os.WriteFile(v3, []byte("v3"), 0644)).To(Succeed())
+
+			Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "v3", URI: v3})).To(Succeed())
+
+			Expect(v1).NotTo(BeAnExistingFile())
+			Expect(v2).To(BeAnExistingFile())
+
+			Expect(cacheManager.Rollback("some-buildpack")).To(Succeed())
+			Expect(cacheManager.Cache["some-buildpack"].Version).To(Equal("v2"))
+		})
+
+		context("when previous entries are not kept", func() {
+			it.Before(func() {
+				Expect(cacheManager.Close()).To(Succeed())
+
+				cacheManager = freezer.NewCacheManager(cacheDir)
+				Expect(cacheManager.Open()).To(Succeed())
+			})
+
+			it("removes the replaced artifact and cannot roll back", func() {
+				Expect(os.WriteFile(v1, []byte("v1"), 0644)).To(Succeed())
+				Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "v1", URI: v1})).To(Succeed())
+
+				Expect(v2).NotTo(BeAnExistingFile())
+				Expect(cacheManager.Rollback("some-buildpack")).To(MatchError(ContainSubstring("no previous entry")))
+			})
+		})
+	})
+
+	context("History", func() {
+		var v1, v2 string
+
+		it.Before(func() {
+			cacheManager = freezer.NewCacheManager(cacheDir).WithHistory(true)
+			Expect(cacheManager.Open()).To(Succeed())
+
+			v1 = filepath.Join(cacheDir, "v1", "buildpack.tgz")
+			v2 = filepath.Join(cacheDir, "v2", "buildpack.tgz")
+			for _, path := range []string{v1, v2} {
+				Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(path, []byte(path), 0644)).To(Succeed())
+			}
+
+			Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "v1", URI: v1})).To(Succeed())
+			Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "v2", URI: v2})).To(Succeed())
+		})
+
+		it("keeps every replaced version on disk", func() {
+			Expect(v1).To(BeAnExistingFile())
+			Expect(v2).To(BeAnExistingFile())
+
+			Expect(cacheManager.Cache["some-buildpack"].Version).To(Equal("v2"))
+			Expect(cacheManager.History("some-buildpack")).To(Equal([]freezer.CacheEntry{{Version: "v1", URI: v1}}))
+		})
+
+		it("checks out a kept version and keeps the current one", func() {
+			Expect(cacheManager.Checkout("some-buildpack", "v1")).To(Succeed())
+
+			Expect(cacheManager.Cache["some-buildpack"].Version).To(Equal("v1"))
+			Expect(cacheManager.History("some-buildpack")).To(Equal([]freezer.CacheEntry{{Version: "v2", URI: v2}}))
+			Expect(v2).To(BeAnExistingFile())
+
+			err := cacheManager.Checkout("some-buildpack", "v3")
+			Expect(err).To(MatchError("no kept entry of some-buildpack has version v3"))
+		})
+
+		it("does not keep a version twice when it is fetched again", func() {
+			Expect(cacheManager.Set("some-buildpack", freezer.CacheEntry{Version: "v1", URI: v1})).To(Succeed())
+
+			Expect(cacheManager.History("some-buildpack")).To(Equal([]freezer.CacheEntry{{Version: "v2", URI: v2}}))
+		})
+
+		it("keeps the history across Close and Open", func() {
+			Expect(cacheManager.Close()).To(Succeed())
+
+			reopened := freezer.NewCacheManager(cacheDir).WithHistory(true)
+			Expect(reopened.Open()).To(Succeed())
+			Expect(reopened.History("some-buildpack")).To(Equal([]freezer.CacheEntry{{Version: "v1", URI: v1}}))
+			Expect(reopened.Close()).To(Succeed())
+		})
+	})
 }
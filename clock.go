@@ -0,0 +1,18 @@
+package freezer
+
+import "time"
+
+//go:generate faux --interface Clock --output fakes/clock.go
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func NewSystemClock() SystemClock {
+	return SystemClock{}
+}
+
+func (c SystemClock) Now() time.Time {
+	return time.Now()
+}
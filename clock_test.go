@@ -0,0 +1,26 @@
+package freezer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testClock(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("Now", func() {
+		it("returns the current time", func() {
+			before := time.Now()
+			now := freezer.NewSystemClock().Now()
+			after := time.Now()
+
+			Expect(now).To(BeTemporally(">=", before))
+			Expect(now).To(BeTemporally("<=", after))
+		})
+	})
+}
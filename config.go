@@ -0,0 +1,129 @@
+package freezer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ForestEckhardt/freezer/github"
+)
+
+// Config holds the settings shared by tools built on freezer.
+//
+// LoadConfig reads it from a TOML file such as
+//
+//	cache_dir = "/var/cache/freezer"
+//	token_file = "/etc/freezer/token"
+//	endpoint = "https://github.example.com/api/v3"
+//	proxy = "http://proxy.example.com:3128"
+//	timeout = "30s"
+//	concurrency = 4
+//
+// Environment variables take precedence over the file: FREEZER_CACHE_DIR,
+// GITHUB_TOKEN, FREEZER_TOKEN_FILE, FREEZER_ENDPOINT, FREEZER_PROXY,
+// FREEZER_TIMEOUT and FREEZER_CONCURRENCY. A token, from either source, takes
+// precedence over a token file.
+type Config struct {
+	CacheDir    string
+	Token       string
+	TokenFile   string
+	Endpoint    string
+	Proxy       string
+	Timeout     time.Duration
+	Concurrency int
+}
+
+type configFile struct {
+	CacheDir    string `toml:"cache_dir"`
+	Token       string `toml:"token"`
+	TokenFile   string `toml:"token_file"`
+	Endpoint    string `toml:"endpoint"`
+	Proxy       string `toml:"proxy"`
+	Timeout     string `toml:"timeout"`
+	Concurrency int    `toml:"concurrency"`
+}
+
+// LoadConfig reads the config file at path and applies the environment
+// overrides to it. The token file, if any, is read last.
+func LoadConfig(path string) (Config, error) {
+	var file configFile
+	_, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to load config %s: %w", path, err)
+	}
+
+	overrides := map[string]*string{
+		"FREEZER_CACHE_DIR":  &file.CacheDir,
+		"GITHUB_TOKEN":       &file.Token,
+		"FREEZER_TOKEN_FILE": &file.TokenFile,
+		"FREEZER_ENDPOINT":   &file.Endpoint,
+		"FREEZER_PROXY":      &file.Proxy,
+		"FREEZER_TIMEOUT":    &file.Timeout,
+	}
+	for name, value := range overrides {
+		if env, ok := os.LookupEnv(name); ok {
+			*value = env
+		}
+	}
+
+	if env, ok := os.LookupEnv("FREEZER_CONCURRENCY"); ok {
+		file.Concurrency, err = strconv.Atoi(env)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid FREEZER_CONCURRENCY %q: %w", env, err)
+		}
+	}
+
+	config := Config{
+		CacheDir:    file.CacheDir,
+		Token:       file.Token,
+		TokenFile:   file.TokenFile,
+		Endpoint:    file.Endpoint,
+		Proxy:       file.Proxy,
+		Concurrency: file.Concurrency,
+	}
+
+	if file.Timeout != "" {
+		config.Timeout, err = time.ParseDuration(file.Timeout)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid timeout %q: %w", file.Timeout, err)
+		}
+	}
+
+	if config.Token == "" && config.TokenFile != "" {
+		content, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read token file: %w", err)
+		}
+		config.Token = strings.TrimSpace(string(content))
+	}
+
+	return config, nil
+}
+
+// GitHubConfig returns the configuration of a github.ReleaseService that
+// uses these settings. The endpoint defaults to github.DefaultEndpoint.
+func (c Config) GitHubConfig() github.Config {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = github.DefaultEndpoint
+	}
+
+	config := github.NewConfig(endpoint, c.Token)
+	config.Proxy = c.Proxy
+	config.Timeout = c.Timeout
+
+	return config
+}
+
+// CacheManager returns a CacheManager for the configured cache directory.
+func (c Config) CacheManager() CacheManager {
+	return NewCacheManager(c.CacheDir)
+}
+
+// Packager limits packager to the configured concurrency.
+func (c Config) Packager(packager Packager) LimitedPackager {
+	return NewLimitedPackager(packager, c.Concurrency)
+}
@@ -0,0 +1,152 @@
+package freezer_test
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/ForestEckhardt/freezer/github"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testConfig(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		configDir  string
+		configPath string
+		proxy      *httptest.Server
+		requests   []*http.Request
+		env        map[string]string
+	)
+
+	it.Before(func() {
+		var err error
+		configDir, err = os.MkdirTemp("", "config")
+		Expect(err).NotTo(HaveOccurred())
+
+		requests = nil
+		proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests = append(requests, req)
+			fmt.Fprint(w, `{"tag_name": "some-tag"}`)
+		}))
+
+		Expect(os.WriteFile(filepath.Join(configDir, "token"), []byte("some-file-token\n"), 0600)).To(Succeed())
+
+		configPath = filepath.Join(configDir, "freezer.toml")
+		Expect(os.WriteFile(configPath, []byte(fmt.Sprintf(`
+cache_dir = "some-cache-dir"
+token_file = %q
+endpoint = "http://api.example.com"
+proxy = %q
+timeout = "30s"
+concurrency = 4
+`, filepath.Join(configDir, "token"), proxy.URL)), 0644)).To(Succeed())
+
+		env = map[string]string{}
+		for _, name := range []string{"FREEZER_CACHE_DIR", "GITHUB_TOKEN", "FREEZER_TOKEN_FILE", "FREEZER_ENDPOINT", "FREEZER_PROXY", "FREEZER_TIMEOUT", "FREEZER_CONCURRENCY"} {
+			if value, ok := os.LookupEnv(name); ok {
+				env[name] = value
+			}
+			Expect(os.Unsetenv(name)).To(Succeed())
+		}
+	})
+
+	it.After(func() {
+		for name, value := range env {
+			Expect(os.Setenv(name, value)).To(Succeed())
+		}
+
+		proxy.Close()
+		Expect(os.RemoveAll(configDir)).To(Succeed())
+	})
+
+	it("loads the settings from the file", func() {
+		config, err := freezer.LoadConfig(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(Equal(freezer.Config{
+			CacheDir:    "some-cache-dir",
+			Token:       "some-file-token",
+			TokenFile:   filepath.Join(configDir, "token"),
+			Endpoint:    "http://api.example.com",
+			Proxy:       proxy.URL,
+			Timeout:     30 * time.Second,
+			Concurrency: 4,
+		}))
+
+		Expect(config.CacheManager().Dir()).To(Equal("some-cache-dir"))
+	})
+
+	it("assembles a release service that uses the settings", func() {
+		config, err := freezer.LoadConfig(configPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		release, err := github.NewReleaseService(config.GitHubConfig()).Get(gocontext.Background(), "some-org", "some-repo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(release.TagName).To(Equal("some-tag"))
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Host).To(Equal("api.example.com"))
+		Expect(requests[0].URL.Path).To(Equal("/repos/some-org/some-repo/releases/latest"))
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("token some-file-token"))
+	})
+
+	context("when environment variables are set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("FREEZER_CACHE_DIR", "some-env-cache-dir")).To(Succeed())
+			Expect(os.Setenv("GITHUB_TOKEN", "some-env-token")).To(Succeed())
+			Expect(os.Setenv("FREEZER_TIMEOUT", "1m")).To(Succeed())
+			Expect(os.Setenv("FREEZER_CONCURRENCY", "8")).To(Succeed())
+		})
+
+		it.After(func() {
+			for _, name := range []string{"FREEZER_CACHE_DIR", "GITHUB_TOKEN", "FREEZER_TIMEOUT", "FREEZER_CONCURRENCY"} {
+				Expect(os.Unsetenv(name)).To(Succeed())
+			}
+		})
+
+		it("overrides the file with them", func() {
+			config, err := freezer.LoadConfig(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.CacheDir).To(Equal("some-env-cache-dir"))
+			Expect(config.Token).To(Equal("some-env-token"))
+			Expect(config.Timeout).To(Equal(time.Minute))
+			Expect(config.Concurrency).To(Equal(8))
+			Expect(config.Endpoint).To(Equal("http://api.example.com"))
+		})
+	})
+
+	context("when no endpoint is configured", func() {
+		it("uses the public GitHub API", func() {
+			Expect(freezer.Config{}.GitHubConfig().Endpoint).To(Equal(github.DefaultEndpoint))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the file does not exist", func() {
+			it("returns an error", func() {
+				_, err := freezer.LoadConfig(filepath.Join(configDir, "missing.toml"))
+				Expect(err).To(MatchError(ContainSubstring("failed to load config")))
+			})
+		})
+
+		context("when the timeout is invalid", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(configPath, []byte(`timeout = "soon"`), 0644)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := freezer.LoadConfig(configPath)
+				Expect(err).To(MatchError(ContainSubstring(`invalid timeout "soon"`)))
+			})
+		})
+	})
+}
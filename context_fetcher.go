@@ -0,0 +1,37 @@
+package freezer
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// contextReader fails reads once its context is done, so that a download
+// from a GitReleaseFetcher that does not watch the context while streaming
+// still stops when the fetch is cancelled.
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	err := c.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	return c.ReadCloser.Read(p)
+}
+
+// sleep waits for d or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,180 @@
+package freezer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/ForestEckhardt/freezer/github"
+)
+
+// DirectoryFetcher is a GitReleaseFetcher that serves releases and downloads
+// from a directory tree, so that the whole fetch pipeline can run against
+// fixtures on disk.
+//
+// The releases of a repository are read from <root>/<org>/<repo>/releases.json,
+// newest first. Asset and tarball urls are served from the file at the url
+// path under root. A tarball url that names a directory is served as a
+// gzipped tarball of that directory, nested in a top-level directory the way
+// GitHub nests source tarballs.
+type DirectoryFetcher struct {
+	root string
+}
+
+func NewDirectoryFetcher(root string) DirectoryFetcher {
+	return DirectoryFetcher{
+		root: root,
+	}
+}
+
+func (d DirectoryFetcher) Get(ctx context.Context, org, repo string) (github.Release, error) {
+	releases, err := d.ListReleases(ctx, org, repo)
+	if err != nil {
+		return github.Release{}, err
+	}
+
+	if len(releases) == 0 {
+		return github.Release{}, fmt.Errorf("no releases found for %s/%s", org, repo)
+	}
+
+	return releases[0], nil
+}
+
+func (d DirectoryFetcher) GetByID(ctx context.Context, org, repo string, id int64) (github.Release, error) {
+	releases, err := d.ListReleases(ctx, org, repo)
+	if err != nil {
+		return github.Release{}, err
+	}
+
+	for _, release := range releases {
+		if release.ID == id {
+			return release, nil
+		}
+	}
+
+	return github.Release{}, fmt.Errorf("no release %d found for %s/%s", id, org, repo)
+}
+
+func (d DirectoryFetcher) ListReleases(ctx context.Context, org, repo string) ([]github.Release, error) {
+	content, err := os.ReadFile(filepath.Join(d.root, org, repo, "releases.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []github.Release
+	err = json.Unmarshal(content, &releases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode releases of %s/%s: %w", org, repo, err)
+	}
+
+	return releases, nil
+}
+
+func (d DirectoryFetcher) GetReleaseAsset(ctx context.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+	path, err := d.path(asset.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+func (d DirectoryFetcher) GetReleaseTarball(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	path, err := d.path(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return os.Open(path)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarDirectory(pw, path))
+	}()
+
+	return pr, nil
+}
+
+func (d DirectoryFetcher) path(rawURL string) (string, error) {
+	uri, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(d.root, filepath.FromSlash(cleanArchivePath(uri.Path))), nil
+}
+
+func tarDirectory(w io.Writer, dir string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	prefix := filepath.Base(dir)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
@@ -0,0 +1,131 @@
+package freezer_test
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/ForestEckhardt/freezer/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDirectoryFetcher(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		root     string
+		cacheDir string
+
+		buildpackCache   *fakes.BuildpackCache
+		packager         *fakes.Packager
+		directoryFetcher freezer.DirectoryFetcher
+		remoteFetcher    freezer.RemoteFetcher
+		remoteBuildpack  freezer.RemoteBuildpack
+	)
+
+	it.Before(func() {
+		var err error
+		root, err = os.MkdirTemp("", "fixtures")
+		Expect(err).NotTo(HaveOccurred())
+
+		cacheDir, err = os.MkdirTemp("", "cache")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(root, "some-org", "some-repo"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "some-org", "some-repo", "releases.json"), []byte(`[
+			{
+				"id": 2,
+				"tag_name": "v2.0.0",
+				"tarball_url": "https://api.example.com/tarballs/some-repo-v2",
+				"assets": [{"url": "https://api.example.com/assets/some-repo-v2.tgz", "name": "some-repo-v2.tgz"}]
+			},
+			{
+				"id": 1,
+				"tag_name": "v1.0.0",
+				"tarball_url": "https://api.example.com/tarballs/some-repo-v1"
+			}
+		]`), 0644)).To(Succeed())
+
+		source := filepath.Join(root, "tarballs", "some-repo-v2")
+		Expect(os.MkdirAll(filepath.Join(source, "bin"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(source, "buildpack.toml"), []byte(`[buildpack]
+id = "some-org/some-repo"
+`), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(source, "bin", "build"), []byte("#!/bin/sh"), 0755)).To(Succeed())
+
+		Expect(os.MkdirAll(filepath.Join(root, "assets"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "assets", "some-repo-v2.tgz"), []byte("some-asset"), 0644)).To(Succeed())
+
+		buildpackCache = &fakes.BuildpackCache{}
+		buildpackCache.DirCall.Stub = func() string {
+			return cacheDir
+		}
+
+		packager = &fakes.Packager{}
+		packager.ExecuteCall.Stub = func(_ gocontext.Context, buildpackDir, output, _ string, _ bool) error {
+			content, err := os.ReadFile(filepath.Join(buildpackDir, "buildpack.toml"))
+			if err != nil {
+				return err
+			}
+
+			return os.WriteFile(output, content, 0644)
+		}
+
+		directoryFetcher = freezer.NewDirectoryFetcher(root)
+		remoteFetcher = freezer.NewRemoteFetcher(buildpackCache, directoryFetcher, packager, freezer.NewFileSystem(os.MkdirTemp))
+		remoteBuildpack = freezer.NewRemoteBuildpack("some-org", "some-repo")
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(root)).To(Succeed())
+		Expect(os.RemoveAll(cacheDir)).To(Succeed())
+	})
+
+	it("packages the latest release from its source directory", func() {
+		remoteBuildpack.Offline = true
+
+		uri, err := remoteFetcher.Get(remoteBuildpack)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "cached", "v2.0.0.tgz")))
+
+		content, err := os.ReadFile(uri)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring(`id = "some-org/some-repo"`))
+
+		Expect(packager.ExecuteCall.Receives.Version).To(Equal("v2.0.0"))
+		Expect(buildpackCache.SetCall.Receives.CachedEntry.Version).To(Equal("v2.0.0"))
+	})
+
+	it("serves release assets from their files", func() {
+		remoteBuildpack.Offline = false
+
+		uri, err := remoteFetcher.Get(remoteBuildpack)
+		Expect(err).NotTo(HaveOccurred())
+
+		content, err := os.ReadFile(uri)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("some-asset"))
+
+		Expect(packager.ExecuteCall.CallCount).To(Equal(0))
+	})
+
+	it("finds releases by id", func() {
+		release, err := directoryFetcher.GetByID(gocontext.Background(), "some-org", "some-repo", 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(release.TagName).To(Equal("v1.0.0"))
+
+		_, err = directoryFetcher.GetByID(gocontext.Background(), "some-org", "some-repo", 3)
+		Expect(err).To(MatchError("no release 3 found for some-org/some-repo"))
+	})
+
+	context("when the repository has no fixtures", func() {
+		it("returns an error", func() {
+			_, err := directoryFetcher.Get(gocontext.Background(), "some-org", "other-repo")
+			Expect(err).To(MatchError(os.ErrNotExist))
+		})
+	})
+}
@@ -0,0 +1,273 @@
+package freezer
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxBufferedFile is the largest regular file that ExtractTarball hands to
+// its writers. Larger files are written by the reader itself so that the
+// content held in memory stays bounded.
+const maxBufferedFile = 1 << 20
+
+type extractedFile struct {
+	path    string
+	mode    os.FileMode
+	content []byte
+
+	// written is closed once the file has been written, or has failed to
+	// be, so that a later entry for the same path can wait for it.
+	written chan struct{}
+}
+
+type extractedLink struct {
+	path   string
+	target string
+}
+
+// ExtractTarball extracts a gzip-compressed or plain tar stream into
+// destination, dropping the first stripComponents elements of every path.
+// Directories are created as soon as they are read, before any file beneath
+// them is handed out, and regular files are written by up to concurrency
+// writers. Symlinks and hard links are created once every file has been
+// written. Entries for the same path are written in archive order, so the
+// last one wins as it would with a single writer. A concurrency below 2
+// writes everything in archive order.
+func ExtractTarball(r io.Reader, destination string, stripComponents, concurrency int) error {
+	tr, err := newArtifactReader(r)
+	if err != nil {
+		return err
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		files    = make(chan extractedFile, concurrency)
+		failed   = make(chan struct{})
+		once     sync.Once
+		writeErr error
+		wg       sync.WaitGroup
+	)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				err := writeExtractedFile(file.path, file.mode, bytes.NewReader(file.content))
+				close(file.written)
+				if err != nil {
+					once.Do(func() {
+						writeErr = err
+						close(failed)
+					})
+				}
+			}
+		}()
+	}
+
+	symlinks, hardlinks, err := readTarball(tr, destination, stripComponents, concurrency > 1, files, failed)
+	close(files)
+	wg.Wait()
+
+	if err != nil {
+		return err
+	}
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	return createLinks(symlinks, hardlinks)
+}
+
+// readTarball creates the directories of the archive and writes its
+// regular files, or sends the small ones to files when dispatch is set. It
+// stops early once failed is closed. The links are returned for the caller
+// to create after the writers are done.
+func readTarball(tr *tar.Reader, destination string, stripComponents int, dispatch bool, files chan<- extractedFile, failed <-chan struct{}) ([]extractedLink, []extractedLink, error) {
+	var (
+		directories = map[string]struct{}{}
+		pending     = map[string]chan struct{}{}
+		symlinks    []extractedLink
+		hardlinks   []extractedLink
+	)
+
+	// wait blocks until a write of path handed to the writers is done
+	wait := func(path string) {
+		written, ok := pending[path]
+		if ok {
+			<-written
+			delete(pending, path)
+		}
+	}
+
+	mkdir := func(dir string) error {
+		if _, ok := directories[dir]; ok {
+			return nil
+		}
+
+		err := os.MkdirAll(dir, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to create archived directory: %w", err)
+		}
+		directories[dir] = struct{}{}
+
+		return nil
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return symlinks, hardlinks, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read tar response: %w", err)
+		}
+
+		path, ok, err := extractPath(destination, hdr.Name, stripComponents)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			err = mkdir(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		err = mkdir(filepath.Dir(path))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			wait(path)
+
+			if !dispatch || hdr.Size > maxBufferedFile {
+				err = writeExtractedFile(path, hdr.FileInfo().Mode(), tr)
+				if err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read tar response: %w", err)
+			}
+
+			file := extractedFile{path: path, mode: hdr.FileInfo().Mode(), content: content, written: make(chan struct{})}
+			select {
+			case files <- file:
+				pending[path] = file.written
+			case <-failed:
+				return nil, nil, nil
+			}
+
+		case tar.TypeSymlink:
+			symlinks = append(symlinks, extractedLink{path: path, target: hdr.Linkname})
+
+		case tar.TypeLink:
+			target, ok, err := extractPath(destination, hdr.Linkname, stripComponents)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				hardlinks = append(hardlinks, extractedLink{path: path, target: target})
+			}
+		}
+	}
+}
+
+// extractPath returns where name is extracted to beneath destination, and
+// false when stripping components leaves nothing of it.
+func extractPath(destination, name string, stripComponents int) (string, bool, error) {
+	name = filepath.Clean(name)
+	if name == "." {
+		return "", false, nil
+	}
+
+	path := filepath.Join(destination, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, filepath.Clean(destination)+string(os.PathSeparator)) {
+		return "", false, fmt.Errorf("illegal file path %q: the file path does not occur within the destination directory", name)
+	}
+
+	elements := strings.Split(filepath.ToSlash(name), "/")
+	if len(elements) <= stripComponents {
+		return "", false, nil
+	}
+
+	return filepath.Join(append([]string{destination}, elements[stripComponents:]...)...), true, nil
+}
+
+func writeExtractedFile(path string, mode os.FileMode, content io.Reader) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to create archived file: %w", err)
+	}
+
+	_, err = io.Copy(file, content)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// createLinks creates the symlinks in rounds, so that a symlink whose
+// target is another symlink waits for it, and then the hard links. A
+// symlink whose target never resolves fails the extraction.
+func createLinks(symlinks, hardlinks []extractedLink) error {
+	for len(symlinks) > 0 {
+		var (
+			pending []extractedLink
+			lastErr error
+		)
+
+		for _, link := range symlinks {
+			_, err := filepath.EvalSymlinks(filepath.Join(filepath.Dir(link.path), link.target))
+			if err != nil {
+				pending = append(pending, link)
+				lastErr = fmt.Errorf("failed to evaluate symlink %s: %w", link.path, err)
+				continue
+			}
+
+			err = os.Symlink(link.target, link.path)
+			if err != nil {
+				return fmt.Errorf("failed to extract symlink: %w", err)
+			}
+		}
+
+		if len(pending) == len(symlinks) {
+			return lastErr
+		}
+
+		symlinks = pending
+	}
+
+	for _, link := range hardlinks {
+		err := os.Link(link.target, link.path)
+		if err != nil {
+			return fmt.Errorf("failed to extract link: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package freezer_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testExtractor(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		destination string
+	)
+
+	it.Before(func() {
+		var err error
+		destination, err = os.MkdirTemp("", "destination")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(destination)).To(Succeed())
+	})
+
+	context("ExtractTarball", func() {
+		context("when given an archive with many files", func() {
+			var archive []byte
+
+			it.Before(func() {
+				buffer := bytes.NewBuffer(nil)
+				gw := gzip.NewWriter(buffer)
+				tw := tar.NewWriter(gw)
+
+				Expect(tw.WriteHeader(&tar.Header{Name: "some-org-some-repo-1234/", Typeflag: tar.TypeDir, Mode: 0755})).To(Succeed())
+				for i := 0; i < 1000; i++ {
+					// Half of the directories have no header of their own
+					dir := fmt.Sprintf("some-org-some-repo-1234/dir-%d", i%20)
+					if i < 10 {
+						Expect(tw.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0755})).To(Succeed())
+					}
+
+					content := strconv.Itoa(i)
+					Expect(tw.WriteHeader(&tar.Header{Name: fmt.Sprintf("%s/file-%d", dir, i), Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})).To(Succeed())
+					_, err := tw.Write([]byte(content))
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				Expect(tw.WriteHeader(&tar.Header{Name: "some-org-some-repo-1234/bin/run", Typeflag: tar.TypeReg, Mode: 0755, Size: 3})).To(Succeed())
+				_, err := tw.Write([]byte("run"))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(tw.WriteHeader(&tar.Header{Name: "some-org-some-repo-1234/chained-link", Typeflag: tar.TypeSymlink, Linkname: "some-link"})).To(Succeed())
+				Expect(tw.WriteHeader(&tar.Header{Name: "some-org-some-repo-1234/some-link", Typeflag: tar.TypeSymlink, Linkname: "dir-1/file-1"})).To(Succeed())
+				Expect(tw.WriteHeader(&tar.Header{Name: "some-org-some-repo-1234/hard-link", Typeflag: tar.TypeLink, Linkname: "some-org-some-repo-1234/dir-2/file-2"})).To(Succeed())
+
+				Expect(tw.Close()).To(Succeed())
+				Expect(gw.Close()).To(Succeed())
+
+				archive = buffer.Bytes()
+			})
+
+			it("extracts every file with concurrent writers", func() {
+				Expect(freezer.ExtractTarball(bytes.NewReader(archive), destination, 1, 8)).To(Succeed())
+
+				for i := 0; i < 1000; i++ {
+					content, err := os.ReadFile(filepath.Join(destination, fmt.Sprintf("dir-%d", i%20), fmt.Sprintf("file-%d", i)))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal(strconv.Itoa(i)))
+				}
+
+				info, err := os.Stat(filepath.Join(destination, "bin", "run"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode()).To(Equal(os.FileMode(0755)))
+
+				link, err := os.Readlink(filepath.Join(destination, "chained-link"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(link).To(Equal("some-link"))
+
+				content, err := os.ReadFile(filepath.Join(destination, "chained-link"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("1"))
+
+				content, err = os.ReadFile(filepath.Join(destination, "hard-link"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("2"))
+			})
+
+			it("extracts the same tree as a single writer", func() {
+				sequential, err := os.MkdirTemp("", "sequential")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.RemoveAll(sequential)
+
+				Expect(freezer.ExtractTarball(bytes.NewReader(archive), sequential, 1, 1)).To(Succeed())
+				Expect(freezer.ExtractTarball(bytes.NewReader(archive), destination, 1, 8)).To(Succeed())
+
+				Expect(listTree(destination)).To(Equal(listTree(sequential)))
+			})
+		})
+
+		context("when an archive holds the same path more than once", func() {
+			var large []byte
+
+			it.Before(func() {
+				large = bytes.Repeat([]byte("x"), 2<<20)
+			})
+
+			it("keeps the entry that comes last", func() {
+				for _, entries := range [][]string{
+					{"some-content", string(large)},
+					{string(large), "some-content"},
+					{"some-content", "some-other-content"},
+				} {
+					buffer := bytes.NewBuffer(nil)
+					tw := tar.NewWriter(buffer)
+					for _, content := range entries {
+						Expect(tw.WriteHeader(&tar.Header{Name: "some-file", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})).To(Succeed())
+						_, err := tw.Write([]byte(content))
+						Expect(err).NotTo(HaveOccurred())
+					}
+					Expect(tw.Close()).To(Succeed())
+
+					Expect(freezer.ExtractTarball(buffer, destination, 0, 8)).To(Succeed())
+
+					content, err := os.ReadFile(filepath.Join(destination, "some-file"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal(entries[len(entries)-1]))
+				}
+			})
+		})
+
+		context("failure cases", func() {
+			context("when an entry escapes the destination", func() {
+				it("returns an error", func() {
+					archive := tarball(map[string]string{"../some-file": "some-content"})
+
+					err := freezer.ExtractTarball(bytes.NewReader(archive), destination, 0, 4)
+					Expect(err).To(MatchError(ContainSubstring("the file path does not occur within the destination directory")))
+				})
+			})
+
+			context("when a file cannot be written", func() {
+				it.Before(func() {
+					Expect(os.MkdirAll(filepath.Join(destination, "some-file"), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					archive := tarball(map[string]string{"some-file": "some-content"})
+
+					err := freezer.ExtractTarball(bytes.NewReader(archive), destination, 0, 4)
+					Expect(err).To(MatchError(ContainSubstring("failed to create archived file")))
+				})
+			})
+
+			context("when a symlink never resolves", func() {
+				it("returns an error", func() {
+					buffer := bytes.NewBuffer(nil)
+					tw := tar.NewWriter(buffer)
+					Expect(tw.WriteHeader(&tar.Header{Name: "some-link", Typeflag: tar.TypeSymlink, Linkname: "other-link"})).To(Succeed())
+					Expect(tw.WriteHeader(&tar.Header{Name: "other-link", Typeflag: tar.TypeSymlink, Linkname: "some-link"})).To(Succeed())
+					Expect(tw.Close()).To(Succeed())
+
+					err := freezer.ExtractTarball(buffer, destination, 0, 4)
+					Expect(err).To(MatchError(ContainSubstring("failed to evaluate symlink")))
+				})
+			})
+		})
+	})
+}
+
+func BenchmarkExtractTarball(b *testing.B) {
+	buffer := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(buffer)
+	tw := tar.NewWriter(gw)
+
+	content := bytes.Repeat([]byte("x"), 16*1024)
+	for i := 0; i < 2000; i++ {
+		err := tw.WriteHeader(&tar.Header{Name: fmt.Sprintf("some-repo/dir-%d/file-%d", i%50, i), Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		_, err = tw.Write(content)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		b.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		b.Fatal(err)
+	}
+	archive := buffer.Bytes()
+
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				destination, err := os.MkdirTemp("", "destination")
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				err = freezer.ExtractTarball(bytes.NewReader(archive), destination, 1, concurrency)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				os.RemoveAll(destination)
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func tarball(files map[string]string) []byte {
+	buffer := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buffer)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+
+	return buffer.Bytes()
+}
+
+func listTree(root string) map[string]string {
+	tree := map[string]string{}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, _ := os.Readlink(path)
+			tree[rel] = "-> " + link
+		case info.Mode().IsRegular():
+			content, _ := os.ReadFile(path)
+			tree[rel] = fmt.Sprintf("%s %s", info.Mode(), content)
+		default:
+			tree[rel] = info.Mode().String()
+		}
+
+		return nil
+	})
+
+	return tree
+}
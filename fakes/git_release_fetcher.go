@@ -1,6 +1,7 @@
 package fakes
 
 import (
+	"context"
 	"io"
 	"sync"
 
@@ -12,6 +13,7 @@ type GitReleaseFetcher struct {
 		sync.Mutex
 		CallCount int
 		Receives  struct {
+			Ctx  context.Context
 			Org  string
 			Repo string
 		}
@@ -19,62 +21,121 @@ type GitReleaseFetcher struct {
 			Release github.Release
 			Error   error
 		}
-		Stub func(string, string) (github.Release, error)
+		Stub func(context.Context, string, string) (github.Release, error)
+	}
+	GetByIDCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			Ctx  context.Context
+			Org  string
+			Repo string
+			Id   int64
+		}
+		Returns struct {
+			Release github.Release
+			Error   error
+		}
+		Stub func(context.Context, string, string, int64) (github.Release, error)
+	}
+	ListReleasesCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			Ctx  context.Context
+			Org  string
+			Repo string
+		}
+		Returns struct {
+			ReleaseSlice []github.Release
+			Error        error
+		}
+		Stub func(context.Context, string, string) ([]github.Release, error)
 	}
 	GetReleaseAssetCall struct {
 		sync.Mutex
 		CallCount int
 		Receives  struct {
+			Ctx   context.Context
 			Asset github.ReleaseAsset
 		}
 		Returns struct {
 			ReadCloser io.ReadCloser
 			Error      error
 		}
-		Stub func(github.ReleaseAsset) (io.ReadCloser, error)
+		Stub func(context.Context, github.ReleaseAsset) (io.ReadCloser, error)
 	}
 	GetReleaseTarballCall struct {
 		sync.Mutex
 		CallCount int
 		Receives  struct {
+			Ctx context.Context
 			Url string
 		}
 		Returns struct {
 			ReadCloser io.ReadCloser
 			Error      error
 		}
-		Stub func(string) (io.ReadCloser, error)
+		Stub func(context.Context, string) (io.ReadCloser, error)
 	}
 }
 
-func (f *GitReleaseFetcher) Get(param1 string, param2 string) (github.Release, error) {
+func (f *GitReleaseFetcher) Get(param1 context.Context, param2 string, param3 string) (github.Release, error) {
 	f.GetCall.Lock()
 	defer f.GetCall.Unlock()
 	f.GetCall.CallCount++
-	f.GetCall.Receives.Org = param1
-	f.GetCall.Receives.Repo = param2
+	f.GetCall.Receives.Ctx = param1
+	f.GetCall.Receives.Org = param2
+	f.GetCall.Receives.Repo = param3
 	if f.GetCall.Stub != nil {
-		return f.GetCall.Stub(param1, param2)
+		return f.GetCall.Stub(param1, param2, param3)
 	}
 	return f.GetCall.Returns.Release, f.GetCall.Returns.Error
 }
-func (f *GitReleaseFetcher) GetReleaseAsset(param1 github.ReleaseAsset) (io.ReadCloser, error) {
+func (f *GitReleaseFetcher) GetByID(param1 context.Context, param2 string, param3 string, param4 int64) (github.Release, error) {
+	f.GetByIDCall.Lock()
+	defer f.GetByIDCall.Unlock()
+	f.GetByIDCall.CallCount++
+	f.GetByIDCall.Receives.Ctx = param1
+	f.GetByIDCall.Receives.Org = param2
+	f.GetByIDCall.Receives.Repo = param3
+	f.GetByIDCall.Receives.Id = param4
+	if f.GetByIDCall.Stub != nil {
+		return f.GetByIDCall.Stub(param1, param2, param3, param4)
+	}
+	return f.GetByIDCall.Returns.Release, f.GetByIDCall.Returns.Error
+}
+func (f *GitReleaseFetcher) ListReleases(param1 context.Context, param2 string, param3 string) ([]github.Release, error) {
+	f.ListReleasesCall.Lock()
+	defer f.ListReleasesCall.Unlock()
+	f.ListReleasesCall.CallCount++
+	f.ListReleasesCall.Receives.Ctx = param1
+	f.ListReleasesCall.Receives.Org = param2
+	f.ListReleasesCall.Receives.Repo = param3
+	if f.ListReleasesCall.Stub != nil {
+		return f.ListReleasesCall.Stub(param1, param2, param3)
+	}
+	return f.ListReleasesCall.Returns.ReleaseSlice, f.ListReleasesCall.Returns.Error
+}
+func (f *GitReleaseFetcher) GetReleaseAsset(param1 context.Context, param2 github.ReleaseAsset) (io.ReadCloser, error) {
 	f.GetReleaseAssetCall.Lock()
 	defer f.GetReleaseAssetCall.Unlock()
 	f.GetReleaseAssetCall.CallCount++
-	f.GetReleaseAssetCall.Receives.Asset = param1
+	f.GetReleaseAssetCall.Receives.Ctx = param1
+	f.GetReleaseAssetCall.Receives.Asset = param2
 	if f.GetReleaseAssetCall.Stub != nil {
-		return f.GetReleaseAssetCall.Stub(param1)
+		return f.GetReleaseAssetCall.Stub(param1, param2)
 	}
 	return f.GetReleaseAssetCall.Returns.ReadCloser, f.GetReleaseAssetCall.Returns.Error
 }
-func (f *GitReleaseFetcher) GetReleaseTarball(param1 string) (io.ReadCloser, error) {
+func (f *GitReleaseFetcher) GetReleaseTarball(param1 context.Context, param2 string) (io.ReadCloser, error) {
 	f.GetReleaseTarballCall.Lock()
 	defer f.GetReleaseTarballCall.Unlock()
 	f.GetReleaseTarballCall.CallCount++
-	f.GetReleaseTarballCall.Receives.Url = param1
+	f.GetReleaseTarballCall.Receives.Ctx = param1
+	f.GetReleaseTarballCall.Receives.Url = param2
 	if f.GetReleaseTarballCall.Stub != nil {
-		return f.GetReleaseTarballCall.Stub(param1)
+		return f.GetReleaseTarballCall.Stub(param1, param2)
 	}
 	return f.GetReleaseTarballCall.Returns.ReadCloser, f.GetReleaseTarballCall.Returns.Error
 }
@@ -1,12 +1,16 @@
 package fakes
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 type Packager struct {
 	ExecuteCall struct {
 		sync.Mutex
 		CallCount int
 		Receives  struct {
+			Ctx          context.Context
 			BuildpackDir string
 			Output       string
 			Version      string
@@ -15,20 +19,21 @@ type Packager struct {
 		Returns struct {
 			Error error
 		}
-		Stub func(string, string, string, bool) error
+		Stub func(context.Context, string, string, string, bool) error
 	}
 }
 
-func (f *Packager) Execute(param1 string, param2 string, param3 string, param4 bool) error {
+func (f *Packager) Execute(param1 context.Context, param2 string, param3 string, param4 string, param5 bool) error {
 	f.ExecuteCall.Lock()
 	defer f.ExecuteCall.Unlock()
 	f.ExecuteCall.CallCount++
-	f.ExecuteCall.Receives.BuildpackDir = param1
-	f.ExecuteCall.Receives.Output = param2
-	f.ExecuteCall.Receives.Version = param3
-	f.ExecuteCall.Receives.Cached = param4
+	f.ExecuteCall.Receives.Ctx = param1
+	f.ExecuteCall.Receives.BuildpackDir = param2
+	f.ExecuteCall.Receives.Output = param3
+	f.ExecuteCall.Receives.Version = param4
+	f.ExecuteCall.Receives.Cached = param5
 	if f.ExecuteCall.Stub != nil {
-		return f.ExecuteCall.Stub(param1, param2, param3, param4)
+		return f.ExecuteCall.Stub(param1, param2, param3, param4, param5)
 	}
 	return f.ExecuteCall.Returns.Error
 }
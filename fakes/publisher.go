@@ -0,0 +1,31 @@
+package fakes
+
+import "sync"
+
+type Publisher struct {
+	PublishCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			Path      string
+			Reference string
+		}
+		Returns struct {
+			String string
+			Error  error
+		}
+		Stub func(string, string) (string, error)
+	}
+}
+
+func (f *Publisher) Publish(param1 string, param2 string) (string, error) {
+	f.PublishCall.Lock()
+	defer f.PublishCall.Unlock()
+	f.PublishCall.CallCount++
+	f.PublishCall.Receives.Path = param1
+	f.PublishCall.Receives.Reference = param2
+	if f.PublishCall.Stub != nil {
+		return f.PublishCall.Stub(param1, param2)
+	}
+	return f.PublishCall.Returns.String, f.PublishCall.Returns.Error
+}
@@ -0,0 +1,94 @@
+package freezer
+
+import (
+	"fmt"
+	"strings"
+)
+
+type FetchStage string
+
+const (
+	StageResolve  FetchStage = "resolve"
+	StageDownload FetchStage = "download"
+	StageExtract  FetchStage = "extract"
+	StagePackage  FetchStage = "package"
+	StageCache    FetchStage = "cache"
+)
+
+// The errors below wrap the cause of a failed fetch together with the
+// buildpack and the stage that failed, so that callers can tell the stages
+// apart with errors.As. Their message is the message of the cause.
+
+// ResolveError is returned when the release of a buildpack cannot be
+// determined.
+type ResolveError struct {
+	Buildpack RemoteBuildpack
+	Err       error
+}
+
+func (e *ResolveError) Error() string     { return e.Err.Error() }
+func (e *ResolveError) Unwrap() error     { return e.Err }
+func (e *ResolveError) Stage() FetchStage { return StageResolve }
+
+// DownloadError is returned when an asset or source tarball cannot be
+// downloaded or is not what was expected.
+type DownloadError struct {
+	Buildpack RemoteBuildpack
+	Err       error
+}
+
+func (e *DownloadError) Error() string     { return e.Err.Error() }
+func (e *DownloadError) Unwrap() error     { return e.Err }
+func (e *DownloadError) Stage() FetchStage { return StageDownload }
+
+// ExtractError is returned when a download cannot be unpacked or its
+// contents fail verification.
+type ExtractError struct {
+	Buildpack RemoteBuildpack
+	Err       error
+}
+
+func (e *ExtractError) Error() string     { return e.Err.Error() }
+func (e *ExtractError) Unwrap() error     { return e.Err }
+func (e *ExtractError) Stage() FetchStage { return StageExtract }
+
+// PackageError is returned when packaging a source tarball fails.
+type PackageError struct {
+	Buildpack RemoteBuildpack
+	Err       error
+}
+
+func (e *PackageError) Error() string     { return e.Err.Error() }
+func (e *PackageError) Unwrap() error     { return e.Err }
+func (e *PackageError) Stage() FetchStage { return StagePackage }
+
+// CacheError is returned when the cache cannot be read or written.
+type CacheError struct {
+	Buildpack RemoteBuildpack
+	Err       error
+}
+
+func (e *CacheError) Error() string     { return e.Err.Error() }
+func (e *CacheError) Unwrap() error     { return e.Err }
+func (e *CacheError) Stage() FetchStage { return StageCache }
+
+// BatchError is returned by GetAll when some of the buildpacks could not be
+// fetched. Failures holds one entry per failed buildpack, in the order the
+// buildpacks were given.
+type BatchError struct {
+	Failures []BatchFailure
+}
+
+type BatchFailure struct {
+	Buildpack RemoteBuildpack
+	Err       error
+}
+
+func (e *BatchError) Error() string {
+	lines := []string{fmt.Sprintf("failed to fetch %d buildpack(s):", len(e.Failures))}
+	for _, failure := range e.Failures {
+		lines = append(lines, fmt.Sprintf("  %s/%s: %s", failure.Buildpack.Org, failure.Buildpack.Repo, failure.Err))
+	}
+
+	return strings.Join(lines, "\n")
+}
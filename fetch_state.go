@@ -0,0 +1,158 @@
+package freezer
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ForestEckhardt/freezer/github"
+)
+
+// fetchGroup coalesces concurrent fetches with the same key into one.
+type fetchGroup struct {
+	mutex   sync.Mutex
+	flights map[string]*fetchFlight
+}
+
+type fetchFlight struct {
+	done   chan struct{}
+	result FetchResult
+	err    error
+}
+
+func (g *fetchGroup) do(key string, fetch func() (FetchResult, error)) (FetchResult, error) {
+	g.mutex.Lock()
+	if g.flights == nil {
+		g.flights = map[string]*fetchFlight{}
+	}
+
+	if flight, ok := g.flights[key]; ok {
+		g.mutex.Unlock()
+		<-flight.done
+		return flight.result, flight.err
+	}
+
+	flight := &fetchFlight{done: make(chan struct{})}
+	g.flights[key] = flight
+	g.mutex.Unlock()
+
+	flight.result, flight.err = fetch()
+
+	g.mutex.Lock()
+	delete(g.flights, key)
+	g.mutex.Unlock()
+	close(flight.done)
+
+	return flight.result, flight.err
+}
+
+// syncCache serializes access to a BuildpackCache that is not safe for
+// concurrent use, such as CacheManager.
+type syncCache struct {
+	mutex sync.Mutex
+	cache BuildpackCache
+}
+
+func (s *syncCache) Get(key string) (CacheEntry, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.cache.Get(key)
+}
+
+func (s *syncCache) Set(key string, cachedEntry CacheEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.cache.Set(key, cachedEntry)
+}
+
+func (s *syncCache) RecordUse(key string) {
+	recorder, ok := s.cache.(UsageRecorder)
+	if !ok {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	recorder.RecordUse(key)
+}
+
+func (s *syncCache) Dir() string {
+	return s.cache.Dir()
+}
+
+// keyLocks hands out one lock per cache key.
+type keyLocks struct {
+	mutex sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock locks key and returns the function that unlocks it.
+func (k *keyLocks) lock(key string) func() {
+	k.mutex.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*sync.Mutex{}
+	}
+
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		k.locks[key] = lock
+	}
+	k.mutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// sourceStore holds extracted source tarballs between fetches of the two
+// variants of a release.
+type sourceStore struct {
+	mutex sync.Mutex
+	dirs  map[string]string
+}
+
+func (s *sourceStore) put(key, dir string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.dirs == nil {
+		s.dirs = map[string]string{}
+	}
+
+	if previous, ok := s.dirs[key]; ok && previous != dir {
+		os.RemoveAll(previous)
+	}
+	s.dirs[key] = dir
+}
+
+func (s *sourceStore) take(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dir, ok := s.dirs[key]
+	delete(s.dirs, key)
+
+	return dir, ok
+}
+
+func (s *sourceStore) discard() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, dir := range s.dirs {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			return err
+		}
+		delete(s.dirs, key)
+	}
+
+	return nil
+}
+
+func sourceKey(buildpack RemoteBuildpack, release github.Release) string {
+	return fmt.Sprintf("%s/%s/%d/%s", buildpack.Org, buildpack.Repo, release.ID, release.TagName)
+}
@@ -0,0 +1,74 @@
+package github
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// byteLimiter caps the combined throughput of every stream it wraps at the
+// configured number of bytes per second.
+type byteLimiter struct {
+	sync.Mutex
+	bytesPerSecond float64
+	next           time.Time
+}
+
+func newByteLimiter(bytesPerSecond float64) *byteLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	return &byteLimiter{bytesPerSecond: bytesPerSecond}
+}
+
+func (l *byteLimiter) Wrap(rc io.ReadCloser) io.ReadCloser {
+	if l == nil {
+		return rc
+	}
+
+	return limitedReadCloser{ReadCloser: rc, limiter: l}
+}
+
+// wait reserves the time it takes to transfer n bytes at the configured
+// rate after all earlier reservations and sleeps until it has passed.
+func (l *byteLimiter) wait(n int) {
+	l.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.bytesPerSecond * float64(time.Second)))
+	until := l.next
+	l.Unlock()
+
+	time.Sleep(until.Sub(now))
+}
+
+// chunk keeps individual reads small enough that throughput stays smooth.
+func (l *byteLimiter) chunk() int {
+	size := int(l.bytesPerSecond / 10)
+	if size < 1 {
+		size = 1
+	}
+
+	return size
+}
+
+type limitedReadCloser struct {
+	io.ReadCloser
+	limiter *byteLimiter
+}
+
+func (r limitedReadCloser) Read(p []byte) (int, error) {
+	if chunk := r.limiter.chunk(); len(p) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+
+	return n, err
+}
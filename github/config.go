@@ -1,11 +1,85 @@
 package github
 
+import (
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultEndpoint is the public GitHub API.
+const DefaultEndpoint = "https://api.github.com"
+
+// DefaultAPIVersion is the X-GitHub-Api-Version sent when Config does not
+// name one.
+const DefaultAPIVersion = "2022-11-28"
+
+// MaxPerPage is the largest page size the GitHub API accepts.
+const MaxPerPage = 100
+
+// DuplicateTagPolicy decides which release GetByTag returns when several
+// releases of a repository share a tag.
+type DuplicateTagPolicy string
+
+const (
+	// DuplicateTagsNewest picks the most recently published release.
+	DuplicateTagsNewest DuplicateTagPolicy = "newest"
+
+	// DuplicateTagsStrict returns an error instead of picking one.
+	DuplicateTagsStrict DuplicateTagPolicy = "strict"
+)
+
 type Config struct {
 	Endpoint string
 	Token    string
+
+	// APIVersion pins the X-GitHub-Api-Version of API requests. It defaults
+	// to DefaultAPIVersion.
+	APIVersion string
+
+	// DownloadsPerSecond limits asset and tarball downloads per host. Zero
+	// means unlimited.
+	DownloadsPerSecond float64
+
+	// BytesPerSecond caps the combined throughput of all asset and tarball
+	// downloads. Zero means unlimited.
+	BytesPerSecond float64
+
+	// DuplicateTags decides how GetByTag resolves a tag shared by several
+	// releases. It defaults to DuplicateTagsNewest.
+	DuplicateTags DuplicateTagPolicy
+
+	// TagFallback makes GetByTag fall back to the git tags of a repository
+	// when no release has the tag, for repositories that push tags without
+	// creating releases. The release it returns only has a tag name and a
+	// tarball url.
+	TagFallback bool
+
+	// PerPage is the page size requested when listing releases and tags.
+	// Every page is read, so a smaller size only means more requests. It
+	// defaults to, and is capped at, MaxPerPage.
+	PerPage int
+
+	// Proxy is the url of the proxy all requests are sent through. Userinfo
+	// in the url is sent as Proxy-Authorization. By default the proxy is
+	// taken from the HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string
+
+	// Timeout limits each request, including reading its body. Zero means no
+	// timeout.
+	Timeout time.Duration
+
+	// Client sends every request when it is set, for transports that Proxy
+	// and Timeout cannot describe. Proxy and Timeout are ignored then.
+	Client *http.Client
 }
 
+// NewConfig authenticates with token, or with the GITHUB_TOKEN environment
+// variable when token is empty. Without either requests are anonymous.
 func NewConfig(endpoint, token string) Config {
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+
 	return Config{
 		Endpoint: endpoint,
 		Token:    token,
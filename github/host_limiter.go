@@ -0,0 +1,53 @@
+package github
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hostLimiter spaces out requests to the same host so that no host sees
+// more than the configured number of requests per second.
+type hostLimiter struct {
+	sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func newHostLimiter(requestsPerSecond float64) *hostLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+
+	return &hostLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		next:     map[string]time.Time{},
+	}
+}
+
+// Wait blocks until the next request to host may be sent, or until ctx is
+// done.
+func (l *hostLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+
+	l.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval)
+	l.Unlock()
+
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
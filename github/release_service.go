@@ -1,34 +1,108 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ReleaseService struct {
-	config Config
+	config    Config
+	client    *http.Client
+	limiter   *hostLimiter
+	bandwidth *byteLimiter
 }
 
 type ReleaseAsset struct {
-	URL string `json:"url"`
+	URL                string `json:"url"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	Name               string `json:"name"`
+}
+
+// StatusError is returned when a download gets an unexpected response
+// status, so that callers can tell a missing file from other failures.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %s", e.Status)
+}
+
+// RateLimitError is returned when GitHub refuses an API request because the
+// rate limit is exhausted. Reset is when the limit is replenished.
+type RateLimitError struct {
+	Org       string
+	Repo      string
+	Remaining int
+	Reset     time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("GitHub API rate limit exceeded for %s/%s: %d requests remaining until %s", e.Org, e.Repo, e.Remaining, e.Reset.UTC().Format(time.RFC3339))
+}
+
+// Download is the body of an asset or tarball download.
+type Download struct {
+	io.ReadCloser
+
+	// Size is the Content-Length reported by the server, or -1 when it is
+	// unknown.
+	Size int64
 }
 
 type Release struct {
-	TagName    string         `json:"tag_name"`
-	Assets     []ReleaseAsset `json:"assets"`
-	TarballURL string         `json:"tarball_url"`
+	ID          int64          `json:"id"`
+	TagName     string         `json:"tag_name"`
+	Body        string         `json:"body"`
+	Assets      []ReleaseAsset `json:"assets"`
+	TarballURL  string         `json:"tarball_url"`
+	HTMLURL     string         `json:"html_url"`
+	Draft       bool           `json:"draft"`
+	Prerelease  bool           `json:"prerelease"`
+	PublishedAt time.Time      `json:"published_at"`
 }
 
 func NewReleaseService(config Config) ReleaseService {
 	return ReleaseService{
-		config: config,
+		config:    config,
+		client:    newHTTPClient(config),
+		limiter:   newHostLimiter(config.DownloadsPerSecond),
+		bandwidth: newByteLimiter(config.BytesPerSecond),
 	}
 }
 
-func (rs ReleaseService) Get(org, repo string) (Release, error) {
+// newHTTPClient returns the client of config, or http.DefaultClient unless
+// config asks for a proxy or a timeout.
+func newHTTPClient(config Config) *http.Client {
+	if config.Client != nil {
+		return config.Client
+	}
+
+	if config.Proxy == "" && config.Timeout == 0 {
+		return http.DefaultClient
+	}
+
+	client := &http.Client{Timeout: config.Timeout}
+	if config.Proxy != "" {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = func(*http.Request) (*url.URL, error) {
+			return url.Parse(config.Proxy)
+		}
+		client.Transport = transport
+	}
+
+	return client
+}
+
+func (rs ReleaseService) Get(ctx context.Context, org, repo string) (Release, error) {
 	uri, err := url.Parse(rs.config.Endpoint)
 	if err != nil {
 		return Release{}, err
@@ -36,20 +110,34 @@ func (rs ReleaseService) Get(org, repo string) (Release, error) {
 
 	uri.Path = fmt.Sprintf("/repos/%s/%s/releases/latest", org, repo)
 
-	req, err := http.NewRequest("GET", uri.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", uri.String(), nil)
 	if err != nil {
 		return Release{}, err
 	}
 
+	rs.setAPIVersion(req)
+
 	if rs.config.Token != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("token %s", rs.config.Token))
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := rs.client.Do(req)
+	if err != nil {
+		return Release{}, err
+	}
+	defer resp.Body.Close()
+
+	err = rateLimitError(resp, org, repo)
 	if err != nil {
 		return Release{}, err
 	}
 
+	// GitHub 404s on /releases/latest when a repo only has pre-releases, so
+	// fall back to the newest entry in the full release list
+	if resp.StatusCode == http.StatusNotFound {
+		return rs.newestRelease(ctx, org, repo)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return Release{}, fmt.Errorf("unexpected response status: %s", resp.Status)
 	}
@@ -63,8 +151,264 @@ func (rs ReleaseService) Get(org, repo string) (Release, error) {
 	return release, nil
 }
 
-func (rs ReleaseService) GetReleaseAsset(asset ReleaseAsset) (io.ReadCloser, error) {
-	req, err := http.NewRequest("GET", asset.URL, nil)
+func (rs ReleaseService) GetByID(ctx context.Context, org, repo string, id int64) (Release, error) {
+	var release Release
+	err := rs.getJSON(ctx, org, repo, fmt.Sprintf("/repos/%s/%s/releases/%d", org, repo, id), &release)
+	if err != nil {
+		return Release{}, err
+	}
+
+	return release, nil
+}
+
+// GetByTag returns the release tagged tag. Draft releases are ignored.
+func (rs ReleaseService) GetByTag(ctx context.Context, org, repo, tag string) (Release, error) {
+	releases, err := rs.ListReleases(ctx, org, repo)
+	if err != nil {
+		return Release{}, err
+	}
+
+	var matches []Release
+	for _, release := range releases {
+		if !release.Draft && release.TagName == tag {
+			matches = append(matches, release)
+		}
+	}
+
+	if len(matches) == 0 && rs.config.TagFallback {
+		return rs.releaseFromTag(ctx, org, repo, tag)
+	}
+
+	switch {
+	case len(matches) == 0:
+		return Release{}, fmt.Errorf("no release tagged %s found for %s/%s", tag, org, repo)
+	case len(matches) > 1 && rs.config.DuplicateTags == DuplicateTagsStrict:
+		return Release{}, fmt.Errorf("%d releases of %s/%s share the tag %s", len(matches), org, repo, tag)
+	}
+
+	// Ties on the publish date fall back to the higher id so that the choice
+	// does not depend on the order of the list
+	chosen := matches[0]
+	for _, release := range matches[1:] {
+		if release.PublishedAt.After(chosen.PublishedAt) || (release.PublishedAt.Equal(chosen.PublishedAt) && release.ID > chosen.ID) {
+			chosen = release
+		}
+	}
+
+	return chosen, nil
+}
+
+func (rs ReleaseService) releaseFromTag(ctx context.Context, org, repo, tag string) (Release, error) {
+	tags, err := rs.ListTags(ctx, org, repo)
+	if err != nil {
+		return Release{}, err
+	}
+
+	for _, t := range tags {
+		if t.TagName == tag {
+			return t, nil
+		}
+	}
+
+	return Release{}, fmt.Errorf("no release or tag %s found for %s/%s", tag, org, repo)
+}
+
+// ListTags lists the git tags of a repository, including those without a
+// release, as releases that only have a tag name and a tarball url.
+func (rs ReleaseService) ListTags(ctx context.Context, org, repo string) ([]Release, error) {
+	var tags []struct {
+		Name       string `json:"name"`
+		TarballURL string `json:"tarball_url"`
+	}
+	err := rs.getPagesJSON(ctx, org, repo, fmt.Sprintf("/repos/%s/%s/tags?per_page=%d", org, repo, rs.perPage()), &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	for _, t := range tags {
+		releases = append(releases, Release{
+			TagName:    t.Name,
+			TarballURL: t.TarballURL,
+		})
+	}
+
+	return releases, nil
+}
+
+func (rs ReleaseService) ListReleases(ctx context.Context, org, repo string) ([]Release, error) {
+	var releases []Release
+	err := rs.getPagesJSON(ctx, org, repo, fmt.Sprintf("/repos/%s/%s/releases?per_page=%d", org, repo, rs.perPage()), &releases)
+	if err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+func (rs ReleaseService) getJSON(ctx context.Context, org, repo, path string, v interface{}) error {
+	_, err := rs.getJSONPage(ctx, org, repo, path, v)
+	return err
+}
+
+// getPagesJSON decodes the items of every page of a list into v, following
+// the rel="next" links of the responses until the last page.
+func (rs ReleaseService) getPagesJSON(ctx context.Context, org, repo, path string, v interface{}) error {
+	items := []json.RawMessage{}
+	for path != "" {
+		var (
+			page []json.RawMessage
+			err  error
+		)
+
+		path, err = rs.getJSONPage(ctx, org, repo, path, &page)
+		if err != nil {
+			return err
+		}
+
+		items = append(items, page...)
+	}
+
+	content, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, v)
+}
+
+// getJSONPage decodes the response to path into v and returns the path of
+// the next page, if there is one. The host of the endpoint is kept for the
+// next page, only its path and query are taken from the link.
+func (rs ReleaseService) getJSONPage(ctx context.Context, org, repo, path string, v interface{}) (string, error) {
+	uri, err := url.Parse(rs.config.Endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+
+	uri.Path = ref.Path
+	uri.RawQuery = ref.RawQuery
+
+	req, err := http.NewRequestWithContext(ctx, "GET", uri.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	rs.setAPIVersion(req)
+
+	if rs.config.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", rs.config.Token))
+	}
+
+	resp, err := rs.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	err = rateLimitError(resp, org, repo)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return "", err
+	}
+
+	return nextLink(resp.Header.Get("Link")), nil
+}
+
+// nextLink returns the rel="next" url of a Link header, or an empty string
+// on the last page.
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+
+	return ""
+}
+
+// rateLimitError returns a RateLimitError when resp was refused because the
+// rate limit is exhausted.
+func rateLimitError(resp *http.Response, org, repo string) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return nil
+	}
+
+	rateLimitErr := &RateLimitError{Org: org, Repo: repo}
+
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err == nil {
+		rateLimitErr.Reset = time.Unix(reset, 0)
+	}
+
+	return rateLimitErr
+}
+
+func (rs ReleaseService) perPage() int {
+	if rs.config.PerPage <= 0 || rs.config.PerPage > MaxPerPage {
+		return MaxPerPage
+	}
+
+	return rs.config.PerPage
+}
+
+func (rs ReleaseService) setAPIVersion(req *http.Request) {
+	version := rs.config.APIVersion
+	if version == "" {
+		version = DefaultAPIVersion
+	}
+
+	req.Header.Set("X-GitHub-Api-Version", version)
+}
+
+func (rs ReleaseService) newestRelease(ctx context.Context, org, repo string) (Release, error) {
+	releases, err := rs.ListReleases(ctx, org, repo)
+	if err != nil {
+		return Release{}, err
+	}
+
+	var newest *Release
+	for i, release := range releases {
+		if release.Draft {
+			continue
+		}
+
+		if newest == nil || release.PublishedAt.After(newest.PublishedAt) {
+			newest = &releases[i]
+		}
+	}
+
+	if newest == nil {
+		return Release{}, fmt.Errorf("no published releases found for %s/%s", org, repo)
+	}
+
+	return *newest, nil
+}
+
+// GetReleaseAsset downloads an asset through its API url, which is the only
+// way to reach assets of private repositories.
+func (rs ReleaseService) GetReleaseAsset(ctx context.Context, asset ReleaseAsset) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", asset.URL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -74,37 +418,76 @@ func (rs ReleaseService) GetReleaseAsset(asset ReleaseAsset) (io.ReadCloser, err
 	}
 
 	req.Header.Add("Accept", "application/octet-stream")
+	rs.setAPIVersion(req)
 
-	resp, err := http.DefaultClient.Do(req)
+	err = rs.limiter.Wait(ctx, req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rs.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusFound {
-		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	err = checkRedirectedToHTML(req, resp)
+	if err != nil {
+		return nil, err
 	}
 
-	return resp.Body, nil
+	return &Download{ReadCloser: rs.bandwidth.Wrap(resp.Body), Size: resp.ContentLength}, nil
 }
 
-func (rs ReleaseService) GetReleaseTarball(url string) (io.ReadCloser, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (rs ReleaseService) GetReleaseTarball(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	rs.setAPIVersion(req)
+
 	if rs.config.Token != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("token %s", rs.config.Token))
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	err = rs.limiter.Wait(ctx, req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rs.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	err = checkRedirectedToHTML(req, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Download{ReadCloser: rs.bandwidth.Wrap(resp.Body), Size: resp.ContentLength}, nil
+}
+
+// checkRedirectedToHTML catches downloads of missing assets that GitHub
+// redirects to a release page, which would otherwise be saved as if the
+// page were the archive.
+func checkRedirectedToHTML(req *http.Request, resp *http.Response) error {
+	if resp.Request == nil || resp.Request.URL.String() == req.URL.String() {
+		return nil
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil
 	}
 
-	return resp.Body, nil
+	resp.Body.Close()
+	return fmt.Errorf("download of %s was redirected to an HTML page: %s", req.URL, resp.Request.URL)
 }
@@ -1,12 +1,20 @@
 package github_test
 
 import (
+	"bytes"
+	gocontext "context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ForestEckhardt/freezer/github"
 	"github.com/sclevine/spec"
@@ -18,6 +26,8 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 	var (
 		service github.ReleaseService
 		api     *httptest.Server
+
+		ctx = gocontext.Background()
 	)
 
 	context("Get", func() {
@@ -39,10 +49,42 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
       "url": "some-url"
     }
   ],
-  "tarball_url": "some-tarball-url"
+  "tarball_url": "some-tarball-url",
+  "published_at": "2022-03-01T12:00:00Z"
 					}`))
+				case "/repos/some-org/limited-repo/releases/latest":
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", "1700000000")
+					w.WriteHeader(http.StatusForbidden)
 				case "/repos/some-org/missing-repo/releases/latest":
 					w.WriteHeader(http.StatusNotFound)
+				case "/repos/some-org/missing-repo/releases":
+					w.WriteHeader(http.StatusNotFound)
+				case "/repos/some-org/prerelease-repo/releases/latest":
+					w.WriteHeader(http.StatusNotFound)
+				case "/repos/some-org/prerelease-repo/releases":
+					w.Write([]byte(`[
+  {
+    "tag_name": "some-draft-tag",
+    "draft": true,
+    "prerelease": true
+  },
+  {
+    "tag_name": "some-older-tag",
+    "prerelease": true,
+    "published_at": "2022-01-01T00:00:00Z"
+  },
+  {
+    "tag_name": "some-newer-tag",
+    "prerelease": true,
+    "published_at": "2022-02-01T00:00:00Z",
+    "tarball_url": "some-tarball-url"
+  }
+]`))
+				case "/repos/some-org/empty-repo/releases/latest":
+					w.WriteHeader(http.StatusNotFound)
+				case "/repos/some-org/empty-repo/releases":
+					w.Write([]byte(`[]`))
 				case "/repos/some-org/malformed-repo/releases/latest":
 					w.Write([]byte("%%%"))
 				default:
@@ -57,7 +99,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 		})
 
 		it("fetches the latest release", func() {
-			release, err := service.Get("some-org", "some-repo")
+			release, err := service.Get(ctx, "some-org", "some-repo")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(release).To(Equal(github.Release{
 				TagName: "some-tag",
@@ -66,10 +108,24 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 						URL: "some-url",
 					},
 				},
-				TarballURL: "some-tarball-url",
+				TarballURL:  "some-tarball-url",
+				PublishedAt: time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC),
 			}))
 		})
 
+		context("when the latest release endpoint 404s but the repo has releases", func() {
+			it("returns the newest published release from the release list", func() {
+				release, err := service.Get(ctx, "some-org", "prerelease-repo")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(release).To(Equal(github.Release{
+					TagName:     "some-newer-tag",
+					TarballURL:  "some-tarball-url",
+					Prerelease:  true,
+					PublishedAt: time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC),
+				}))
+			})
+		})
+
 		context("when no github token is specified", func() {
 			var authToken string
 			it.Before(func() {
@@ -101,7 +157,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 
 			})
 			it("makes the call without any authorization header", func() {
-				release, err := service.Get("some-org", "some-repo")
+				release, err := service.Get(ctx, "some-org", "some-repo")
 				Expect(err).ToNot(HaveOccurred())
 				Expect(release).To(Equal(github.Release{
 					TagName: "some-tag",
@@ -125,21 +181,402 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 				})
 
 				it("returns an error", func() {
-					_, err := service.Get("some-org", "some-repo")
+					_, err := service.Get(ctx, "some-org", "some-repo")
+					Expect(err).To(MatchError(ContainSubstring("invalid URL escape \"%%%\"")))
+				})
+			})
+
+			context("when the response status is not 200 OK", func() {
+				it("returns an error", func() {
+					_, err := service.Get(ctx, "some-org", "missing-repo")
+					Expect(err).To(MatchError("unexpected response status: 404 Not Found"))
+				})
+			})
+
+			context("when the rate limit is exhausted", func() {
+				it("returns a RateLimitError", func() {
+					_, err := service.Get(ctx, "some-org", "limited-repo")
+					Expect(err).To(MatchError("GitHub API rate limit exceeded for some-org/limited-repo: 0 requests remaining until 2023-11-14T22:13:20Z"))
+
+					var rateLimitErr *github.RateLimitError
+					Expect(errors.As(err, &rateLimitErr)).To(BeTrue())
+					Expect(rateLimitErr.Remaining).To(Equal(0))
+					Expect(rateLimitErr.Reset).To(Equal(time.Unix(1700000000, 0)))
+				})
+			})
+
+			context("when the context is cancelled", func() {
+				it("returns the context error", func() {
+					ctx, cancel := gocontext.WithCancel(gocontext.Background())
+					cancel()
+
+					_, err := service.Get(ctx, "some-org", "some-repo")
+					Expect(errors.Is(err, gocontext.Canceled)).To(BeTrue())
+				})
+			})
+
+			context("when the repo has no published releases", func() {
+				it("returns an error", func() {
+					_, err := service.Get(ctx, "some-org", "empty-repo")
+					Expect(err).To(MatchError("no published releases found for some-org/empty-repo"))
+				})
+			})
+
+			context("when the response JSON is malformed", func() {
+				it("returns an error", func() {
+					_, err := service.Get(ctx, "some-org", "malformed-repo")
+					Expect(err).To(MatchError(ContainSubstring("invalid character '%'")))
+				})
+			})
+		})
+	})
+
+	context("GetByID", func() {
+		it.Before(func() {
+			api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				dump, _ := httputil.DumpRequest(req, true)
+
+				if req.Header.Get("Authorization") != "token some-github-token" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				switch req.URL.Path {
+				case "/repos/some-org/some-repo/releases/12345":
+					w.Write([]byte(`{
+  "id": 12345,
+  "tag_name": "some-tag",
+  "assets": [
+    {
+      "url": "some-url"
+    }
+  ],
+  "tarball_url": "some-tarball-url"
+}`))
+				case "/repos/some-org/some-repo/releases/404":
+					w.WriteHeader(http.StatusNotFound)
+				case "/repos/some-org/some-repo/releases/429":
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", "1700000000")
+					w.WriteHeader(http.StatusTooManyRequests)
+				default:
+					Fail(fmt.Sprintf("unexpected request:\n%s", dump))
+				}
+			}))
+
+			service = github.NewReleaseService(github.Config{
+				Endpoint: api.URL,
+				Token:    "some-github-token",
+			})
+		})
+
+		it("fetches the release with the given id", func() {
+			release, err := service.GetByID(ctx, "some-org", "some-repo", 12345)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(release).To(Equal(github.Release{
+				ID:      12345,
+				TagName: "some-tag",
+				Assets: []github.ReleaseAsset{
+					{
+						URL: "some-url",
+					},
+				},
+				TarballURL: "some-tarball-url",
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when the release does not exist", func() {
+				it("returns an error", func() {
+					_, err := service.GetByID(ctx, "some-org", "some-repo", 404)
+					Expect(err).To(MatchError("unexpected response status: 404 Not Found"))
+				})
+			})
+
+			context("when the rate limit is exhausted", func() {
+				it("returns a RateLimitError", func() {
+					_, err := service.GetByID(ctx, "some-org", "some-repo", 429)
+
+					var rateLimitErr *github.RateLimitError
+					Expect(errors.As(err, &rateLimitErr)).To(BeTrue())
+					Expect(rateLimitErr.Org).To(Equal("some-org"))
+					Expect(rateLimitErr.Repo).To(Equal("some-repo"))
+				})
+			})
+		})
+	})
+
+	context("GetByTag", func() {
+		it.Before(func() {
+			api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				dump, _ := httputil.DumpRequest(req, true)
+
+				switch req.URL.Path {
+				case "/repos/some-org/some-repo/releases":
+					w.Write([]byte(`[
+  {
+    "id": 1,
+    "tag_name": "some-tag",
+    "published_at": "2022-01-01T00:00:00Z"
+  },
+  {
+    "id": 2,
+    "tag_name": "some-tag",
+    "published_at": "2022-02-01T00:00:00Z"
+  },
+  {
+    "id": 3,
+    "tag_name": "some-tag",
+    "draft": true
+  },
+  {
+    "id": 4,
+    "tag_name": "some-other-tag",
+    "published_at": "2022-03-01T00:00:00Z"
+  }
+]`))
+				case "/repos/some-org/some-repo/tags":
+					if req.URL.Query().Get("page") == "2" {
+						w.Write([]byte(`[
+  {
+    "name": "some-unreleased-tag",
+    "tarball_url": "some-unreleased-tarball-url"
+  }
+]`))
+						return
+					}
+
+					w.Header().Set("Link", fmt.Sprintf(`<%s/repos/some-org/some-repo/tags?page=2>; rel="next"`, api.URL))
+					w.Write([]byte(`[
+  {
+    "name": "some-other-tag",
+    "tarball_url": "some-other-tarball-url"
+  }
+]`))
+				default:
+					Fail(fmt.Sprintf("unexpected request:\n%s", dump))
+				}
+			}))
+
+			service = github.NewReleaseService(github.Config{
+				Endpoint: api.URL,
+			})
+		})
+
+		it("returns the release with the tag", func() {
+			release, err := service.GetByTag(ctx, "some-org", "some-repo", "some-other-tag")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(release.ID).To(Equal(int64(4)))
+		})
+
+		context("when several releases share the tag", func() {
+			it("returns the newest published one", func() {
+				release, err := service.GetByTag(ctx, "some-org", "some-repo", "some-tag")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(release).To(Equal(github.Release{
+					ID:          2,
+					TagName:     "some-tag",
+					PublishedAt: time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC),
+				}))
+			})
+
+			context("when duplicate tags are strict", func() {
+				it.Before(func() {
+					service = github.NewReleaseService(github.Config{
+						Endpoint:      api.URL,
+						DuplicateTags: github.DuplicateTagsStrict,
+					})
+				})
+
+				it("returns an error", func() {
+					_, err := service.GetByTag(ctx, "some-org", "some-repo", "some-tag")
+					Expect(err).To(MatchError("2 releases of some-org/some-repo share the tag some-tag"))
+				})
+
+				it("still returns a release with a unique tag", func() {
+					release, err := service.GetByTag(ctx, "some-org", "some-repo", "some-other-tag")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(release.ID).To(Equal(int64(4)))
+				})
+			})
+		})
+
+		context("when no release has the tag", func() {
+			it("returns an error", func() {
+				_, err := service.GetByTag(ctx, "some-org", "some-repo", "some-unreleased-tag")
+				Expect(err).To(MatchError("no release tagged some-unreleased-tag found for some-org/some-repo"))
+			})
+
+			context("when the tag fallback is enabled", func() {
+				it.Before(func() {
+					service = github.NewReleaseService(github.Config{
+						Endpoint:    api.URL,
+						TagFallback: true,
+					})
+				})
+
+				it("returns a release built from the git tag", func() {
+					release, err := service.GetByTag(ctx, "some-org", "some-repo", "some-unreleased-tag")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(release).To(Equal(github.Release{
+						TagName:    "some-unreleased-tag",
+						TarballURL: "some-unreleased-tarball-url",
+					}))
+				})
+
+				it("lists the tags of every page", func() {
+					tags, err := service.ListTags(ctx, "some-org", "some-repo")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(tags).To(Equal([]github.Release{
+						{TagName: "some-other-tag", TarballURL: "some-other-tarball-url"},
+						{TagName: "some-unreleased-tag", TarballURL: "some-unreleased-tarball-url"},
+					}))
+				})
+
+				it("returns an error when the tag does not exist either", func() {
+					_, err := service.GetByTag(ctx, "some-org", "some-repo", "missing-tag")
+					Expect(err).To(MatchError("no release or tag missing-tag found for some-org/some-repo"))
+				})
+			})
+		})
+	})
+
+	context("ListReleases", func() {
+		var perPage string
+
+		it.Before(func() {
+			api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				dump, _ := httputil.DumpRequest(req, true)
+
+				if req.Header.Get("Authorization") != "token some-github-token" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				perPage = req.URL.Query().Get("per_page")
+
+				switch req.URL.Path {
+				case "/repos/some-org/some-repo/releases":
+					w.Write([]byte(`[
+  {
+    "tag_name": "some-tag",
+    "assets": [
+      {
+        "url": "some-url"
+      }
+    ],
+    "tarball_url": "some-tarball-url"
+  },
+  {
+    "tag_name": "some-other-tag",
+    "draft": true
+  }
+]`))
+				case "/repos/some-org/paged-repo/releases":
+					if req.URL.Query().Get("page") == "2" {
+						w.Write([]byte(`[{"tag_name": "some-older-tag"}]`))
+						return
+					}
+
+					w.Header().Set("Link", fmt.Sprintf(`<%s/repos/some-org/paged-repo/releases?per_page=%s&page=2>; rel="next", <%s/repos/some-org/paged-repo/releases?per_page=%s&page=2>; rel="last"`, api.URL, perPage, api.URL, perPage))
+					w.Write([]byte(`[{"tag_name": "some-newer-tag"}]`))
+				case "/repos/some-org/missing-repo/releases":
+					w.WriteHeader(http.StatusNotFound)
+				case "/repos/some-org/malformed-repo/releases":
+					w.Write([]byte("%%%"))
+				default:
+					Fail(fmt.Sprintf("unexpected request:\n%s", dump))
+				}
+			}))
+
+			service = github.NewReleaseService(github.Config{
+				Endpoint: api.URL,
+				Token:    "some-github-token",
+			})
+		})
+
+		it("lists every release", func() {
+			releases, err := service.ListReleases(ctx, "some-org", "some-repo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(perPage).To(Equal("100"))
+			Expect(releases).To(Equal([]github.Release{
+				{
+					TagName: "some-tag",
+					Assets: []github.ReleaseAsset{
+						{
+							URL: "some-url",
+						},
+					},
+					TarballURL: "some-tarball-url",
+				},
+				{
+					TagName: "some-other-tag",
+					Draft:   true,
+				},
+			}))
+		})
+
+		context("when the releases span several pages", func() {
+			it("follows the next links to the last page", func() {
+				releases, err := service.ListReleases(ctx, "some-org", "paged-repo")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(releases).To(Equal([]github.Release{
+					{TagName: "some-newer-tag"},
+					{TagName: "some-older-tag"},
+				}))
+			})
+		})
+
+		context("when a page size is configured", func() {
+			it("requests pages of that size", func() {
+				service = github.NewReleaseService(github.Config{
+					Endpoint: api.URL,
+					Token:    "some-github-token",
+					PerPage:  30,
+				})
+
+				_, err := service.ListReleases(ctx, "some-org", "some-repo")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(perPage).To(Equal("30"))
+			})
+
+			it("caps it at the maximum GitHub accepts", func() {
+				service = github.NewReleaseService(github.Config{
+					Endpoint: api.URL,
+					Token:    "some-github-token",
+					PerPage:  500,
+				})
+
+				_, err := service.ListReleases(ctx, "some-org", "some-repo")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(perPage).To(Equal("100"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the request url is malformed", func() {
+				it.Before(func() {
+					service = github.NewReleaseService(github.Config{
+						Endpoint: "%%%",
+					})
+				})
+
+				it("returns an error", func() {
+					_, err := service.ListReleases(ctx, "some-org", "some-repo")
 					Expect(err).To(MatchError(ContainSubstring("invalid URL escape \"%%%\"")))
 				})
 			})
 
 			context("when the response status is not 200 OK", func() {
 				it("returns an error", func() {
-					_, err := service.Get("some-org", "missing-repo")
+					_, err := service.ListReleases(ctx, "some-org", "missing-repo")
 					Expect(err).To(MatchError("unexpected response status: 404 Not Found"))
 				})
 			})
 
 			context("when the response JSON is malformed", func() {
 				it("returns an error", func() {
-					_, err := service.Get("some-org", "malformed-repo")
+					_, err := service.ListReleases(ctx, "some-org", "malformed-repo")
 					Expect(err).To(MatchError(ContainSubstring("invalid character '%'")))
 				})
 			})
@@ -173,7 +610,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 		})
 
 		it("fetches the latest release", func() {
-			response, err := service.GetReleaseAsset(github.ReleaseAsset{
+			response, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{
 				URL: fmt.Sprintf("%s/some-url", api.URL),
 			})
 			Expect(err).ToNot(HaveOccurred())
@@ -182,7 +619,35 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(string(content)).To(Equal("some-asset"))
 
+			Expect(response).To(BeAssignableToTypeOf(&github.Download{}))
+			Expect(response.(*github.Download).Size).To(Equal(int64(len("some-asset"))))
+
+			Expect(response.Close()).To(Succeed())
+		})
+
+		it("requests the asset from its API url as an octet-stream", func() {
+			var path, accept string
+			api.Close()
+			api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				path = req.URL.Path
+				accept = req.Header.Get("Accept")
+				w.Write([]byte(`some-asset`))
+			}))
+
+			service = github.NewReleaseService(github.Config{
+				Endpoint: api.URL,
+				Token:    "some-github-token",
+			})
+
+			response, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{
+				URL:                fmt.Sprintf("%s/some-api-url", api.URL),
+				BrowserDownloadURL: fmt.Sprintf("%s/some-browser-url", api.URL),
+			})
+			Expect(err).ToNot(HaveOccurred())
 			Expect(response.Close()).To(Succeed())
+
+			Expect(path).To(Equal("/some-api-url"))
+			Expect(accept).To(Equal("application/octet-stream"))
 		})
 
 		context("when no github token is specified", func() {
@@ -209,7 +674,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 
 			})
 			it("fetches the latest release without any authorization header", func() {
-				response, err := service.GetReleaseAsset(github.ReleaseAsset{
+				response, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{
 					URL: fmt.Sprintf("%s/some-url", api.URL),
 				})
 				Expect(err).ToNot(HaveOccurred())
@@ -226,7 +691,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 		context("failure cases", func() {
 			context("when the url is malformed", func() {
 				it("returns an error", func() {
-					_, err := service.GetReleaseAsset(github.ReleaseAsset{
+					_, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{
 						URL: "%%%",
 					})
 					Expect(err).To(MatchError(ContainSubstring("invalid URL escape")))
@@ -239,7 +704,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 				})
 
 				it("returns an error", func() {
-					_, err := service.GetReleaseAsset(github.ReleaseAsset{
+					_, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{
 						URL: fmt.Sprintf("%s/some-url", api.URL),
 					})
 					Expect(err).To(MatchError(ContainSubstring("connection refused")))
@@ -248,7 +713,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 
 			context("when the status code is not ok", func() {
 				it("returns an error", func() {
-					_, err := service.GetReleaseAsset(github.ReleaseAsset{
+					_, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{
 						URL: fmt.Sprintf("%s/not-found", api.URL),
 					})
 					Expect(err).To(MatchError(ContainSubstring("unexpected response status")))
@@ -284,7 +749,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 		})
 
 		it("fetches the latest release", func() {
-			response, err := service.GetReleaseTarball(fmt.Sprintf("%s/some-tarball-url", api.URL))
+			response, err := service.GetReleaseTarball(ctx, fmt.Sprintf("%s/some-tarball-url", api.URL))
 			Expect(err).ToNot(HaveOccurred())
 
 			content, err := io.ReadAll(response)
@@ -318,7 +783,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 
 			})
 			it("fetches the latest release tarball without any authorization header", func() {
-				response, err := service.GetReleaseTarball(fmt.Sprintf("%s/some-tarball-url", api.URL))
+				response, err := service.GetReleaseTarball(ctx, fmt.Sprintf("%s/some-tarball-url", api.URL))
 				Expect(err).ToNot(HaveOccurred())
 
 				content, err := io.ReadAll(response)
@@ -333,7 +798,7 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 		context("failure cases", func() {
 			context("when the url is malformed", func() {
 				it("returns an error", func() {
-					_, err := service.GetReleaseTarball("%%%")
+					_, err := service.GetReleaseTarball(ctx, "%%%")
 					Expect(err).To(MatchError(ContainSubstring("invalid URL escape")))
 				})
 			})
@@ -344,17 +809,359 @@ func testReleaseService(t *testing.T, context spec.G, it spec.S) {
 				})
 
 				it("returns an error", func() {
-					_, err := service.GetReleaseTarball(fmt.Sprintf("%s/some-url", api.URL))
+					_, err := service.GetReleaseTarball(ctx, fmt.Sprintf("%s/some-url", api.URL))
 					Expect(err).To(MatchError(ContainSubstring("connection refused")))
 				})
 			})
 
 			context("when the status code is not ok", func() {
 				it("returns an error", func() {
-					_, err := service.GetReleaseTarball(fmt.Sprintf("%s/not-found", api.URL))
-					Expect(err).To(MatchError(ContainSubstring("unexpected response status")))
+					_, err := service.GetReleaseTarball(ctx, fmt.Sprintf("%s/not-found", api.URL))
+					Expect(err).To(MatchError("unexpected response status: 403 Forbidden"))
+
+					var statusErr *github.StatusError
+					Expect(errors.As(err, &statusErr)).To(BeTrue())
+					Expect(statusErr.StatusCode).To(Equal(http.StatusForbidden))
 				})
 			})
 		})
 	})
+
+	context("when downloads are rate limited per host", func() {
+		var (
+			mutex    sync.Mutex
+			requests []time.Time
+		)
+
+		it.Before(func() {
+			requests = nil
+
+			api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				mutex.Lock()
+				requests = append(requests, time.Now())
+				mutex.Unlock()
+
+				w.Write([]byte(`some-content`))
+			}))
+
+			service = github.NewReleaseService(github.Config{
+				Endpoint:           api.URL,
+				DownloadsPerSecond: 10,
+			})
+		})
+
+		it("spaces out downloads to the same host", func() {
+			for i := 0; i < 3; i++ {
+				response, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{URL: fmt.Sprintf("%s/some-url", api.URL)})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.Close()).To(Succeed())
+
+				response, err = service.GetReleaseTarball(ctx, fmt.Sprintf("%s/some-tarball-url", api.URL))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.Close()).To(Succeed())
+			}
+
+			Expect(requests).To(HaveLen(6))
+			for i := 1; i < len(requests); i++ {
+				Expect(requests[i].Sub(requests[i-1])).To(BeNumerically(">=", 90*time.Millisecond))
+			}
+		})
+
+		context("when the context is done while waiting for the host", func() {
+			it("returns the context error without sending the request", func() {
+				response, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{URL: fmt.Sprintf("%s/some-url", api.URL)})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(response.Close()).To(Succeed())
+
+				timeout, cancel := gocontext.WithTimeout(ctx, 10*time.Millisecond)
+				defer cancel()
+
+				_, err = service.GetReleaseTarball(timeout, fmt.Sprintf("%s/some-tarball-url", api.URL))
+				Expect(errors.Is(err, gocontext.DeadlineExceeded)).To(BeTrue())
+				Expect(requests).To(HaveLen(1))
+			})
+		})
+	})
+
+	context("when download bandwidth is capped", func() {
+		it.Before(func() {
+			api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write(bytes.Repeat([]byte("x"), 2000))
+			}))
+
+			service = github.NewReleaseService(github.Config{
+				Endpoint:       api.URL,
+				BytesPerSecond: 10000,
+			})
+		})
+
+		it("keeps the combined throughput of concurrent downloads under the cap", func() {
+			start := time.Now()
+
+			var wg sync.WaitGroup
+			sizes := make([]int, 2)
+			errs := make([]error, 2)
+			download := func(i int, get func() (io.ReadCloser, error)) {
+				defer wg.Done()
+
+				response, err := get()
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				defer response.Close()
+
+				content, err := io.ReadAll(response)
+				sizes[i], errs[i] = len(content), err
+			}
+
+			wg.Add(2)
+			go download(0, func() (io.ReadCloser, error) {
+				return service.GetReleaseAsset(ctx, github.ReleaseAsset{URL: fmt.Sprintf("%s/some-url", api.URL)})
+			})
+			go download(1, func() (io.ReadCloser, error) {
+				return service.GetReleaseTarball(ctx, fmt.Sprintf("%s/some-tarball-url", api.URL))
+			})
+			wg.Wait()
+
+			Expect(errs).To(Equal([]error{nil, nil}))
+			Expect(sizes).To(Equal([]int{2000, 2000}))
+
+			// 4000 bytes at 10000 bytes per second cannot take less than 400ms
+			Expect(time.Since(start)).To(BeNumerically(">=", 380*time.Millisecond))
+		})
+	})
+
+	context("when sending API requests", func() {
+		var versions []string
+
+		it.Before(func() {
+			versions = nil
+
+			api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				versions = append(versions, req.Header.Get("X-GitHub-Api-Version"))
+
+				switch req.URL.Path {
+				case "/repos/some-org/some-repo/releases/latest", "/repos/some-org/some-repo/releases/12345":
+					w.Write([]byte(`{"tag_name": "some-tag"}`))
+				default:
+					w.Write([]byte(`some-content`))
+				}
+			}))
+		})
+
+		it("pins the default API version", func() {
+			service = github.NewReleaseService(github.NewConfig(api.URL, "some-github-token"))
+
+			_, err := service.Get(ctx, "some-org", "some-repo")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = service.GetByID(ctx, "some-org", "some-repo", 12345)
+			Expect(err).ToNot(HaveOccurred())
+
+			response, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{URL: fmt.Sprintf("%s/some-url", api.URL)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.Close()).To(Succeed())
+
+			response, err = service.GetReleaseTarball(ctx, fmt.Sprintf("%s/some-tarball-url", api.URL))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.Close()).To(Succeed())
+
+			Expect(versions).To(Equal([]string{"2022-11-28", "2022-11-28", "2022-11-28", "2022-11-28"}))
+		})
+
+		it("sends the configured API version", func() {
+			config := github.NewConfig(api.URL, "some-github-token")
+			config.APIVersion = "some-api-version"
+			service = github.NewReleaseService(config)
+
+			_, err := service.Get(ctx, "some-org", "some-repo")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(versions).To(Equal([]string{"some-api-version"}))
+		})
+	})
+
+	context("when authenticating", func() {
+		var (
+			authorizations []string
+			githubToken    string
+			hadToken       bool
+		)
+
+		it.Before(func() {
+			authorizations = nil
+
+			api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				authorizations = append(authorizations, req.Header.Get("Authorization"))
+
+				switch req.URL.Path {
+				case "/repos/some-org/some-repo/releases/latest":
+					w.Write([]byte(`{"tag_name": "some-tag"}`))
+				default:
+					w.Write([]byte(`some-content`))
+				}
+			}))
+
+			githubToken, hadToken = os.LookupEnv("GITHUB_TOKEN")
+			Expect(os.Unsetenv("GITHUB_TOKEN")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("GITHUB_TOKEN")).To(Succeed())
+			if hadToken {
+				Expect(os.Setenv("GITHUB_TOKEN", githubToken)).To(Succeed())
+			}
+		})
+
+		it("sends the GITHUB_TOKEN when no token is given", func() {
+			Expect(os.Setenv("GITHUB_TOKEN", "some-env-token")).To(Succeed())
+			service = github.NewReleaseService(github.NewConfig(api.URL, ""))
+
+			_, err := service.Get(ctx, "some-org", "some-repo")
+			Expect(err).ToNot(HaveOccurred())
+
+			response, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{URL: fmt.Sprintf("%s/some-url", api.URL)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.Close()).To(Succeed())
+
+			response, err = service.GetReleaseTarball(ctx, fmt.Sprintf("%s/some-tarball-url", api.URL))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.Close()).To(Succeed())
+
+			Expect(authorizations).To(Equal([]string{"token some-env-token", "token some-env-token", "token some-env-token"}))
+		})
+
+		it("prefers the token it is given", func() {
+			Expect(os.Setenv("GITHUB_TOKEN", "some-env-token")).To(Succeed())
+			service = github.NewReleaseService(github.NewConfig(api.URL, "some-github-token"))
+
+			_, err := service.Get(ctx, "some-org", "some-repo")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(authorizations).To(Equal([]string{"token some-github-token"}))
+		})
+
+		it("stays anonymous without any token", func() {
+			service = github.NewReleaseService(github.NewConfig(api.URL, ""))
+
+			_, err := service.Get(ctx, "some-org", "some-repo")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(authorizations).To(Equal([]string{""}))
+		})
+	})
+
+	context("when sending requests through a proxy", func() {
+		var (
+			proxy          *httptest.Server
+			requests       []string
+			authorizations []string
+		)
+
+		it.Before(func() {
+			requests = nil
+			authorizations = nil
+
+			proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requests = append(requests, req.URL.String())
+				authorizations = append(authorizations, req.Header.Get("Proxy-Authorization"))
+
+				switch req.URL.Path {
+				case "/repos/some-org/some-repo/releases/latest":
+					w.Write([]byte(`{"tag_name": "some-tag"}`))
+				default:
+					w.Write([]byte(`some-content`))
+				}
+			}))
+		})
+
+		it.After(func() {
+			proxy.Close()
+		})
+
+		it("routes API requests and downloads through the proxy", func() {
+			proxyURL, err := url.Parse(proxy.URL)
+			Expect(err).ToNot(HaveOccurred())
+			proxyURL.User = url.UserPassword("some-user", "some-password")
+
+			service = github.NewReleaseService(github.Config{
+				Endpoint: "http://github.example.com",
+				Proxy:    proxyURL.String(),
+			})
+
+			release, err := service.Get(ctx, "some-org", "some-repo")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(release.TagName).To(Equal("some-tag"))
+
+			response, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{URL: "http://github.example.com/some-url"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.Close()).To(Succeed())
+
+			basic := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("some-user:some-password")))
+			Expect(requests).To(Equal([]string{"http://github.example.com/repos/some-org/some-repo/releases/latest", "http://github.example.com/some-url"}))
+			Expect(authorizations).To(Equal([]string{basic, basic}))
+		})
+
+		it("sends requests with the configured client", func() {
+			proxyURL, err := url.Parse(proxy.URL)
+			Expect(err).ToNot(HaveOccurred())
+
+			service = github.NewReleaseService(github.Config{
+				Endpoint: "http://github.example.com",
+				Proxy:    "http://some-ignored-proxy.example.com",
+				Client:   &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}},
+			})
+
+			response, err := service.GetReleaseTarball(ctx, "http://github.example.com/some-tarball-url")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.Close()).To(Succeed())
+
+			Expect(requests).To(Equal([]string{"http://github.example.com/some-tarball-url"}))
+			Expect(authorizations).To(Equal([]string{""}))
+		})
+	})
+
+	context("when a download is redirected to an HTML page", func() {
+		it.Before(func() {
+			api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/some-url", "/some-tarball-url":
+					http.Redirect(w, req, "/some-org/some-repo/releases/tag/some-tag", http.StatusFound)
+				case "/some-redirected-url":
+					http.Redirect(w, req, "/some-archive", http.StatusFound)
+				case "/some-archive":
+					w.Header().Set("Content-Type", "application/octet-stream")
+					w.Write([]byte(`some-archive`))
+				default:
+					w.Header().Set("Content-Type", "text/html; charset=utf-8")
+					w.Write([]byte(`<html><body>some-release-page</body></html>`))
+				}
+			}))
+
+			service = github.NewReleaseService(github.Config{
+				Endpoint: api.URL,
+			})
+		})
+
+		it("returns an error for assets", func() {
+			_, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{URL: fmt.Sprintf("%s/some-url", api.URL)})
+			Expect(err).To(MatchError(fmt.Sprintf("download of %s/some-url was redirected to an HTML page: %s/some-org/some-repo/releases/tag/some-tag", api.URL, api.URL)))
+		})
+
+		it("returns an error for tarballs", func() {
+			_, err := service.GetReleaseTarball(ctx, fmt.Sprintf("%s/some-tarball-url", api.URL))
+			Expect(err).To(MatchError(ContainSubstring("was redirected to an HTML page")))
+		})
+
+		it("follows redirects to archives", func() {
+			response, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{URL: fmt.Sprintf("%s/some-redirected-url", api.URL)})
+			Expect(err).ToNot(HaveOccurred())
+
+			content, err := io.ReadAll(response)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(Equal("some-archive"))
+			Expect(response.Close()).To(Succeed())
+		})
+	})
 }
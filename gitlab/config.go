@@ -0,0 +1,39 @@
+package gitlab
+
+import (
+	"os"
+	"time"
+)
+
+// DefaultEndpoint is the API of gitlab.com. Self-hosted instances are
+// reached through their own base url, such as https://gitlab.example.com.
+const DefaultEndpoint = "https://gitlab.com"
+
+// PerPage is the page size requested when listing releases, which is the
+// largest the GitLab API accepts.
+const PerPage = 100
+
+type Config struct {
+	// Endpoint is the base url of the GitLab instance, without /api/v4.
+	Endpoint string
+
+	// Token is sent as the PRIVATE-TOKEN of every request when set.
+	Token string
+
+	// Timeout limits each request, including reading its body. Zero means no
+	// timeout.
+	Timeout time.Duration
+}
+
+// NewConfig authenticates with token, or with the GITLAB_TOKEN environment
+// variable when token is empty. Without either requests are anonymous.
+func NewConfig(endpoint, token string) Config {
+	if token == "" {
+		token = os.Getenv("GITLAB_TOKEN")
+	}
+
+	return Config{
+		Endpoint: endpoint,
+		Token:    token,
+	}
+}
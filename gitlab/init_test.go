@@ -0,0 +1,25 @@
+package gitlab_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestGitlab(t *testing.T) {
+	suite := spec.New("gitlab", spec.Report(report.Terminal{}))
+	suite("ReleaseService", testReleaseService)
+
+	suite.Before(func(t *testing.T) {
+		RegisterTestingT(t)
+	})
+
+	suite.Run(t)
+}
+
+func Fail(message string) {
+	panic(message)
+}
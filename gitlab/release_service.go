@@ -0,0 +1,215 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ForestEckhardt/freezer/github"
+)
+
+// ReleaseService reads the releases of GitLab projects and maps them onto
+// the github release types, so that it can stand in for a
+// github.ReleaseService. The org and repo of a buildpack are the namespace
+// and name of its project.
+type ReleaseService struct {
+	config Config
+	client *http.Client
+}
+
+type release struct {
+	TagName     string    `json:"tag_name"`
+	Description string    `json:"description"`
+	ReleasedAt  time.Time `json:"released_at"`
+	Upcoming    bool      `json:"upcoming_release"`
+	Assets      struct {
+		Links []struct {
+			Name           string `json:"name"`
+			URL            string `json:"url"`
+			DirectAssetURL string `json:"direct_asset_url"`
+		} `json:"links"`
+		Sources []struct {
+			Format string `json:"format"`
+			URL    string `json:"url"`
+		} `json:"sources"`
+	} `json:"assets"`
+	Links struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+}
+
+func NewReleaseService(config Config) ReleaseService {
+	client := http.DefaultClient
+	if config.Timeout != 0 {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+
+	return ReleaseService{
+		config: config,
+		client: client,
+	}
+}
+
+// Get returns the most recently released release of a project. Upcoming
+// releases are skipped.
+func (rs ReleaseService) Get(ctx context.Context, org, repo string) (github.Release, error) {
+	releases, err := rs.ListReleases(ctx, org, repo)
+	if err != nil {
+		return github.Release{}, err
+	}
+
+	var latest *github.Release
+	for i, release := range releases {
+		if release.Prerelease {
+			continue
+		}
+
+		if latest == nil || release.PublishedAt.After(latest.PublishedAt) {
+			latest = &releases[i]
+		}
+	}
+
+	if latest == nil {
+		return github.Release{}, fmt.Errorf("no published releases found for %s/%s", org, repo)
+	}
+
+	return *latest, nil
+}
+
+// GetByID is not supported because GitLab identifies releases by their tag.
+func (rs ReleaseService) GetByID(ctx context.Context, org, repo string, id int64) (github.Release, error) {
+	return github.Release{}, fmt.Errorf("cannot get release %d of %s/%s: GitLab releases have no id, pin a tag instead", id, org, repo)
+}
+
+// ListReleases lists the releases of a project, newest first. Upcoming
+// releases are reported as pre-releases.
+func (rs ReleaseService) ListReleases(ctx context.Context, org, repo string) ([]github.Release, error) {
+	uri := fmt.Sprintf("%s/api/v4/projects/%s/releases?per_page=%d", strings.TrimSuffix(rs.config.Endpoint, "/"), url.PathEscape(fmt.Sprintf("%s/%s", org, repo)), PerPage)
+
+	releases := []github.Release{}
+	for uri != "" {
+		var (
+			page []release
+			err  error
+		)
+
+		page, uri, err = rs.listPage(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range page {
+			releases = append(releases, r.toGithub())
+		}
+	}
+
+	return releases, nil
+}
+
+// listPage returns the releases on the page at uri and the url of the next
+// page, which is empty on the last one.
+func (rs ReleaseService) listPage(ctx context.Context, uri string) ([]release, string, error) {
+	resp, err := rs.get(ctx, uri)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	var releases []release
+	err = json.NewDecoder(resp.Body).Decode(&releases)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return releases, nextLink(resp.Header.Get("Link")), nil
+}
+
+// nextLink returns the rel="next" url of a Link header, or an empty string
+// on the last page.
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+
+	return ""
+}
+
+func (rs ReleaseService) GetReleaseAsset(ctx context.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+	return rs.download(ctx, asset.URL)
+}
+
+func (rs ReleaseService) GetReleaseTarball(ctx context.Context, url string) (io.ReadCloser, error) {
+	return rs.download(ctx, url)
+}
+
+func (rs ReleaseService) download(ctx context.Context, uri string) (io.ReadCloser, error) {
+	resp, err := rs.get(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &github.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return &github.Download{ReadCloser: resp.Body, Size: resp.ContentLength}, nil
+}
+
+func (rs ReleaseService) get(ctx context.Context, uri string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs.config.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", rs.config.Token)
+	}
+
+	return rs.client.Do(req)
+}
+
+func (r release) toGithub() github.Release {
+	converted := github.Release{
+		TagName:     r.TagName,
+		Body:        r.Description,
+		HTMLURL:     r.Links.Self,
+		Prerelease:  r.Upcoming,
+		PublishedAt: r.ReleasedAt,
+	}
+
+	for _, link := range r.Assets.Links {
+		uri := link.DirectAssetURL
+		if uri == "" {
+			uri = link.URL
+		}
+
+		converted.Assets = append(converted.Assets, github.ReleaseAsset{
+			URL:                uri,
+			BrowserDownloadURL: link.URL,
+			Name:               link.Name,
+		})
+	}
+
+	for _, source := range r.Assets.Sources {
+		if source.Format == "tar.gz" {
+			converted.TarballURL = source.URL
+		}
+	}
+
+	return converted
+}
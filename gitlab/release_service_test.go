@@ -0,0 +1,185 @@
+package gitlab_test
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"testing"
+	"time"
+
+	"github.com/ForestEckhardt/freezer/github"
+	"github.com/ForestEckhardt/freezer/gitlab"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testReleaseService(t *testing.T, context spec.G, it spec.S) {
+	var (
+		service gitlab.ReleaseService
+		api     *httptest.Server
+
+		ctx = gocontext.Background()
+	)
+
+	it.Before(func() {
+		api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			dump, _ := httputil.DumpRequest(req, true)
+
+			if req.Header.Get("PRIVATE-TOKEN") != "some-gitlab-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			switch req.URL.EscapedPath() {
+			case "/api/v4/projects/some-group%2Fsome-project/releases":
+				Expect(req.URL.Query().Get("per_page")).To(Equal("100"))
+				fmt.Fprintf(w, `[
+  {
+    "tag_name": "v2.0.0-rc.1",
+    "released_at": "2022-04-01T12:00:00Z",
+    "upcoming_release": true
+  },
+  {
+    "tag_name": "v1.2.3",
+    "description": "some-notes",
+    "released_at": "2022-03-01T12:00:00Z",
+    "assets": {
+      "links": [
+        {"name": "some-buildpack.tgz", "url": "%[1]s/some-link", "direct_asset_url": "%[1]s/some-direct-link"}
+      ],
+      "sources": [
+        {"format": "zip", "url": "%[1]s/some-source.zip"},
+        {"format": "tar.gz", "url": "%[1]s/some-source.tar.gz"}
+      ]
+    },
+    "_links": {"self": "https://gitlab.example.com/some-group/some-project/-/releases/v1.2.3"}
+  },
+  {
+    "tag_name": "v1.2.2",
+    "released_at": "2022-02-01T12:00:00Z"
+  }
+]`, api.URL)
+			case "/api/v4/projects/some-group%2Fpaged-project/releases":
+				if req.URL.Query().Get("page") == "2" {
+					w.Write([]byte(`[{"tag_name": "v1.0.0"}]`))
+					return
+				}
+
+				w.Header().Set("Link", fmt.Sprintf(`<%s/api/v4/projects/some-group%%2Fpaged-project/releases?page=2&per_page=100>; rel="next", <%s/api/v4/projects/some-group%%2Fpaged-project/releases?page=2&per_page=100>; rel="last"`, api.URL, api.URL))
+				w.Write([]byte(`[{"tag_name": "v2.0.0"}]`))
+			case "/api/v4/projects/some-group%2Fmissing-project/releases":
+				w.WriteHeader(http.StatusNotFound)
+			case "/some-direct-link":
+				w.Write([]byte("some-asset"))
+			case "/some-source.tar.gz":
+				w.Write([]byte("some-tarball"))
+			case "/missing-source.tar.gz":
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				Fail(fmt.Sprintf("unexpected request:\n%s", dump))
+			}
+		}))
+
+		service = gitlab.NewReleaseService(gitlab.Config{
+			Endpoint: api.URL,
+			Token:    "some-gitlab-token",
+		})
+	})
+
+	it.After(func() {
+		api.Close()
+	})
+
+	context("ListReleases", func() {
+		it("maps the releases onto github releases", func() {
+			releases, err := service.ListReleases(ctx, "some-group", "some-project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(releases).To(HaveLen(3))
+
+			Expect(releases[0].Prerelease).To(BeTrue())
+			Expect(releases[1]).To(Equal(github.Release{
+				TagName: "v1.2.3",
+				Body:    "some-notes",
+				Assets: []github.ReleaseAsset{{
+					URL:                fmt.Sprintf("%s/some-direct-link", api.URL),
+					BrowserDownloadURL: fmt.Sprintf("%s/some-link", api.URL),
+					Name:               "some-buildpack.tgz",
+				}},
+				TarballURL:  fmt.Sprintf("%s/some-source.tar.gz", api.URL),
+				HTMLURL:     "https://gitlab.example.com/some-group/some-project/-/releases/v1.2.3",
+				PublishedAt: time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC),
+			}))
+		})
+
+		context("when the releases span several pages", func() {
+			it("follows the next links to the last page", func() {
+				releases, err := service.ListReleases(ctx, "some-group", "paged-project")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(releases).To(HaveLen(2))
+				Expect(releases[0].TagName).To(Equal("v2.0.0"))
+				Expect(releases[1].TagName).To(Equal("v1.0.0"))
+			})
+		})
+
+		context("when the project cannot be found", func() {
+			it("returns an error", func() {
+				_, err := service.ListReleases(ctx, "some-group", "missing-project")
+				Expect(err).To(MatchError("unexpected response status: 404 Not Found"))
+			})
+		})
+	})
+
+	context("Get", func() {
+		it("returns the newest release that is not upcoming", func() {
+			release, err := service.Get(ctx, "some-group", "some-project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(release.TagName).To(Equal("v1.2.3"))
+		})
+	})
+
+	context("GetByID", func() {
+		it("returns an error", func() {
+			_, err := service.GetByID(ctx, "some-group", "some-project", 12345)
+			Expect(err).To(MatchError("cannot get release 12345 of some-group/some-project: GitLab releases have no id, pin a tag instead"))
+		})
+	})
+
+	context("GetReleaseAsset", func() {
+		it("downloads the asset", func() {
+			content, err := service.GetReleaseAsset(ctx, github.ReleaseAsset{URL: fmt.Sprintf("%s/some-direct-link", api.URL)})
+			Expect(err).NotTo(HaveOccurred())
+			defer content.Close()
+
+			body, err := io.ReadAll(content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal("some-asset"))
+		})
+	})
+
+	context("GetReleaseTarball", func() {
+		it("downloads the source tarball", func() {
+			content, err := service.GetReleaseTarball(ctx, fmt.Sprintf("%s/some-source.tar.gz", api.URL))
+			Expect(err).NotTo(HaveOccurred())
+			defer content.Close()
+
+			body, err := io.ReadAll(content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal("some-tarball"))
+		})
+
+		context("when the tarball is missing", func() {
+			it("returns a StatusError", func() {
+				_, err := service.GetReleaseTarball(ctx, fmt.Sprintf("%s/missing-source.tar.gz", api.URL))
+
+				var statusErr *github.StatusError
+				Expect(errors.As(err, &statusErr)).To(BeTrue())
+				Expect(statusErr.StatusCode).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+}
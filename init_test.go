@@ -11,11 +11,23 @@ var gitToken string
 
 func TestFreezer(t *testing.T) {
 	suite := spec.New("freezer", spec.Report(report.Terminal{}))
+	suite("Artifact", testArtifact)
 	suite("CacheManager", testCacheManager)
+	suite("Clock", testClock)
+	suite("Config", testConfig)
+	suite("DirectoryFetcher", testDirectoryFetcher)
+	suite("Extractor", testExtractor)
 	suite("FileSystem", testFileSystem)
+	suite("LimitedPackager", testLimitedPackager)
 	suite("LocalFetcher", testLocalFetcher)
+	suite("MemoryCache", testMemoryCache)
 	suite("PackingTools", testPackingTools)
+	suite("PinnedBuildpacks", testPinnedBuildpacks)
 	suite("RandomName", testRandomName)
+	suite("RecordReplay", testRecordReplay)
+	suite("RemoteBuildpack", testRemoteBuildpack)
 	suite("RemoteFetcher", testRemoteFetcher)
+	suite("SnapshotFetcher", testSnapshotFetcher)
+	suite("Version", testVersion)
 	suite.Run(t)
 }
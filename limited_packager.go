@@ -0,0 +1,39 @@
+package freezer
+
+import (
+	"context"
+	"runtime"
+)
+
+// LimitedPackager bounds the number of packager processes running at once,
+// independently of how many downloads run in parallel.
+type LimitedPackager struct {
+	packager Packager
+	slots    chan struct{}
+}
+
+// NewLimitedPackager allows at most limit concurrent Execute calls on
+// packager. A limit below one defaults to GOMAXPROCS.
+func NewLimitedPackager(packager Packager, limit int) LimitedPackager {
+	if limit < 1 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+
+	return LimitedPackager{
+		packager: packager,
+		slots:    make(chan struct{}, limit),
+	}
+}
+
+// Execute waits for a free slot and runs the packager in it. It stops
+// waiting once ctx is done.
+func (l LimitedPackager) Execute(ctx context.Context, buildpackDir, output, version string, cached bool) error {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-l.slots }()
+
+	return l.packager.Execute(ctx, buildpackDir, output, version, cached)
+}
@@ -0,0 +1,110 @@
+package freezer_test
+
+import (
+	gocontext "context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/ForestEckhardt/freezer/fakes"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLimitedPackager(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		packager *fakes.Packager
+	)
+
+	it.Before(func() {
+		packager = &fakes.Packager{}
+	})
+
+	context("Execute", func() {
+		it("runs no more than the limit of packagers at once", func() {
+			var (
+				mutex   sync.Mutex
+				running int
+				most    int
+			)
+
+			// The fake serializes calls with its own lock, so the stub is
+			// wrapped to observe the limiter rather than the fake
+			limited := freezer.NewLimitedPackager(packagerFunc(func(ctx gocontext.Context, buildpackDir, output, version string, cached bool) error {
+				mutex.Lock()
+				running++
+				if running > most {
+					most = running
+				}
+				mutex.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mutex.Lock()
+				running--
+				mutex.Unlock()
+
+				return packager.Execute(ctx, buildpackDir, output, version, cached)
+			}), 2)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 6; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_ = limited.Execute(gocontext.Background(), "some-dir", "some-output", "some-version", false)
+				}()
+			}
+			wg.Wait()
+
+			Expect(most).To(Equal(2))
+			Expect(packager.ExecuteCall.CallCount).To(Equal(6))
+		})
+
+		it("passes the arguments and error through", func() {
+			packager.ExecuteCall.Returns.Error = errors.New("failed to package")
+
+			err := freezer.NewLimitedPackager(packager, 0).Execute(gocontext.Background(), "some-dir", "some-output", "some-version", true)
+			Expect(err).To(MatchError("failed to package"))
+
+			Expect(packager.ExecuteCall.Receives.BuildpackDir).To(Equal("some-dir"))
+			Expect(packager.ExecuteCall.Receives.Output).To(Equal("some-output"))
+			Expect(packager.ExecuteCall.Receives.Version).To(Equal("some-version"))
+			Expect(packager.ExecuteCall.Receives.Cached).To(BeTrue())
+		})
+
+		it("stops waiting for a free slot once the context is done", func() {
+			started := make(chan struct{})
+			finish := make(chan struct{})
+
+			limited := freezer.NewLimitedPackager(packagerFunc(func(ctx gocontext.Context, buildpackDir, output, version string, cached bool) error {
+				close(started)
+				<-finish
+				return nil
+			}), 1)
+
+			go func() {
+				_ = limited.Execute(gocontext.Background(), "some-dir", "some-output", "some-version", false)
+			}()
+			<-started
+			defer close(finish)
+
+			ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			err := limited.Execute(ctx, "some-other-dir", "some-output", "some-version", false)
+			Expect(err).To(MatchError(gocontext.DeadlineExceeded))
+		})
+	})
+}
+
+type packagerFunc func(ctx gocontext.Context, buildpackDir, output, version string, cached bool) error
+
+func (f packagerFunc) Execute(ctx gocontext.Context, buildpackDir, output, version string, cached bool) error {
+	return f(ctx, buildpackDir, output, version, cached)
+}
@@ -1,9 +1,13 @@
 package freezer
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/BurntSushi/toml"
 )
 
 //go:generate faux --interface Namer --output fakes/namer.go
@@ -15,6 +19,10 @@ type LocalFetcher struct {
 	buildpackCache BuildpackCache
 	packager       Packager
 	namer          Namer
+	namespace      string
+	cachedSuffix   string
+	cachedDir      string
+	clock          Clock
 }
 
 func NewLocalFetcher(buildpackCache BuildpackCache, packager Packager, namer Namer) LocalFetcher {
@@ -22,6 +30,7 @@ func NewLocalFetcher(buildpackCache BuildpackCache, packager Packager, namer Nam
 		buildpackCache: buildpackCache,
 		packager:       packager,
 		namer:          namer,
+		clock:          NewSystemClock(),
 	}
 }
 
@@ -30,16 +39,38 @@ func (l LocalFetcher) WithPackager(packager Packager) LocalFetcher {
 	return l
 }
 
+// WithClock sets the clock that versions buildpacks without a version of
+// their own.
+func (l LocalFetcher) WithClock(clock Clock) LocalFetcher {
+	l.clock = clock
+	return l
+}
+
+// WithNamespace prefixes cache keys with "<namespace>/" and stores
+// artifacts beneath <cache dir>/<namespace>.
+func (l LocalFetcher) WithNamespace(namespace string) LocalFetcher {
+	l.namespace = namespace
+	return l
+}
+
+// WithCachedVariant behaves like RemoteFetcher.WithCachedVariant.
+func (l LocalFetcher) WithCachedVariant(keySuffix, dir string) LocalFetcher {
+	l.cachedSuffix = keySuffix
+	l.cachedDir = dir
+	return l
+}
+
 func (l LocalFetcher) Get(buildpack LocalBuildpack) (string, error) {
-	buildpackCacheDir := filepath.Join(l.buildpackCache.Dir(), buildpack.Name)
+	buildpackCacheDir := filepath.Join(l.buildpackCache.Dir(), l.namespace, buildpack.Name)
 	if buildpack.Offline {
-		buildpackCacheDir = filepath.Join(buildpackCacheDir, "cached")
+		buildpackCacheDir = filepath.Join(buildpackCacheDir, cachedVariantDir(l.cachedDir))
 	}
 
 	key := buildpack.UncachedKey
 	if buildpack.Offline {
-		key = buildpack.CachedKey
+		key = cachedVariantKey(buildpack.UncachedKey, buildpack.CachedKey, l.cachedSuffix)
 	}
+	key = namespacedKey(l.namespace, key)
 
 	name, err := l.namer.RandomName(buildpack.Name)
 	if err != nil {
@@ -66,13 +97,18 @@ func (l LocalFetcher) Get(buildpack LocalBuildpack) (string, error) {
 		}
 	}
 
-	err = l.packager.Execute(buildpack.Path, path, buildpack.Version, buildpack.Offline)
+	version, err := l.version(buildpack)
+	if err != nil {
+		return "", err
+	}
+
+	err = l.packager.Execute(context.Background(), buildpack.Path, path, version, buildpack.Offline)
 	if err != nil {
 		return "", fmt.Errorf("failed to package buildpack: %w", err)
 	}
 
 	err = l.buildpackCache.Set(key, CacheEntry{
-		Version: "testing",
+		Version: version,
 		URI:     path,
 	})
 
@@ -82,3 +118,34 @@ func (l LocalFetcher) Get(buildpack LocalBuildpack) (string, error) {
 
 	return path, nil
 }
+
+// version is the version of buildpack when it has one. Otherwise it is taken
+// from the metadata.version or buildpack.version of its buildpack.toml, and
+// failing that from the current time.
+func (l LocalFetcher) version(buildpack LocalBuildpack) (string, error) {
+	if buildpack.Version != "" {
+		return buildpack.Version, nil
+	}
+
+	var config struct {
+		Metadata struct {
+			Version string `toml:"version"`
+		} `toml:"metadata"`
+		Buildpack struct {
+			Version string `toml:"version"`
+		} `toml:"buildpack"`
+	}
+	_, err := toml.DecodeFile(filepath.Join(buildpack.Path, "buildpack.toml"), &config)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read buildpack.toml: %w", err)
+	}
+
+	switch {
+	case config.Metadata.Version != "":
+		return config.Metadata.Version, nil
+	case config.Buildpack.Version != "":
+		return config.Buildpack.Version, nil
+	}
+
+	return l.clock.Now().UTC().Format("20060102150405"), nil
+}
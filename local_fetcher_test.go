@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ForestEckhardt/freezer"
 	"github.com/ForestEckhardt/freezer/fakes"
@@ -75,11 +76,83 @@ func testLocalFetcher(t *testing.T, context spec.G, it spec.S) {
 				Expect(packager.ExecuteCall.Receives.Cached).To(BeFalse())
 
 				Expect(buildpackCache.SetCall.CallCount).To(Equal(1))
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.Version).To(Equal("some-version"))
 
 				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-buildpack", "some-buildpack-random-string.tgz")))
 			})
 		})
 
+		context("when a namespace is configured", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+				localFetcher = localFetcher.WithNamespace("suite-a")
+			})
+
+			it("prefixes the cache key and path with the namespace", func() {
+				uri, err := localFetcher.Get(localBuildpack)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(buildpackCache.GetCall.Receives.Key).To(Equal("suite-a/some-buildpack"))
+				Expect(buildpackCache.SetCall.Receives.Key).To(Equal("suite-a/some-buildpack"))
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "suite-a", "some-buildpack", "some-buildpack-random-string.tgz")))
+			})
+		})
+
+		context("when the cached variant naming is configured", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+				localBuildpack.Offline = true
+				localFetcher = localFetcher.WithCachedVariant(":offline", "offline")
+			})
+
+			it("uses the custom suffix in the key and the path", func() {
+				uri, err := localFetcher.Get(localBuildpack)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(buildpackCache.GetCall.Receives.Key).To(Equal("some-buildpack:offline"))
+				Expect(buildpackCache.SetCall.Receives.Key).To(Equal("some-buildpack:offline"))
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-buildpack", "offline", "some-buildpack-random-string.tgz")))
+			})
+		})
+
+		context("when the buildpack has no version", func() {
+			var buildpackDir string
+
+			it.Before(func() {
+				var err error
+				buildpackDir, err = os.MkdirTemp("", "buildpack")
+				Expect(err).NotTo(HaveOccurred())
+
+				localBuildpack = freezer.NewLocalBuildpack(buildpackDir, "some-buildpack")
+
+				clock := &fakes.Clock{}
+				clock.NowCall.Returns.Time = time.Date(2022, time.March, 1, 12, 30, 45, 0, time.UTC)
+				localFetcher = localFetcher.WithClock(clock)
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(buildpackDir)).To(Succeed())
+			})
+
+			it("takes the version from the metadata of the buildpack.toml", func() {
+				Expect(os.WriteFile(filepath.Join(buildpackDir, "buildpack.toml"), []byte("[buildpack]\nversion = \"1.0.0\"\n\n[metadata]\nversion = \"1.2.3\"\n"), 0644)).To(Succeed())
+
+				_, err := localFetcher.Get(localBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(packager.ExecuteCall.Receives.Version).To(Equal("1.2.3"))
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.Version).To(Equal("1.2.3"))
+			})
+
+			it("falls back to a timestamp", func() {
+				_, err := localFetcher.Get(localBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(packager.ExecuteCall.Receives.Version).To(Equal("20220301123045"))
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.Version).To(Equal("20220301123045"))
+			})
+		})
+
 		context("failure cases", func() {
 			context("when the namer fails to generate a random name", func() {
 				it.Before(func() {
@@ -0,0 +1,103 @@
+package freezer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// MemoryCache is a BuildpackCache that keeps its entries in memory. It is
+// meant for tests that depend on a cache but should not manage a cache
+// database on disk. Artifacts are still written beneath Dir, which is a
+// temporary directory removed by Close.
+type MemoryCache struct {
+	mutex   sync.Mutex
+	entries CacheDB
+	dir     string
+}
+
+func NewMemoryCache() (*MemoryCache, error) {
+	dir, err := os.MkdirTemp("", "freezer-cache")
+	if err != nil {
+		return nil, err
+	}
+
+	return &MemoryCache{
+		entries: CacheDB{},
+		dir:     dir,
+	}, nil
+}
+
+func (m *MemoryCache) Get(key string) (CacheEntry, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, ok := m.entries[key]
+	return entry, ok, nil
+}
+
+func (m *MemoryCache) Set(key string, cachedEntry CacheEntry) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if cachedEntry.Labels == nil {
+		cachedEntry.Labels = m.entries[key].Labels
+	}
+
+	m.entries[key] = cachedEntry
+	return nil
+}
+
+func (m *MemoryCache) Label(key string, labels ...string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return fmt.Errorf("no cache entry for %s", key)
+	}
+
+	entry.Labels = addLabels(entry.Labels, labels)
+	m.entries[key] = entry
+
+	return nil
+}
+
+// ListByLabel returns the sorted keys of all entries carrying label.
+func (m *MemoryCache) ListByLabel(label string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return keysWithLabel(m.entries, label)
+}
+
+// List returns the keys of all entries in sorted order.
+func (m *MemoryCache) List() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var keys []string
+	for key := range m.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (m *MemoryCache) Delete(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *MemoryCache) Dir() string {
+	return m.dir
+}
+
+func (m *MemoryCache) Close() error {
+	return os.RemoveAll(m.dir)
+}
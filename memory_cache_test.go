@@ -0,0 +1,98 @@
+package freezer_test
+
+import (
+	"testing"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testMemoryCache(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		cache *freezer.MemoryCache
+	)
+
+	it.Before(func() {
+		var err error
+		cache, err = freezer.NewMemoryCache()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(cache.Close()).To(Succeed())
+	})
+
+	it("satisfies the BuildpackCache interface", func() {
+		var _ freezer.BuildpackCache = cache
+	})
+
+	context("Dir", func() {
+		it("returns a temporary directory that is removed on Close", func() {
+			dir := cache.Dir()
+			Expect(dir).To(BeADirectory())
+
+			Expect(cache.Close()).To(Succeed())
+			Expect(dir).NotTo(BeADirectory())
+		})
+	})
+
+	context("Get and Set", func() {
+		it("returns the entry that was set", func() {
+			Expect(cache.Set("some-key", freezer.CacheEntry{Version: "1.2.3", URI: "some-uri"})).To(Succeed())
+
+			entry, ok, err := cache.Get("some-key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(entry).To(Equal(freezer.CacheEntry{Version: "1.2.3", URI: "some-uri"}))
+		})
+
+		it("reports a missing key as not ok", func() {
+			entry, ok, err := cache.Get("some-missing-key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(entry).To(Equal(freezer.CacheEntry{}))
+		})
+	})
+
+	context("List", func() {
+		it("returns the keys in sorted order", func() {
+			Expect(cache.Set("some-key", freezer.CacheEntry{})).To(Succeed())
+			Expect(cache.Set("other-key", freezer.CacheEntry{})).To(Succeed())
+
+			Expect(cache.List()).To(Equal([]string{"other-key", "some-key"}))
+		})
+	})
+
+	context("Delete", func() {
+		it("removes the entry", func() {
+			Expect(cache.Set("some-key", freezer.CacheEntry{})).To(Succeed())
+			Expect(cache.Delete("some-key")).To(Succeed())
+
+			_, ok, err := cache.Get("some-key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(cache.List()).To(BeEmpty())
+		})
+	})
+
+	context("Label", func() {
+		it("attaches labels that can be queried", func() {
+			Expect(cache.Set("some-key", freezer.CacheEntry{})).To(Succeed())
+			Expect(cache.Set("other-key", freezer.CacheEntry{})).To(Succeed())
+
+			Expect(cache.Label("some-key", "golden", "nightly")).To(Succeed())
+			Expect(cache.Label("other-key", "nightly")).To(Succeed())
+
+			Expect(cache.ListByLabel("nightly")).To(Equal([]string{"other-key", "some-key"}))
+			Expect(cache.ListByLabel("golden")).To(Equal([]string{"some-key"}))
+		})
+
+		it("returns an error when the entry does not exist", func() {
+			Expect(cache.Label("some-missing-key", "golden")).To(MatchError("no cache entry for some-missing-key"))
+		})
+	})
+}
@@ -0,0 +1,199 @@
+package objectstore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ForestEckhardt/freezer"
+)
+
+const indexObject = "buildpacks-cache.db"
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// Bucket is the subset of an object store (S3, GCS, ...) that the cache
+// needs. Get must return an error wrapping ErrObjectNotFound for missing
+// objects.
+type Bucket interface {
+	Get(name string) (io.ReadCloser, error)
+	Put(name string, content io.Reader) error
+	Delete(name string) error
+}
+
+// Cache is a freezer.BuildpackCache that keeps artifacts and the metadata
+// index in a Bucket. Artifacts are staged in a local directory so that
+// fetchers can keep writing to paths under Dir.
+type Cache struct {
+	Index freezer.CacheDB
+
+	bucket   Bucket
+	stageDir string
+}
+
+func NewCache(bucket Bucket, stageDir string) Cache {
+	return Cache{
+		bucket:   bucket,
+		stageDir: stageDir,
+	}
+}
+
+func (c *Cache) Open() error {
+	err := os.MkdirAll(c.stageDir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	index, err := c.bucket.Get(indexObject)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			c.Index = freezer.CacheDB{}
+			return nil
+		}
+		return err
+	}
+	defer index.Close()
+
+	return gob.NewDecoder(index).Decode(&c.Index)
+}
+
+func (c Cache) Close() error {
+	buffer := bytes.NewBuffer(nil)
+	err := gob.NewEncoder(buffer).Encode(&c.Index)
+	if err != nil {
+		return err
+	}
+
+	return c.bucket.Put(indexObject, buffer)
+}
+
+func (c Cache) Get(key string) (freezer.CacheEntry, bool, error) {
+	entry, ok := c.Index[key]
+	if !ok {
+		return entry, false, nil
+	}
+
+	_, err := os.Stat(entry.URI)
+	if err == nil {
+		return entry, true, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return freezer.CacheEntry{}, false, err
+	}
+
+	name, err := c.objectName(entry.URI)
+	if err != nil {
+		return freezer.CacheEntry{}, false, err
+	}
+
+	object, err := c.bucket.Get(name)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return entry, false, nil
+		}
+		return freezer.CacheEntry{}, false, err
+	}
+	defer object.Close()
+
+	err = os.MkdirAll(filepath.Dir(entry.URI), os.ModePerm)
+	if err != nil {
+		return freezer.CacheEntry{}, false, err
+	}
+
+	err = download(object, entry.URI)
+	if err != nil {
+		return freezer.CacheEntry{}, false, err
+	}
+
+	return entry, true, nil
+}
+
+func (c *Cache) Set(key string, value freezer.CacheEntry) error {
+	if c.Index == nil {
+		return errors.New("the cache is not loaded properly")
+	}
+
+	name, err := c.objectName(value.URI)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(value.URI)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = c.bucket.Put(name, file)
+	if err != nil {
+		return err
+	}
+
+	previous, ok := c.Index[key]
+	if ok && previous.URI != value.URI {
+		previousName, err := c.objectName(previous.URI)
+		if err != nil {
+			return err
+		}
+
+		err = c.bucket.Delete(previousName)
+		if err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return err
+		}
+
+		err = os.RemoveAll(previous.URI)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Index[key] = value
+
+	return nil
+}
+
+func (c Cache) Dir() string {
+	return c.stageDir
+}
+
+// download stages the object beside path and renames it into place, so
+// that a failed copy never leaves a truncated artifact at path.
+func download(object io.Reader, path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), fmt.Sprintf(".%s-*", filepath.Base(path)))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, object)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func (c Cache) objectName(uri string) (string, error) {
+	rel, err := filepath.Rel(c.stageDir, uri)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("artifact %s is outside of the cache directory %s", uri, c.stageDir)
+	}
+
+	return filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,299 @@
+package objectstore_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/ForestEckhardt/freezer/objectstore"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+type memoryBucket struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *memoryBucket) Get(name string) (io.ReadCloser, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	content, ok := b.objects[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, objectstore.ErrObjectNotFound)
+	}
+
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (b *memoryBucket) Put(name string, content io.Reader) error {
+	b.Lock()
+	defer b.Unlock()
+
+	buffer, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	b.objects[name] = buffer
+	return nil
+}
+
+func (b *memoryBucket) Delete(name string) error {
+	b.Lock()
+	defer b.Unlock()
+
+	delete(b.objects, name)
+	return nil
+}
+
+func testCache(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		stageDir string
+		bucket   *memoryBucket
+		cache    objectstore.Cache
+	)
+
+	it.Before(func() {
+		var err error
+		stageDir, err = os.MkdirTemp("", "stage")
+		Expect(err).NotTo(HaveOccurred())
+
+		bucket = &memoryBucket{objects: map[string][]byte{}}
+		cache = objectstore.NewCache(bucket, stageDir)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(stageDir)).To(Succeed())
+	})
+
+	it("satisfies the BuildpackCache interface", func() {
+		var _ freezer.BuildpackCache = &cache
+		Expect(cache.Dir()).To(Equal(stageDir))
+	})
+
+	context("Open", func() {
+		context("when the bucket has an index object", func() {
+			it.Before(func() {
+				buffer := bytes.NewBuffer(nil)
+				Expect(gob.NewEncoder(buffer).Encode(freezer.CacheDB{"some-key": {Version: "1.2.3", URI: "some-uri"}})).To(Succeed())
+				bucket.objects["buildpacks-cache.db"] = buffer.Bytes()
+			})
+
+			it("loads the index", func() {
+				Expect(cache.Open()).To(Succeed())
+				Expect(cache.Index).To(Equal(freezer.CacheDB{"some-key": {Version: "1.2.3", URI: "some-uri"}}))
+			})
+		})
+
+		context("when the bucket is empty", func() {
+			it("starts with an empty index", func() {
+				Expect(cache.Open()).To(Succeed())
+				Expect(cache.Index).To(Equal(freezer.CacheDB{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the index object is malformed", func() {
+				it.Before(func() {
+					bucket.objects["buildpacks-cache.db"] = []byte(`%%%`)
+				})
+
+				it("returns an error", func() {
+					Expect(cache.Open()).To(MatchError(ContainSubstring("unexpected EOF")))
+				})
+			})
+		})
+	})
+
+	context("Set", func() {
+		var uri string
+
+		it.Before(func() {
+			Expect(cache.Open()).To(Succeed())
+
+			uri = filepath.Join(stageDir, "some-org", "some-repo", "some-tag.tgz")
+			Expect(os.MkdirAll(filepath.Dir(uri), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(uri, []byte("some-content"), 0644)).To(Succeed())
+		})
+
+		it("uploads the artifact and records the entry", func() {
+			Expect(cache.Set("some-org:some-repo", freezer.CacheEntry{Version: "some-tag", URI: uri})).To(Succeed())
+
+			Expect(bucket.objects).To(HaveKeyWithValue("some-org/some-repo/some-tag.tgz", []byte("some-content")))
+			Expect(cache.Index).To(HaveKeyWithValue("some-org:some-repo", freezer.CacheEntry{Version: "some-tag", URI: uri}))
+		})
+
+		context("when there is a previous entry", func() {
+			var previous string
+
+			it.Before(func() {
+				previous = filepath.Join(stageDir, "some-org", "some-repo", "some-old-tag.tgz")
+				Expect(os.WriteFile(previous, []byte("some-old-content"), 0644)).To(Succeed())
+				Expect(cache.Set("some-org:some-repo", freezer.CacheEntry{Version: "some-old-tag", URI: previous})).To(Succeed())
+			})
+
+			it("removes the previous object and staged file", func() {
+				Expect(cache.Set("some-org:some-repo", freezer.CacheEntry{Version: "some-tag", URI: uri})).To(Succeed())
+
+				Expect(bucket.objects).NotTo(HaveKey("some-org/some-repo/some-old-tag.tgz"))
+				Expect(previous).NotTo(BeAnExistingFile())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the artifact is outside of the staging directory", func() {
+				it("returns an error", func() {
+					err := cache.Set("some-org:some-repo", freezer.CacheEntry{Version: "some-tag", URI: "/some/other/place.tgz"})
+					Expect(err).To(MatchError(ContainSubstring("is outside of the cache directory")))
+				})
+			})
+
+			context("when the index has not been loaded", func() {
+				it.Before(func() {
+					cache.Index = nil
+				})
+
+				it("returns an error", func() {
+					err := cache.Set("some-org:some-repo", freezer.CacheEntry{Version: "some-tag", URI: uri})
+					Expect(err).To(MatchError("the cache is not loaded properly"))
+				})
+			})
+		})
+	})
+
+	context("Get", func() {
+		var uri string
+
+		it.Before(func() {
+			Expect(cache.Open()).To(Succeed())
+
+			uri = filepath.Join(stageDir, "some-org", "some-repo", "some-tag.tgz")
+			cache.Index["some-org:some-repo"] = freezer.CacheEntry{Version: "some-tag", URI: uri}
+		})
+
+		context("when the artifact is staged locally", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Dir(uri), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(uri, []byte("some-content"), 0644)).To(Succeed())
+			})
+
+			it("returns the entry", func() {
+				entry, ok, err := cache.Get("some-org:some-repo")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				Expect(entry).To(Equal(freezer.CacheEntry{Version: "some-tag", URI: uri}))
+			})
+		})
+
+		context("when the artifact is only in the bucket", func() {
+			it.Before(func() {
+				bucket.objects["some-org/some-repo/some-tag.tgz"] = []byte("some-content")
+			})
+
+			it("downloads the artifact into the staging directory", func() {
+				entry, ok, err := cache.Get("some-org:some-repo")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				Expect(entry.URI).To(Equal(uri))
+
+				content, err := os.ReadFile(uri)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("some-content"))
+			})
+		})
+
+		context("when the artifact is missing from the bucket", func() {
+			it("returns the entry and not ok", func() {
+				entry, ok, err := cache.Get("some-org:some-repo")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+				Expect(entry.URI).To(Equal(uri))
+			})
+		})
+
+		context("when the key does not exist", func() {
+			it("returns an empty entry and not ok", func() {
+				entry, ok, err := cache.Get("some-other-key")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+				Expect(entry).To(Equal(freezer.CacheEntry{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the download of the artifact fails partway", func() {
+				it.Before(func() {
+					cache = objectstore.NewCache(truncatingBucket{}, stageDir)
+					Expect(cache.Open()).To(Succeed())
+					cache.Index["some-org:some-repo"] = freezer.CacheEntry{Version: "some-tag", URI: uri}
+				})
+
+				it("returns an error and leaves nothing at the artifact path", func() {
+					_, ok, err := cache.Get("some-org:some-repo")
+					Expect(err).To(MatchError("connection reset"))
+					Expect(ok).To(BeFalse())
+
+					Expect(uri).NotTo(BeAnExistingFile())
+
+					files, err := os.ReadDir(filepath.Dir(uri))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(files).To(BeEmpty())
+				})
+			})
+		})
+	})
+
+	context("Close", func() {
+		it("writes the index to the bucket so a new cache can read it", func() {
+			Expect(cache.Open()).To(Succeed())
+			cache.Index["some-key"] = freezer.CacheEntry{Version: "1.2.3", URI: "some-uri"}
+			Expect(cache.Close()).To(Succeed())
+
+			reopened := objectstore.NewCache(bucket, stageDir)
+			Expect(reopened.Open()).To(Succeed())
+			Expect(reopened.Index).To(Equal(cache.Index))
+		})
+
+		context("failure cases", func() {
+			context("when the bucket rejects the index", func() {
+				it("returns an error", func() {
+					cache = objectstore.NewCache(failingBucket{}, stageDir)
+					Expect(cache.Close()).To(MatchError("bucket is read-only"))
+				})
+			})
+		})
+	})
+}
+
+type failingBucket struct{}
+
+func (failingBucket) Get(string) (io.ReadCloser, error) { return nil, objectstore.ErrObjectNotFound }
+func (failingBucket) Put(string, io.Reader) error       { return errors.New("bucket is read-only") }
+func (failingBucket) Delete(string) error               { return nil }
+
+type truncatingBucket struct{}
+
+func (truncatingBucket) Get(name string) (io.ReadCloser, error) {
+	if name == "buildpacks-cache.db" {
+		return nil, objectstore.ErrObjectNotFound
+	}
+
+	return io.NopCloser(io.MultiReader(strings.NewReader("some-partial-content"), iotest.ErrReader(errors.New("connection reset")))), nil
+}
+
+func (truncatingBucket) Put(string, io.Reader) error { return nil }
+func (truncatingBucket) Delete(string) error         { return nil }
@@ -0,0 +1,22 @@
+package objectstore_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestObjectStore(t *testing.T) {
+	suite := spec.New("objectstore", spec.Report(report.Terminal{}))
+	suite("Cache", testCache)
+	suite("S3Bucket", testS3Bucket)
+
+	suite.Before(func(t *testing.T) {
+		RegisterTestingT(t)
+	})
+
+	suite.Run(t)
+}
@@ -0,0 +1,273 @@
+package objectstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ForestEckhardt/freezer"
+)
+
+// DefaultRegion is the region requests are signed for when S3Config does
+// not name one.
+const DefaultRegion = "us-east-1"
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+type S3Config struct {
+	// Endpoint is the base url of the S3 API, such as
+	// https://s3.us-east-1.amazonaws.com. Any S3-compatible store works,
+	// including MinIO and the XML API of GCS at
+	// https://storage.googleapis.com with HMAC keys.
+	Endpoint string
+
+	// Bucket is addressed path-style, as Endpoint/Bucket/object.
+	Bucket string
+
+	// Region is the region requests are signed for. It defaults to
+	// DefaultRegion.
+	Region string
+
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SessionToken is sent as the X-Amz-Security-Token of every request
+	// when set, for temporary credentials.
+	SessionToken string
+
+	// Timeout limits each request, including reading its body. Zero means no
+	// timeout.
+	Timeout time.Duration
+
+	// Client sends every request when it is set, in which case Timeout is
+	// ignored.
+	Client *http.Client
+}
+
+// NewS3Config reads the credentials from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+func NewS3Config(endpoint, bucket, region string) S3Config {
+	return S3Config{
+		Endpoint:        endpoint,
+		Bucket:          bucket,
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// S3Bucket is a Bucket that keeps objects in an S3-compatible store. Every
+// request is signed with AWS Signature Version 4.
+type S3Bucket struct {
+	config S3Config
+	client *http.Client
+	clock  freezer.Clock
+}
+
+func NewS3Bucket(config S3Config) S3Bucket {
+	if config.Region == "" {
+		config.Region = DefaultRegion
+	}
+
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+		if config.Timeout != 0 {
+			client = &http.Client{Timeout: config.Timeout}
+		}
+	}
+
+	return S3Bucket{
+		config: config,
+		client: client,
+		clock:  freezer.NewSystemClock(),
+	}
+}
+
+// WithClock sets the clock that dates the signature of every request.
+func (b S3Bucket) WithClock(clock freezer.Clock) S3Bucket {
+	b.clock = clock
+	return b
+}
+
+func (b S3Bucket) Get(name string) (io.ReadCloser, error) {
+	resp, err := b.do("GET", name, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError("get", name, resp)
+	}
+
+	return resp.Body, nil
+}
+
+// Put uploads content with its length and digest. Content that cannot seek
+// is buffered in memory to measure it first.
+func (b S3Bucket) Put(name string, content io.Reader) error {
+	seeker, ok := content.(io.ReadSeeker)
+	if !ok {
+		buffer, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+
+		seeker = bytes.NewReader(buffer)
+	}
+
+	hash := sha256.New()
+	length, err := io.Copy(hash, seeker)
+	if err != nil {
+		return err
+	}
+
+	_, err = seeker.Seek(-length, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.do("PUT", name, io.LimitReader(seeker, length), length, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError("put", name, resp)
+	}
+
+	return nil
+}
+
+func (b S3Bucket) Delete(name string) error {
+	resp, err := b.do("DELETE", name, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return statusError("delete", name, resp)
+	}
+
+	return nil
+}
+
+func (b S3Bucket) do(method, name string, body io.Reader, length int64, payloadHash string) (*http.Response, error) {
+	if length == 0 {
+		body = http.NoBody
+	}
+
+	uri := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(b.config.Endpoint, "/"), uriEncode(b.config.Bucket), uriEncode(name))
+
+	req, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = length
+
+	b.sign(req, payloadHash)
+
+	return b.client.Do(req)
+}
+
+// sign adds the Authorization header of AWS Signature Version 4 to req. Only
+// the host and X-Amz-* headers are signed.
+func (b S3Bucket) sign(req *http.Request, payloadHash string) {
+	now := b.clock.Now().UTC()
+	timestamp := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", timestamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.config.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, b.config.Region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		timestamp,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + b.config.SecretAccessKey)
+	for _, part := range []string{date, b.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.config.AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func hmacSHA256(key []byte, content string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
+
+// uriEncode escapes everything but the unreserved characters and slashes,
+// as the canonical request of a signature expects.
+func uriEncode(path string) string {
+	var encoded strings.Builder
+	for _, c := range []byte(path) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte("-_.~/", c) >= 0:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+
+	return encoded.String()
+}
+
+func statusError(action, name string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", name, ErrObjectNotFound)
+	}
+
+	return fmt.Errorf("failed to %s object %s: unexpected response status: %s", action, name, resp.Status)
+}
@@ -1,24 +1,36 @@
 package freezer
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 )
 
 //go:generate faux --interface Executable --output fakes/executable.go
 type Executable interface {
-	Execute(pexec.Execution) error
+	Execute(ctx context.Context, execution pexec.Execution) error
 }
 
+// packagerOutputLines is how much of the packager output a failure reports.
+const packagerOutputLines = 20
+
 type PackingTools struct {
-	jam Executable
+	jam    Executable
+	output io.Writer
 }
 
 func NewPackingTools() PackingTools {
 	return PackingTools{
-		jam: pexec.NewExecutable("jam"),
+		jam: commandExecutable{name: "jam"},
 	}
 }
 
@@ -27,7 +39,16 @@ func (p PackingTools) WithExecutable(executable Executable) PackingTools {
 	return p
 }
 
-func (p PackingTools) Execute(buildpackDir, output, version string, cached bool) error {
+// WithOutput also writes the full stdout and stderr of every packager run to
+// w.
+func (p PackingTools) WithOutput(w io.Writer) PackingTools {
+	p.output = w
+	return p
+}
+
+// Execute runs jam to package the buildpack in buildpackDir. A jam process
+// that is still running once ctx is done is killed.
+func (p PackingTools) Execute(ctx context.Context, buildpackDir, output, version string, cached bool) error {
 	args := []string{
 		"pack",
 		"--buildpack", filepath.Join(buildpackDir, "buildpack.toml"),
@@ -39,9 +60,92 @@ func (p PackingTools) Execute(buildpackDir, output, version string, cached bool)
 		args = append(args, "--offline")
 	}
 
-	return p.jam.Execute(pexec.Execution{
+	captured := &outputBuffer{}
+	stdout := []io.Writer{os.Stdout, captured}
+	stderr := []io.Writer{os.Stderr, captured}
+	if p.output != nil {
+		stdout = append(stdout, p.output)
+		stderr = append(stderr, p.output)
+	}
+
+	err := p.jam.Execute(ctx, pexec.Execution{
 		Args:   args,
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
+		Stdout: io.MultiWriter(stdout...),
+		Stderr: io.MultiWriter(stderr...),
 	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		tail := captured.tail(packagerOutputLines)
+		if tail == "" {
+			return err
+		}
+		return fmt.Errorf("%w\n%s", err, tail)
+	}
+
+	return nil
+}
+
+// Validate checks that jam can be found and run so that a missing packager
+// is reported before anything is downloaded.
+func (p PackingTools) Validate() error {
+	err := p.jam.Execute(context.Background(), pexec.Execution{
+		Args:   []string{"--help"},
+		Stdout: io.Discard,
+		Stderr: io.Discard,
+	})
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("jam not found: %w", err)
+		}
+		return fmt.Errorf("jam is not runnable: %w", err)
+	}
+
+	return nil
+}
+
+// commandExecutable runs a program from the $PATH the way pexec.Executable
+// does, but kills it once the context of the run is done.
+type commandExecutable struct {
+	name string
+}
+
+func (c commandExecutable) Execute(ctx context.Context, execution pexec.Execution) error {
+	cmd := exec.CommandContext(ctx, c.name, execution.Args...)
+	cmd.Dir = execution.Dir
+	if len(execution.Env) > 0 {
+		cmd.Env = execution.Env
+	}
+	cmd.Stdout = execution.Stdout
+	cmd.Stderr = execution.Stderr
+
+	return cmd.Run()
+}
+
+// outputBuffer collects the interleaved stdout and stderr of a process.
+type outputBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (o *outputBuffer) Write(p []byte) (int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.buffer.Write(p)
+}
+
+// tail returns the last n lines written.
+func (o *outputBuffer) tail(n int) string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	lines := strings.Split(strings.TrimRight(o.buffer.String(), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n")
 }
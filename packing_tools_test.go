@@ -1,13 +1,19 @@
 package freezer_test
 
 import (
+	"bytes"
+	gocontext "context"
 	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ForestEckhardt/freezer"
 	"github.com/ForestEckhardt/freezer/fakes"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/sclevine/spec"
 
 	. "github.com/onsi/gomega"
@@ -16,6 +22,7 @@ import (
 func testPackingTools(t *testing.T, context spec.G, it spec.S) {
 	var (
 		Expect = NewWithT(t).Expect
+		ctx    = gocontext.Background()
 
 		buildpackDir string
 
@@ -40,7 +47,7 @@ func testPackingTools(t *testing.T, context spec.G, it spec.S) {
 
 	context("Execute", func() {
 		it("creates a correct pexec.Execution", func() {
-			err := packingTools.Execute(buildpackDir, "some-output", "some-version", false)
+			err := packingTools.Execute(ctx, buildpackDir, "some-output", "some-version", false)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(executable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{
@@ -53,7 +60,7 @@ func testPackingTools(t *testing.T, context spec.G, it spec.S) {
 
 		context("when cache is set to true", func() {
 			it("creates a correct pexec.Execution", func() {
-				err := packingTools.Execute(buildpackDir, "some-output", "some-version", true)
+				err := packingTools.Execute(ctx, buildpackDir, "some-output", "some-version", true)
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(executable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{
@@ -66,16 +73,115 @@ func testPackingTools(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when the context is done while jam is running", func() {
+			var path string
+
+			it.Before(func() {
+				sleep, err := exec.LookPath("sleep")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(os.WriteFile(filepath.Join(buildpackDir, "jam"), []byte(fmt.Sprintf("#!/bin/sh\nexec %s 10\n", sleep)), 0755)).To(Succeed())
+
+				path = os.Getenv("PATH")
+				Expect(os.Setenv("PATH", buildpackDir)).To(Succeed())
+
+				packingTools = freezer.NewPackingTools()
+			})
+
+			it.After(func() {
+				Expect(os.Setenv("PATH", path)).To(Succeed())
+			})
+
+			it("kills jam and returns the context error", func() {
+				timeout, cancel := gocontext.WithTimeout(ctx, 100*time.Millisecond)
+				defer cancel()
+
+				start := time.Now()
+				err := packingTools.Execute(timeout, buildpackDir, "some-output", "some-version", false)
+				Expect(err).To(MatchError(gocontext.DeadlineExceeded))
+				Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+			})
+		})
+
 		context("failure cases", func() {
 			context("when the execution returns an error", func() {
 				it.Before(func() {
 					executable.ExecuteCall.Returns.Error = errors.New("some error")
 				})
 				it("returns an error", func() {
-					err := packingTools.Execute(buildpackDir, "some-output", "some-version", true)
+					err := packingTools.Execute(ctx, buildpackDir, "some-output", "some-version", true)
 					Expect(err).To(MatchError("some error"))
 				})
 			})
+
+			context("when the packager writes diagnostics before failing", func() {
+				var output *bytes.Buffer
+
+				it.Before(func() {
+					output = bytes.NewBuffer(nil)
+					packingTools = packingTools.WithOutput(output)
+
+					executable.ExecuteCall.Stub = func(_ gocontext.Context, execution pexec.Execution) error {
+						fmt.Fprintln(execution.Stdout, "Packing some-buildpack")
+						for i := 1; i <= 25; i++ {
+							fmt.Fprintf(execution.Stderr, "some diagnostic %d\n", i)
+						}
+						return errors.New("exit status 1")
+					}
+				})
+
+				it("includes the tail of the output in the error", func() {
+					err := packingTools.Execute(ctx, buildpackDir, "some-output", "some-version", true)
+					Expect(err).To(MatchError(ContainSubstring("exit status 1\nsome diagnostic 6\n")))
+					Expect(err).To(MatchError(HaveSuffix("some diagnostic 25")))
+					Expect(err).NotTo(MatchError(ContainSubstring("some diagnostic 5\n")))
+					Expect(err).NotTo(MatchError(ContainSubstring("Packing some-buildpack")))
+
+					Expect(output.String()).To(HavePrefix("Packing some-buildpack\nsome diagnostic 1\n"))
+				})
+			})
+		})
+	})
+
+	context("Validate", func() {
+		it("runs jam to confirm it is available", func() {
+			Expect(packingTools.Validate()).To(Succeed())
+
+			Expect(executable.ExecuteCall.Receives.Execution.Args).To(Equal([]string{"--help"}))
+		})
+
+		context("failure cases", func() {
+			context("when the executable is not on the path", func() {
+				var path string
+
+				it.Before(func() {
+					path = os.Getenv("PATH")
+					Expect(os.Setenv("PATH", buildpackDir)).To(Succeed())
+
+					packingTools = freezer.NewPackingTools()
+				})
+
+				it.After(func() {
+					Expect(os.Setenv("PATH", path)).To(Succeed())
+				})
+
+				it("returns a not found error", func() {
+					err := packingTools.Validate()
+					Expect(err).To(MatchError(ContainSubstring("jam not found")))
+					Expect(errors.Is(err, exec.ErrNotFound)).To(BeTrue())
+				})
+			})
+
+			context("when the executable fails to run", func() {
+				it.Before(func() {
+					executable.ExecuteCall.Returns.Error = errors.New("exit status 1")
+				})
+
+				it("returns an error", func() {
+					err := packingTools.Validate()
+					Expect(err).To(MatchError("jam is not runnable: exit status 1"))
+				})
+			})
 		})
 	})
 }
@@ -0,0 +1,110 @@
+package freezer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// RepoResolver maps a buildpack id, such as paketo-buildpacks/go-dist, to
+// the GitHub org and repo that publish it.
+type RepoResolver func(id string) (org, repo string, err error)
+
+type projectConfig struct {
+	Order []struct {
+		Group []struct {
+			ID      string `toml:"id"`
+			Version string `toml:"version"`
+		} `toml:"group"`
+	} `toml:"order"`
+
+	Dependencies []struct {
+		URI string `toml:"uri"`
+	} `toml:"dependencies"`
+}
+
+// LoadPinnedBuildpacks reads the buildpacks referenced by a buildpack.toml
+// order or a package.toml dependency list and returns them as remote
+// buildpacks pinned to the referenced versions. A referenced version becomes
+// the exact Constraint of its buildpack, so that the release tagged with it,
+// with or without a v prefix, is fetched rather than the latest one.
+func LoadPinnedBuildpacks(path string, resolve RepoResolver) ([]RemoteBuildpack, error) {
+	var config projectConfig
+	_, err := toml.DecodeFile(path, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	type reference struct{ id, version string }
+
+	var references []reference
+	for _, order := range config.Order {
+		for _, group := range order.Group {
+			references = append(references, reference{id: group.ID, version: group.Version})
+		}
+	}
+
+	for _, dependency := range config.Dependencies {
+		id, version, err := parseDependencyURI(dependency.URI)
+		if err != nil {
+			return nil, err
+		}
+		references = append(references, reference{id: id, version: version})
+	}
+
+	seen := map[reference]bool{}
+	var buildpacks []RemoteBuildpack
+	for _, ref := range references {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		org, repo, err := resolve(ref.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve repo for %s: %w", ref.id, err)
+		}
+
+		buildpack := NewRemoteBuildpack(org, repo)
+		if ref.version != "" {
+			buildpack = NewRemoteBuildpackWithConstraint(org, repo, ref.version)
+		}
+		buildpack.Version = ref.version
+		buildpacks = append(buildpacks, buildpack)
+	}
+
+	return buildpacks, nil
+}
+
+// parseDependencyURI understands the registry URNs and image references
+// found in package.toml, for example
+// urn:cnb:registry:paketo-buildpacks/go-dist@1.2.3 and
+// docker://gcr.io/paketo-buildpacks/go-dist:1.2.3. For image references
+// the id is the image path without its registry.
+func parseDependencyURI(uri string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(uri, "urn:cnb:registry:"):
+		ref := strings.TrimPrefix(uri, "urn:cnb:registry:")
+		if i := strings.LastIndex(ref, "@"); i > 0 {
+			return ref[:i], ref[i+1:], nil
+		}
+		return ref, "", nil
+
+	case strings.HasPrefix(uri, "docker://"):
+		ref := strings.TrimPrefix(uri, "docker://")
+
+		version := ""
+		if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+			ref, version = ref[:i], ref[i+1:]
+		}
+
+		if i := strings.Index(ref, "/"); i > 0 && strings.ContainsAny(ref[:i], ".:") {
+			ref = ref[i+1:]
+		}
+
+		return ref, version, nil
+	}
+
+	return "", "", fmt.Errorf("unsupported dependency uri: %q", uri)
+}
@@ -0,0 +1,220 @@
+package freezer_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/ForestEckhardt/freezer/fakes"
+	"github.com/ForestEckhardt/freezer/github"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPinnedBuildpacks(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		resolve    freezer.RepoResolver
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		resolve = func(id string) (string, string, error) {
+			parts := strings.SplitN(id, "/", 2)
+			return parts[0], parts[1], nil
+		}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("LoadPinnedBuildpacks", func() {
+		context("when given a buildpack.toml with an order", func() {
+			var path string
+
+			it.Before(func() {
+				path = filepath.Join(workingDir, "buildpack.toml")
+				Expect(os.WriteFile(path, []byte(`
+[buildpack]
+  id = "some-org/some-meta"
+
+[[order]]
+  [[order.group]]
+    id = "some-org/some-repo"
+    version = "1.2.3"
+
+  [[order.group]]
+    id = "other-org/other-repo"
+    version = "4.5.6"
+
+[[order]]
+  [[order.group]]
+    id = "some-org/some-repo"
+    version = "1.2.3"
+`), 0644)).To(Succeed())
+			})
+
+			it("returns deduplicated remote buildpacks pinned to the referenced versions", func() {
+				buildpacks, err := freezer.LoadPinnedBuildpacks(path, resolve)
+				Expect(err).NotTo(HaveOccurred())
+
+				someBuildpack := freezer.NewRemoteBuildpackWithConstraint("some-org", "some-repo", "1.2.3")
+				someBuildpack.Version = "1.2.3"
+				otherBuildpack := freezer.NewRemoteBuildpackWithConstraint("other-org", "other-repo", "4.5.6")
+				otherBuildpack.Version = "4.5.6"
+
+				Expect(buildpacks).To(Equal([]freezer.RemoteBuildpack{someBuildpack, otherBuildpack}))
+			})
+		})
+
+		context("when given a package.toml with dependencies", func() {
+			var path string
+
+			it.Before(func() {
+				path = filepath.Join(workingDir, "package.toml")
+				Expect(os.WriteFile(path, []byte(`
+[buildpack]
+  uri = "build/buildpack.tgz"
+
+[[dependencies]]
+  uri = "urn:cnb:registry:some-org/some-repo@1.2.3"
+
+[[dependencies]]
+  uri = "docker://gcr.io/other-org/other-repo:4.5.6"
+`), 0644)).To(Succeed())
+			})
+
+			it("returns remote buildpacks pinned to the referenced versions", func() {
+				buildpacks, err := freezer.LoadPinnedBuildpacks(path, resolve)
+				Expect(err).NotTo(HaveOccurred())
+
+				someBuildpack := freezer.NewRemoteBuildpackWithConstraint("some-org", "some-repo", "1.2.3")
+				someBuildpack.Version = "1.2.3"
+				otherBuildpack := freezer.NewRemoteBuildpackWithConstraint("other-org", "other-repo", "4.5.6")
+				otherBuildpack.Version = "4.5.6"
+
+				Expect(buildpacks).To(Equal([]freezer.RemoteBuildpack{someBuildpack, otherBuildpack}))
+			})
+		})
+
+		context("when a dependency has no version", func() {
+			it("returns a buildpack that follows the latest release", func() {
+				path := filepath.Join(workingDir, "package.toml")
+				Expect(os.WriteFile(path, []byte(`
+[[dependencies]]
+  uri = "urn:cnb:registry:some-org/some-repo"
+`), 0644)).To(Succeed())
+
+				buildpacks, err := freezer.LoadPinnedBuildpacks(path, resolve)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buildpacks).To(Equal([]freezer.RemoteBuildpack{freezer.NewRemoteBuildpack("some-org", "some-repo")}))
+			})
+		})
+
+		context("when the pinned buildpacks are fetched", func() {
+			var (
+				cacheDir          string
+				gitReleaseFetcher *fakes.GitReleaseFetcher
+				remoteFetcher     freezer.RemoteFetcher
+			)
+
+			it.Before(func() {
+				var err error
+				cacheDir, err = os.MkdirTemp("", "cache")
+				Expect(err).NotTo(HaveOccurred())
+
+				gitReleaseFetcher = &fakes.GitReleaseFetcher{}
+				gitReleaseFetcher.GetCall.Returns.Release = github.Release{
+					TagName: "v2.0.0",
+					Assets:  []github.ReleaseAsset{{URL: "some-latest-url"}},
+				}
+				gitReleaseFetcher.ListReleasesCall.Returns.ReleaseSlice = []github.Release{
+					{TagName: "v2.0.0", Assets: []github.ReleaseAsset{{URL: "some-latest-url"}}},
+					{TagName: "v1.2.3", Assets: []github.ReleaseAsset{{URL: "some-pinned-url"}}},
+					{TagName: "v1.2.2", Assets: []github.ReleaseAsset{{URL: "some-older-url"}}},
+				}
+				gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-artifact"))
+
+				buildpackCache := &fakes.BuildpackCache{}
+				buildpackCache.DirCall.Returns.String = cacheDir
+
+				remoteFetcher = freezer.NewRemoteFetcher(buildpackCache, gitReleaseFetcher, &fakes.Packager{}, freezer.NewFileSystem(os.MkdirTemp))
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(cacheDir)).To(Succeed())
+			})
+
+			it("downloads the referenced version rather than the latest", func() {
+				path := filepath.Join(workingDir, "package.toml")
+				Expect(os.WriteFile(path, []byte(`
+[[dependencies]]
+  uri = "urn:cnb:registry:some-org/some-repo@1.2.3"
+`), 0644)).To(Succeed())
+
+				buildpacks, err := freezer.LoadPinnedBuildpacks(path, resolve)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(buildpacks).To(HaveLen(1))
+
+				result, err := remoteFetcher.Fetch(buildpacks[0])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Version).To(Equal("v1.2.3"))
+				Expect(result.ResolvedURL).To(Equal("some-pinned-url"))
+
+				Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(0))
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset.URL).To(Equal("some-pinned-url"))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the file cannot be decoded", func() {
+				it("returns an error", func() {
+					path := filepath.Join(workingDir, "buildpack.toml")
+					Expect(os.WriteFile(path, []byte(`%%%`), 0644)).To(Succeed())
+
+					_, err := freezer.LoadPinnedBuildpacks(path, resolve)
+					Expect(err).To(MatchError(ContainSubstring("failed to decode")))
+				})
+			})
+
+			context("when a dependency uri is not supported", func() {
+				it("returns an error", func() {
+					path := filepath.Join(workingDir, "package.toml")
+					Expect(os.WriteFile(path, []byte(`
+[[dependencies]]
+  uri = "build/some-buildpack.tgz"
+`), 0644)).To(Succeed())
+
+					_, err := freezer.LoadPinnedBuildpacks(path, resolve)
+					Expect(err).To(MatchError(`unsupported dependency uri: "build/some-buildpack.tgz"`))
+				})
+			})
+
+			context("when the resolver fails", func() {
+				it("returns an error", func() {
+					path := filepath.Join(workingDir, "package.toml")
+					Expect(os.WriteFile(path, []byte(`
+[[dependencies]]
+  uri = "urn:cnb:registry:some-org/some-repo@1.2.3"
+`), 0644)).To(Succeed())
+
+					_, err := freezer.LoadPinnedBuildpacks(path, func(string) (string, string, error) {
+						return "", "", errors.New("failed to resolve")
+					})
+					Expect(err).To(MatchError("failed to resolve repo for some-org/some-repo: failed to resolve"))
+				})
+			})
+		})
+	})
+}
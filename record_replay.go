@@ -0,0 +1,214 @@
+package freezer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ForestEckhardt/freezer/github"
+)
+
+var ErrNotRecorded = errors.New("interaction was not recorded")
+
+// RecordingFetcher passes every call through to a GitReleaseFetcher and
+// saves the releases and downloaded bytes in a directory so that a
+// ReplayFetcher can serve them later without network access.
+type RecordingFetcher struct {
+	fetcher GitReleaseFetcher
+	dir     string
+}
+
+func NewRecordingFetcher(fetcher GitReleaseFetcher, dir string) RecordingFetcher {
+	return RecordingFetcher{
+		fetcher: fetcher,
+		dir:     dir,
+	}
+}
+
+func (r RecordingFetcher) Get(ctx context.Context, org, repo string) (github.Release, error) {
+	release, err := r.fetcher.Get(ctx, org, repo)
+	if err != nil {
+		return github.Release{}, err
+	}
+
+	return release, r.saveRelease(releaseInteraction(org, repo), release)
+}
+
+func (r RecordingFetcher) GetByID(ctx context.Context, org, repo string, id int64) (github.Release, error) {
+	release, err := r.fetcher.GetByID(ctx, org, repo, id)
+	if err != nil {
+		return github.Release{}, err
+	}
+
+	return release, r.saveRelease(releaseByIDInteraction(org, repo, id), release)
+}
+
+func (r RecordingFetcher) ListReleases(ctx context.Context, org, repo string) ([]github.Release, error) {
+	releases, err := r.fetcher.ListReleases(ctx, org, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return releases, r.saveRelease(releasesInteraction(org, repo), releases)
+}
+
+func (r RecordingFetcher) GetReleaseAsset(ctx context.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+	bundle, err := r.fetcher.GetReleaseAsset(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.saveBundle(assetInteraction(asset), bundle)
+}
+
+func (r RecordingFetcher) GetReleaseTarball(ctx context.Context, url string) (io.ReadCloser, error) {
+	bundle, err := r.fetcher.GetReleaseTarball(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.saveBundle(tarballInteraction(url), bundle)
+}
+
+func (r RecordingFetcher) saveRelease(interaction string, release interface{}) error {
+	err := os.MkdirAll(r.dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(release)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(interactionPath(r.dir, interaction), content, 0644)
+}
+
+func (r RecordingFetcher) saveBundle(interaction string, bundle io.ReadCloser) (io.ReadCloser, error) {
+	defer bundle.Close()
+
+	err := os.MkdirAll(r.dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	path := interactionPath(r.dir, interaction)
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(file, bundle)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+// ReplayFetcher serves the interactions saved by a RecordingFetcher and
+// returns ErrNotRecorded for anything else.
+type ReplayFetcher struct {
+	dir string
+}
+
+func NewReplayFetcher(dir string) ReplayFetcher {
+	return ReplayFetcher{
+		dir: dir,
+	}
+}
+
+func (r ReplayFetcher) Get(ctx context.Context, org, repo string) (github.Release, error) {
+	return r.loadRelease(releaseInteraction(org, repo))
+}
+
+func (r ReplayFetcher) GetByID(ctx context.Context, org, repo string, id int64) (github.Release, error) {
+	return r.loadRelease(releaseByIDInteraction(org, repo, id))
+}
+
+func (r ReplayFetcher) ListReleases(ctx context.Context, org, repo string) ([]github.Release, error) {
+	var releases []github.Release
+	err := r.load(releasesInteraction(org, repo), &releases)
+	if err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+func (r ReplayFetcher) GetReleaseAsset(ctx context.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+	return r.loadBundle(assetInteraction(asset))
+}
+
+func (r ReplayFetcher) GetReleaseTarball(ctx context.Context, url string) (io.ReadCloser, error) {
+	return r.loadBundle(tarballInteraction(url))
+}
+
+func (r ReplayFetcher) loadRelease(interaction string) (github.Release, error) {
+	var release github.Release
+	err := r.load(interaction, &release)
+	if err != nil {
+		return github.Release{}, err
+	}
+
+	return release, nil
+}
+
+func (r ReplayFetcher) load(interaction string, v interface{}) error {
+	content, err := os.ReadFile(interactionPath(r.dir, interaction))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s: %w", interaction, ErrNotRecorded)
+		}
+		return err
+	}
+
+	return json.Unmarshal(content, v)
+}
+
+func (r ReplayFetcher) loadBundle(interaction string) (io.ReadCloser, error) {
+	file, err := os.Open(interactionPath(r.dir, interaction))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", interaction, ErrNotRecorded)
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func releaseInteraction(org, repo string) string {
+	return fmt.Sprintf("release %s/%s", org, repo)
+}
+
+func releaseByIDInteraction(org, repo string, id int64) string {
+	return fmt.Sprintf("release %s/%s %d", org, repo, id)
+}
+
+func releasesInteraction(org, repo string) string {
+	return fmt.Sprintf("releases %s/%s", org, repo)
+}
+
+func assetInteraction(asset github.ReleaseAsset) string {
+	return fmt.Sprintf("asset %s", asset.URL)
+}
+
+func tarballInteraction(url string) string {
+	return fmt.Sprintf("tarball %s", url)
+}
+
+func interactionPath(dir, interaction string) string {
+	return filepath.Join(dir, fmt.Sprintf("%x", sha256.Sum256([]byte(interaction))))
+}
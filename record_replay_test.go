@@ -0,0 +1,142 @@
+package freezer_test
+
+import (
+	gocontext "context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/ForestEckhardt/freezer/fakes"
+	"github.com/ForestEckhardt/freezer/github"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRecordReplay(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+		ctx    = gocontext.Background()
+
+		recordDir         string
+		gitReleaseFetcher *fakes.GitReleaseFetcher
+		recorder          freezer.RecordingFetcher
+		replayer          freezer.ReplayFetcher
+	)
+
+	it.Before(func() {
+		var err error
+		recordDir, err = os.MkdirTemp("", "record")
+		Expect(err).NotTo(HaveOccurred())
+
+		gitReleaseFetcher = &fakes.GitReleaseFetcher{}
+		gitReleaseFetcher.GetCall.Returns.Release = github.Release{
+			TagName:    "some-tag",
+			Assets:     []github.ReleaseAsset{{URL: "some-url"}},
+			TarballURL: "some-tarball-url",
+		}
+		gitReleaseFetcher.GetByIDCall.Returns.Release = github.Release{
+			ID:      12345,
+			TagName: "some-pinned-tag",
+		}
+		gitReleaseFetcher.ListReleasesCall.Returns.ReleaseSlice = []github.Release{
+			{TagName: "some-tag"},
+			{TagName: "some-older-tag"},
+		}
+		gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-asset"))
+		gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-tarball"))
+
+		recorder = freezer.NewRecordingFetcher(gitReleaseFetcher, recordDir)
+		replayer = freezer.NewReplayFetcher(recordDir)
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(recordDir)).To(Succeed())
+	})
+
+	it("replays the interactions that were recorded", func() {
+		release, err := recorder.Get(ctx, "some-org", "some-repo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(release.TagName).To(Equal("some-tag"))
+
+		pinned, err := recorder.GetByID(ctx, "some-org", "some-repo", 12345)
+		Expect(err).NotTo(HaveOccurred())
+
+		releases, err := recorder.ListReleases(ctx, "some-org", "some-repo")
+		Expect(err).NotTo(HaveOccurred())
+
+		asset, err := recorder.GetReleaseAsset(ctx, release.Assets[0])
+		Expect(err).NotTo(HaveOccurred())
+		content, err := io.ReadAll(asset)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("some-asset"))
+		Expect(asset.Close()).To(Succeed())
+
+		tarball, err := recorder.GetReleaseTarball(ctx, release.TarballURL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tarball.Close()).To(Succeed())
+
+		replayedRelease, err := replayer.Get(ctx, "some-org", "some-repo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replayedRelease).To(Equal(release))
+
+		replayedPinned, err := replayer.GetByID(ctx, "some-org", "some-repo", 12345)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replayedPinned).To(Equal(pinned))
+
+		replayedReleases, err := replayer.ListReleases(ctx, "some-org", "some-repo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replayedReleases).To(Equal(releases))
+
+		replayedAsset, err := replayer.GetReleaseAsset(ctx, release.Assets[0])
+		Expect(err).NotTo(HaveOccurred())
+		content, err = io.ReadAll(replayedAsset)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("some-asset"))
+		Expect(replayedAsset.Close()).To(Succeed())
+
+		replayedTarball, err := replayer.GetReleaseTarball(ctx, release.TarballURL)
+		Expect(err).NotTo(HaveOccurred())
+		content, err = io.ReadAll(replayedTarball)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("some-tarball"))
+		Expect(replayedTarball.Close()).To(Succeed())
+
+		Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(1))
+		Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+		Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(1))
+	})
+
+	context("failure cases", func() {
+		context("when replaying an interaction that was not recorded", func() {
+			it("returns an error", func() {
+				_, err := replayer.Get(ctx, "some-org", "some-repo")
+				Expect(errors.Is(err, freezer.ErrNotRecorded)).To(BeTrue())
+				Expect(err).To(MatchError(ContainSubstring("release some-org/some-repo")))
+
+				_, err = replayer.GetReleaseAsset(ctx, github.ReleaseAsset{URL: "some-url"})
+				Expect(errors.Is(err, freezer.ErrNotRecorded)).To(BeTrue())
+
+				_, err = replayer.GetReleaseTarball(ctx, "some-tarball-url")
+				Expect(errors.Is(err, freezer.ErrNotRecorded)).To(BeTrue())
+			})
+		})
+
+		context("when the recorded fetcher fails", func() {
+			it.Before(func() {
+				gitReleaseFetcher.GetCall.Returns.Error = errors.New("unable to get release")
+			})
+
+			it("returns the error and records nothing", func() {
+				_, err := recorder.Get(ctx, "some-org", "some-repo")
+				Expect(err).To(MatchError("unable to get release"))
+
+				_, err = replayer.Get(ctx, "some-org", "some-repo")
+				Expect(errors.Is(err, freezer.ErrNotRecorded)).To(BeTrue())
+			})
+		})
+	})
+}
@@ -1,14 +1,38 @@
 package freezer
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
 
 type RemoteBuildpack struct {
+	// Host is the GitHub host the buildpack lives on. It is only set by
+	// ParseRemoteBuildpack.
+	Host string
+
 	Org         string
 	Repo        string
 	UncachedKey string
 	CachedKey   string
 	Offline     bool
 	Version     string
+	ReleaseID   int64
+
+	// Constraint is a semver range, such as ~1.2 or >=1.0 <2.0, that the
+	// fetched release must satisfy when set.
+	Constraint string
+
+	// AssetPolicy overrides the fetcher's asset policy for this buildpack
+	// when set.
+	AssetPolicy AssetPolicy
+
+	// CacheDir stores the artifacts of this buildpack beneath a directory
+	// other than the shared cache directory when set.
+	CacheDir string
+
+	ExpectedFiles []string
+	StrictFiles   bool
 }
 
 func NewRemoteBuildpack(org, repo string) RemoteBuildpack {
@@ -19,3 +43,76 @@ func NewRemoteBuildpack(org, repo string) RemoteBuildpack {
 		CachedKey:   fmt.Sprintf("%s:%s:cached", org, repo),
 	}
 }
+
+// NewRemoteBuildpackWithReleaseID pins the buildpack to a single GitHub
+// release, which avoids ambiguity when a tag has been reused.
+func NewRemoteBuildpackWithReleaseID(org, repo string, id int64) RemoteBuildpack {
+	buildpack := NewRemoteBuildpack(org, repo)
+	buildpack.ReleaseID = id
+	buildpack.UncachedKey = fmt.Sprintf("%s:%s:%d", org, repo, id)
+	buildpack.CachedKey = fmt.Sprintf("%s:%s:%d:cached", org, repo, id)
+
+	return buildpack
+}
+
+// NewRemoteBuildpackWithConstraint pins the buildpack to the highest release
+// whose tag satisfies the semver constraint, such as ~1.2 or >=1.0 <2.0.
+func NewRemoteBuildpackWithConstraint(org, repo, constraint string) RemoteBuildpack {
+	buildpack := NewRemoteBuildpack(org, repo)
+	buildpack.Constraint = constraint
+	buildpack.UncachedKey = fmt.Sprintf("%s:%s:%s", org, repo, constraint)
+	buildpack.CachedKey = fmt.Sprintf("%s:%s:%s:cached", org, repo, constraint)
+
+	return buildpack
+}
+
+// ParseRemoteBuildpack builds a RemoteBuildpack from a repository URL such
+// as https://github.com/org/repo. A .git suffix and anything after the
+// repository name, like /releases/tag/v1.2.3, are ignored. Buildpacks on
+// hosts other than github.com have the host added to their cache keys and
+// to the directory their artifacts are stored in.
+func ParseRemoteBuildpack(rawURL string) (RemoteBuildpack, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return RemoteBuildpack{}, fmt.Errorf("invalid buildpack url %q: %w", rawURL, err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return RemoteBuildpack{}, fmt.Errorf("invalid buildpack url %q: unsupported scheme %q", rawURL, u.Scheme)
+	}
+
+	if u.Host == "" {
+		return RemoteBuildpack{}, fmt.Errorf("invalid buildpack url %q: missing host", rawURL)
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 || segments[0] == "" || segments[1] == "" {
+		return RemoteBuildpack{}, fmt.Errorf("invalid buildpack url %q: expected a path of the form /org/repo", rawURL)
+	}
+
+	org := segments[0]
+	repo := strings.TrimSuffix(segments[1], ".git")
+	if repo == "" {
+		return RemoteBuildpack{}, fmt.Errorf("invalid buildpack url %q: expected a path of the form /org/repo", rawURL)
+	}
+
+	buildpack := NewRemoteBuildpack(org, repo)
+	buildpack.Host = u.Host
+
+	if !isGitHubHost(u.Host) {
+		buildpack.UncachedKey = fmt.Sprintf("%s:%s", u.Host, buildpack.UncachedKey)
+		buildpack.CachedKey = fmt.Sprintf("%s:%s", u.Host, buildpack.CachedKey)
+	}
+
+	return buildpack, nil
+}
+
+// isGitHubHost reports whether host is github.com, which is also what an
+// empty host stands for.
+func isGitHubHost(host string) bool {
+	return host == "" || strings.EqualFold(host, "github.com") || strings.EqualFold(host, "www.github.com")
+}
@@ -0,0 +1,73 @@
+package freezer_test
+
+import (
+	"testing"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testRemoteBuildpack(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseRemoteBuildpack", func() {
+		it("parses the org and repo out of repository urls", func() {
+			for _, url := range []string{
+				"https://github.com/some-org/some-repo",
+				"https://github.com/some-org/some-repo/",
+				"https://github.com/some-org/some-repo.git",
+				"https://github.com/some-org/some-repo/releases/tag/v1.2.3",
+				"http://github.com/some-org/some-repo",
+				"github.com/some-org/some-repo",
+			} {
+				buildpack, err := freezer.ParseRemoteBuildpack(url)
+				Expect(err).NotTo(HaveOccurred(), url)
+				Expect(buildpack).To(Equal(freezer.RemoteBuildpack{
+					Host:        "github.com",
+					Org:         "some-org",
+					Repo:        "some-repo",
+					UncachedKey: "some-org:some-repo",
+					CachedKey:   "some-org:some-repo:cached",
+				}), url)
+			}
+		})
+
+		it("adds enterprise hosts to the cache keys", func() {
+			buildpack, err := freezer.ParseRemoteBuildpack("https://github.example.com/some-org/some-repo")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buildpack.Host).To(Equal("github.example.com"))
+			Expect(buildpack.Org).To(Equal("some-org"))
+			Expect(buildpack.Repo).To(Equal("some-repo"))
+			Expect(buildpack.UncachedKey).To(Equal("github.example.com:some-org:some-repo"))
+			Expect(buildpack.CachedKey).To(Equal("github.example.com:some-org:some-repo:cached"))
+		})
+
+		context("failure cases", func() {
+			it("rejects urls without an org and repo", func() {
+				_, err := freezer.ParseRemoteBuildpack("https://github.com/some-org")
+				Expect(err).To(MatchError(`invalid buildpack url "https://github.com/some-org": expected a path of the form /org/repo`))
+
+				_, err = freezer.ParseRemoteBuildpack("https://github.com/some-org/.git")
+				Expect(err).To(MatchError(ContainSubstring("expected a path of the form /org/repo")))
+			})
+
+			it("rejects unsupported schemes", func() {
+				_, err := freezer.ParseRemoteBuildpack("ftp://github.com/some-org/some-repo")
+				Expect(err).To(MatchError(`invalid buildpack url "ftp://github.com/some-org/some-repo": unsupported scheme "ftp"`))
+			})
+
+			it("rejects urls without a host", func() {
+				_, err := freezer.ParseRemoteBuildpack("https:///some-org/some-repo")
+				Expect(err).To(MatchError(`invalid buildpack url "https:///some-org/some-repo": missing host`))
+			})
+
+			it("rejects urls that cannot be parsed", func() {
+				_, err := freezer.ParseRemoteBuildpack("https://github.com/some-org/some-repo%zz")
+				Expect(err).To(MatchError(ContainSubstring("invalid buildpack url")))
+			})
+		})
+	})
+}
@@ -1,25 +1,53 @@
 package freezer
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/ForestEckhardt/freezer/github"
+	"github.com/Masterminds/semver/v3"
 	"github.com/paketo-buildpacks/packit/v2/vacation"
 )
 
 //go:generate faux --interface GitReleaseFetcher --output fakes/git_release_fetcher.go
 type GitReleaseFetcher interface {
-	Get(org, repo string) (github.Release, error)
-	GetReleaseAsset(asset github.ReleaseAsset) (io.ReadCloser, error)
-	GetReleaseTarball(url string) (io.ReadCloser, error)
+	Get(ctx context.Context, org, repo string) (github.Release, error)
+	GetByID(ctx context.Context, org, repo string, id int64) (github.Release, error)
+	ListReleases(ctx context.Context, org, repo string) ([]github.Release, error)
+	GetReleaseAsset(ctx context.Context, asset github.ReleaseAsset) (io.ReadCloser, error)
+	GetReleaseTarball(ctx context.Context, url string) (io.ReadCloser, error)
+}
+
+// GitTagLister is implemented by GitReleaseFetchers that can list the git
+// tags of a repository, such as github.ReleaseService. WithTagFallback
+// needs it.
+type GitTagLister interface {
+	ListTags(ctx context.Context, org, repo string) ([]github.Release, error)
+}
+
+// UsageRecorder is implemented by BuildpackCaches that track when their
+// entries were last used, such as CacheManager. RemoteFetcher reports every
+// cache hit to it.
+type UsageRecorder interface {
+	RecordUse(key string)
 }
 
 //go:generate faux --interface Packager --output fakes/packager.go
 type Packager interface {
-	Execute(buildpackDir, output, version string, cached bool) error
+	Execute(ctx context.Context, buildpackDir, output, version string, cached bool) error
 }
 
 //go:generate faux --interface BuildpackCache --output fakes/buildpack_cache.go
@@ -29,11 +57,137 @@ type BuildpackCache interface {
 	Dir() string
 }
 
+//go:generate faux --interface Publisher --output fakes/publisher.go
+type Publisher interface {
+	Publish(path, reference string) (string, error)
+}
+
+// AssetPolicy decides whether a buildpack is built from a release's source
+// tarball or taken from one of its uploaded assets.
+type AssetPolicy string
+
+const (
+	// AssetPolicyPreferArtifact uses the first release asset for uncached
+	// buildpacks and builds cached buildpacks, or releases without assets,
+	// from source.
+	AssetPolicyPreferArtifact AssetPolicy = "prefer-artifact"
+
+	// AssetPolicyAlwaysSource always builds from the source tarball.
+	AssetPolicyAlwaysSource AssetPolicy = "always-source"
+
+	// AssetPolicySourceIfNoCachedAsset behaves like AssetPolicyPreferArtifact
+	// except that cached buildpacks use a release asset with "cached" in its
+	// name when one is present.
+	AssetPolicySourceIfNoCachedAsset AssetPolicy = "source-if-no-cached-asset"
+)
+
+type UpdateState string
+
+const (
+	UpToDate UpdateState = "up-to-date"
+	Outdated UpdateState = "outdated"
+	Missing  UpdateState = "missing"
+)
+
+type UpdateStatus struct {
+	Buildpack     RemoteBuildpack
+	CachedVersion string
+	LatestVersion string
+	State         UpdateState
+
+	// Reason is why Fetch would or would not download the buildpack again.
+	// State is derived from it.
+	Reason FetchReason
+}
+
+// FetchReason explains why Fetch did or did not download a buildpack.
+type FetchReason string
+
+const (
+	FetchReasonCached         FetchReason = "cached"
+	FetchReasonNotCached      FetchReason = "not-cached"
+	FetchReasonFileMissing    FetchReason = "file-missing"
+	FetchReasonVersionChanged FetchReason = "version-changed"
+	FetchReasonMismatched     FetchReason = "mismatched"
+	FetchReasonCorrupted      FetchReason = "corrupted"
+	FetchReasonExpired        FetchReason = "expired"
+	FetchReasonUnpublished    FetchReason = "unpublished"
+)
+
+type FetchResult struct {
+	URI           string
+	Version       string
+	CachedVersion string
+	Fetched       bool
+	Reason        FetchReason
+
+	// ResolvedURL is the url of the release asset or source tarball that the
+	// fetched artifact was downloaded from. It is empty when nothing was
+	// downloaded.
+	ResolvedURL string
+
+	// Files lists the regular files in the downloaded archive when the
+	// fetcher was configured WithFileListing. It is empty when the buildpack
+	// was packaged from a source kept by WithSourceReuse.
+	Files []ArchiveEntry
+
+	// OptionalAssets maps the name of each optional asset that was
+	// downloaded to its path.
+	OptionalAssets map[string]string
+
+	// Warnings describes optional assets that were missing or could not be
+	// downloaded. They do not fail the fetch.
+	Warnings []string
+}
+
 type RemoteFetcher struct {
 	buildpackCache    BuildpackCache
 	gitReleaseFetcher GitReleaseFetcher
 	packager          Packager
 	fileSystem        FileSystem
+	clock             Clock
+	ttl               time.Duration
+	rejectPrerelease  bool
+	publisher         Publisher
+	registry          string
+	assetPolicy       AssetPolicy
+	verifyRepository  bool
+	namespace         string
+	tarballEndpoint   string
+	acceptedFormats   []ArchiveFormat
+	listFiles         bool
+	requireBuildpack  bool
+	packRetries       int
+	packBackoff       time.Duration
+	packTransient     func(error) bool
+	checksumFile      bool
+	modeMask          os.FileMode
+	pinned            map[string]github.Release
+	minSize           int64
+	cachedSuffix      string
+	cachedDir         string
+	reuseOutput       bool
+	optionalAssets    []string
+	fsync             bool
+	tolerateReadOnly  bool
+	flights           *fetchGroup
+	sources           *sourceStore
+	versionedLayout   bool
+	cacheMetadata     bool
+	releasePredicate  func(github.Release) bool
+	tarballRetries    int
+	tarballBackoff    time.Duration
+	identityKeys      bool
+	concurrency       int
+	extractWorkers    int
+	progress          func(downloaded, total int64)
+	hostFetchers      map[string]GitReleaseFetcher
+	skipCachedCheck   bool
+	tagFallback       bool
+
+	// ctx is the context of the fetch in progress. It is only set on the
+	// copy of the fetcher that FetchWithContext works with.
+	ctx context.Context
 }
 
 func NewRemoteFetcher(buildpackCache BuildpackCache, gitReleaseFetcher GitReleaseFetcher, packager Packager, fileSystem FileSystem) RemoteFetcher {
@@ -42,6 +196,8 @@ func NewRemoteFetcher(buildpackCache BuildpackCache, gitReleaseFetcher GitReleas
 		gitReleaseFetcher: gitReleaseFetcher,
 		packager:          packager,
 		fileSystem:        fileSystem,
+		clock:             NewSystemClock(),
+		minSize:           1,
 	}
 }
 
@@ -50,93 +206,1852 @@ func (r RemoteFetcher) WithPackager(packager Packager) RemoteFetcher {
 	return r
 }
 
+func (r RemoteFetcher) WithClock(clock Clock) RemoteFetcher {
+	r.clock = clock
+	return r
+}
+
+// WithTTL makes cache entries older than ttl be refetched even when their
+// version matches the latest release. A zero ttl never expires entries.
+func (r RemoteFetcher) WithTTL(ttl time.Duration) RemoteFetcher {
+	r.ttl = ttl
+	return r
+}
+
+// WithRejectPrerelease makes Get fail when the selected release is a
+// pre-release instead of fetching it.
+func (r RemoteFetcher) WithRejectPrerelease(reject bool) RemoteFetcher {
+	r.rejectPrerelease = reject
+	return r
+}
+
+// WithReleasePredicate changes which release counts as the latest: rather
+// than the release GitHub marks as latest, Get takes the newest release,
+// in the order GitHub lists them, that is not a draft and satisfies
+// predicate. It has no effect on buildpacks pinned to a release ID.
+func (r RemoteFetcher) WithReleasePredicate(predicate func(github.Release) bool) RemoteFetcher {
+	r.releasePredicate = predicate
+	return r
+}
+
+// WithPublisher pushes every fetched buildpack to
+// <registry>/<org>/<repo>:<tag> and reports the pushed reference as its URI.
+func (r RemoteFetcher) WithPublisher(publisher Publisher, registry string) RemoteFetcher {
+	r.publisher = publisher
+	r.registry = registry
+	return r
+}
+
+// WithAssetPolicy sets the asset policy for buildpacks that do not set
+// their own.
+func (r RemoteFetcher) WithAssetPolicy(policy AssetPolicy) RemoteFetcher {
+	r.assetPolicy = policy
+	return r
+}
+
+// WithVerifyRepository makes Get fail when the html_url of a release does
+// not point at the requested org and repo, which guards against mirrors
+// serving releases from the wrong repository.
+func (r RemoteFetcher) WithVerifyRepository(verify bool) RemoteFetcher {
+	r.verifyRepository = verify
+	return r
+}
+
+// WithNamespace prefixes cache keys with "<namespace>/" and stores
+// artifacts beneath <cache dir>/<namespace> so that independent users of a
+// shared cache directory do not collide.
+func (r RemoteFetcher) WithNamespace(namespace string) RemoteFetcher {
+	r.namespace = namespace
+	return r
+}
+
+// WithTarballFallback builds the source tarball url from the GitHub API
+// endpoint, org, repo and tag when a release does not provide one, or when
+// the url it provides is not found. Without it such releases cannot be
+// built from source and Get returns an error.
+func (r RemoteFetcher) WithTarballFallback(endpoint string) RemoteFetcher {
+	r.tarballEndpoint = strings.TrimSuffix(endpoint, "/")
+	return r
+}
+
+// WithTarballNotFoundRetries retries a source tarball download that is not
+// found up to retries times, doubling backoff after each attempt, before
+// treating the tarball as missing. GitHub occasionally 404s on tarballs of
+// releases that do exist.
+func (r RemoteFetcher) WithTarballNotFoundRetries(retries int, backoff time.Duration) RemoteFetcher {
+	r.tarballRetries = retries
+	r.tarballBackoff = backoff
+	return r
+}
+
+// WithAcceptedFormats rejects downloads whose content is not one of the
+// given archive formats, such as an HTML error page served with a 200. By
+// default any content is accepted.
+func (r RemoteFetcher) WithAcceptedFormats(formats ...ArchiveFormat) RemoteFetcher {
+	r.acceptedFormats = formats
+	return r
+}
+
+// WithFileListing makes Fetch report the files in each downloaded archive.
+// The listing is collected while the archive is extracted or copied.
+func (r RemoteFetcher) WithFileListing(list bool) RemoteFetcher {
+	r.listFiles = list
+	return r
+}
+
+// WithBuildpackCheck rejects downloads that do not contain a buildpack.toml
+// at their root or at the root of their single top-level directory, such as
+// a mis-selected documentation asset, before they are packaged or cached.
+func (r RemoteFetcher) WithBuildpackCheck(check bool) RemoteFetcher {
+	r.requireBuildpack = check
+	return r
+}
+
+// WithPackagingRetries retries a failed packaging step up to retries times,
+// waiting backoff before the first retry and doubling it after each. Only
+// errors that transient reports as transient are retried; a nil transient
+// retries every error.
+func (r RemoteFetcher) WithPackagingRetries(retries int, backoff time.Duration, transient func(error) bool) RemoteFetcher {
+	r.packRetries = retries
+	r.packBackoff = backoff
+	r.packTransient = transient
+	return r
+}
+
+// WithChecksumFile writes an <artifact>.sha256 file in sha256sum format next
+// to every artifact that is cached, so that other tools can verify it.
+func (r RemoteFetcher) WithChecksumFile(write bool) RemoteFetcher {
+	r.checksumFile = write
+	return r
+}
+
+// WithModeMask clears the given mode bits, for example
+// os.ModeSetuid|os.ModeSetgid|0002, from every file and directory extracted
+// from a source tarball before it is packaged. Modes are kept as they are by
+// default.
+func (r RemoteFetcher) WithModeMask(mask os.FileMode) RemoteFetcher {
+	r.modeMask = mask
+	return r
+}
+
+// WithMinimumSize rejects downloads smaller than size bytes, which usually
+// means a release asset was published empty. It defaults to 1 byte.
+func (r RemoteFetcher) WithMinimumSize(size int64) RemoteFetcher {
+	r.minSize = size
+	return r
+}
+
+// WithCachedVariant names the cached variant of buildpacks differently, for
+// sharing a cache with a tool that uses other conventions. Its cache keys
+// become the uncached key followed by keySuffix, and its artifacts are
+// stored in dir instead of "cached". Empty values keep the defaults.
+func (r RemoteFetcher) WithCachedVariant(keySuffix, dir string) RemoteFetcher {
+	r.cachedSuffix = keySuffix
+	r.cachedDir = dir
+	return r
+}
+
+// WithOutputReuse skips downloading and packaging a source tarball when the
+// artifact it would produce is already cached and still matches its
+// recorded digest, for instance when only the ttl of its entry ran out.
+func (r RemoteFetcher) WithOutputReuse(reuse bool) RemoteFetcher {
+	r.reuseOutput = reuse
+	return r
+}
+
+// WithOptionalAssets also downloads the release assets with the given names,
+// such as an SBOM or a signature, next to the artifact whenever it is
+// fetched. Assets that are missing or fail to download are reported in
+// FetchResult.Warnings instead of failing the fetch.
+func (r RemoteFetcher) WithOptionalAssets(names ...string) RemoteFetcher {
+	r.optionalAssets = names
+	return r
+}
+
+// WithFsync flushes every artifact and its directory to disk before it is
+// recorded in the cache, so that a crash cannot leave an entry pointing at
+// a truncated file. It is off by default as it slows down fetching.
+func (r RemoteFetcher) WithFsync(fsync bool) RemoteFetcher {
+	r.fsync = fsync
+	return r
+}
+
+// WithReadOnlyTolerance ignores a failure to update the cache entry when
+// nothing had to be downloaded because the artifact on disk was already
+// valid, which lets a read-only cache keep serving requests. Failures to
+// record newly downloaded artifacts are still returned.
+func (r RemoteFetcher) WithReadOnlyTolerance(tolerate bool) RemoteFetcher {
+	r.tolerateReadOnly = tolerate
+	return r
+}
+
+// WithDeduplication makes concurrent fetches of the same buildpack variant
+// through this fetcher, or copies of it made afterwards, share a single
+// download and packaging and all receive its result.
+func (r RemoteFetcher) WithDeduplication(dedupe bool) RemoteFetcher {
+	r.flights = nil
+	if dedupe {
+		r.flights = &fetchGroup{}
+	}
+	return r
+}
+
+// WithVersionedLayout stores each release at <version>/buildpack.tgz
+// rather than <version>.tgz, giving every version a directory of its own.
+// Pair it with CacheManager.WithHistory to keep the versions it replaces.
+func (r RemoteFetcher) WithVersionedLayout(versioned bool) RemoteFetcher {
+	r.versionedLayout = versioned
+	return r
+}
+
+// WithMetadataCache saves the buildpack.toml of every fetched artifact next
+// to it, so that Metadata can answer without downloading anything.
+func (r RemoteFetcher) WithMetadataCache(cache bool) RemoteFetcher {
+	r.cacheMetadata = cache
+	return r
+}
+
+// WithIdentityKeys also records every fetched artifact under the id@version
+// declared in its buildpack.toml, with a :cached suffix for the cached
+// variant. When another repository already produced an artifact for the
+// same id@version and tag, that artifact is shared rather than keeping a
+// second copy. It stays on disk until no entry refers to it.
+func (r RemoteFetcher) WithIdentityKeys(identity bool) RemoteFetcher {
+	r.identityKeys = identity
+	return r
+}
+
+// WithSourceReuse keeps the source tarball extracted for one variant of a
+// release so that fetching the other variant of the same release packages
+// it again without downloading it. Each kept source is used once; call
+// DiscardSources to remove those that were never used.
+func (r RemoteFetcher) WithSourceReuse(reuse bool) RemoteFetcher {
+	r.sources = nil
+	if reuse {
+		r.sources = &sourceStore{}
+	}
+	return r
+}
+
+// WithConcurrency sets how many buildpacks GetAll fetches at once. It
+// defaults to GOMAXPROCS.
+func (r RemoteFetcher) WithConcurrency(concurrency int) RemoteFetcher {
+	r.concurrency = concurrency
+	return r
+}
+
+// WithExtractionConcurrency extracts source tarballs with up to
+// concurrency writers for their regular files instead of one after the
+// other, which speeds up archives with many files on fast disks.
+// Directories are still created before the files beneath them and links
+// after every file. Only gzip-compressed and plain tarballs can be
+// extracted this way.
+func (r RemoteFetcher) WithExtractionConcurrency(concurrency int) RemoteFetcher {
+	r.extractWorkers = concurrency
+	return r
+}
+
+// WithProgress calls progress as the asset or source tarball of a buildpack
+// is downloaded, with the number of bytes read so far and the size reported
+// by the server, or -1 when the size is unknown.
+func (r RemoteFetcher) WithProgress(progress func(downloaded, total int64)) RemoteFetcher {
+	r.progress = progress
+	return r
+}
+
+// WithHostFetcher fetches the releases of buildpacks whose Host is host,
+// such as a self-hosted GitLab, from fetcher instead of the fetcher the
+// RemoteFetcher was created with. ParseRemoteBuildpack sets the Host of a
+// buildpack from its url.
+func (r RemoteFetcher) WithHostFetcher(host string, fetcher GitReleaseFetcher) RemoteFetcher {
+	hostFetchers := map[string]GitReleaseFetcher{}
+	for h, f := range r.hostFetchers {
+		hostFetchers[h] = f
+	}
+	hostFetchers[strings.ToLower(host)] = fetcher
+
+	r.hostFetchers = hostFetchers
+	return r
+}
+
+// WithCachedArtifactCheck(false) turns off the check that a cache hit makes
+// by default: that the cached artifact still exists and, when the entry
+// records a digest, that it still matches it. A missing or altered artifact
+// is downloaded again.
+func (r RemoteFetcher) WithCachedArtifactCheck(check bool) RemoteFetcher {
+	r.skipCachedCheck = !check
+	return r
+}
+
+// WithTagFallback makes a buildpack with a version Constraint that no
+// release satisfies fall back to the git tags of its repository, for
+// repositories that push tags without creating releases. The highest
+// satisfying tag is packaged from its source tarball. The release fetcher
+// must implement GitTagLister.
+func (r RemoteFetcher) WithTagFallback(fallback bool) RemoteFetcher {
+	r.tagFallback = fallback
+	return r
+}
+
+// DiscardSources removes the sources kept by WithSourceReuse.
+func (r RemoteFetcher) DiscardSources() error {
+	if r.sources == nil {
+		return nil
+	}
+
+	return r.sources.discard()
+}
+
 func (r RemoteFetcher) Get(buildpack RemoteBuildpack) (string, error) {
-	release, err := r.gitReleaseFetcher.Get(buildpack.Org, buildpack.Repo)
+	return r.GetWithContext(context.Background(), buildpack)
+}
+
+// GetWithContext behaves like Get but gives up once ctx is done, returning
+// an error that wraps ctx.Err() and removing any partial download.
+func (r RemoteFetcher) GetWithContext(ctx context.Context, buildpack RemoteBuildpack) (string, error) {
+	result, err := r.FetchWithContext(ctx, buildpack)
 	if err != nil {
 		return "", err
 	}
 
-	buildpackCacheDir := filepath.Join(r.buildpackCache.Dir(), buildpack.Org, buildpack.Repo)
-	if buildpack.Offline {
-		buildpackCacheDir = filepath.Join(buildpackCacheDir, "cached")
+	return result.URI, nil
+}
+
+// GetAll gets the uncached or cached variant of every buildpack, running up
+// to the configured concurrency of fetches at once. The paths are returned as
+// a slice in the order of buildpacks, with an empty path for every buildpack
+// that failed. They cannot be keyed by buildpack because a RemoteBuildpack
+// holds a slice, ExpectedFiles, and is therefore not comparable. A failure
+// does not stop the other fetches; they are all reported together in a
+// BatchError. Access to the cache is serialized and buildpacks sharing a
+// cache key are fetched one after the other.
+func (r RemoteFetcher) GetAll(buildpacks []RemoteBuildpack, cached bool) ([]string, error) {
+	concurrency := r.concurrency
+	if concurrency < 1 {
+		concurrency = runtime.GOMAXPROCS(0)
 	}
 
-	key := buildpack.UncachedKey
-	if buildpack.Offline {
-		key = buildpack.CachedKey
+	r.buildpackCache = &syncCache{cache: r.buildpackCache}
+
+	var (
+		uris    = make([]string, len(buildpacks))
+		errs    = make([]error, len(buildpacks))
+		indices = make(chan int)
+		locks   keyLocks
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				buildpack := buildpacks[index]
+				buildpack.Offline = cached
+
+				unlock := locks.lock(r.key(buildpack))
+				uris[index], errs[index] = r.Get(buildpack)
+				unlock()
+			}
+		}()
 	}
 
-	cachedEntry, exist, err := r.buildpackCache.Get(key)
-	if err != nil {
-		return "", err
+	for i := range buildpacks {
+		indices <- i
 	}
+	close(indices)
+	wg.Wait()
 
-	if !exist {
-		err = os.MkdirAll(buildpackCacheDir, os.ModePerm)
+	var batchErr BatchError
+	for i, err := range errs {
 		if err != nil {
-			return "", err
+			buildpack := buildpacks[i]
+			buildpack.Offline = cached
+			batchErr.Failures = append(batchErr.Failures, BatchFailure{Buildpack: buildpack, Err: err})
 		}
 	}
 
-	path := cachedEntry.URI
+	if len(batchErr.Failures) > 0 {
+		return uris, &batchErr
+	}
 
-	if release.TagName != cachedEntry.Version || !exist {
-		missingReleaseArtifacts := !(len(release.Assets) > 0)
-		var bundle io.ReadCloser
-		if missingReleaseArtifacts || buildpack.Offline {
-			bundle, err = r.gitReleaseFetcher.GetReleaseTarball(release.TarballURL)
+	return uris, nil
+}
+
+// Fetch behaves like Get but also reports whether the buildpack was
+// downloaded and why.
+func (r RemoteFetcher) Fetch(buildpack RemoteBuildpack) (FetchResult, error) {
+	return r.FetchWithContext(context.Background(), buildpack)
+}
+
+// FetchWithContext behaves like Fetch but gives up once ctx is done. With
+// WithDeduplication, fetches that join one already in flight share the
+// context of the first.
+func (r RemoteFetcher) FetchWithContext(ctx context.Context, buildpack RemoteBuildpack) (FetchResult, error) {
+	err := ctx.Err()
+	if err != nil {
+		return FetchResult{}, &ResolveError{Buildpack: buildpack, Err: err}
+	}
+
+	r = r.forHost(buildpack)
+	r.ctx = ctx
+
+	if r.flights == nil {
+		return r.fetch(buildpack)
+	}
+
+	key := fmt.Sprintf("%s\x00%s", r.key(buildpack), buildpack.CacheDir)
+	return r.flights.do(key, func() (FetchResult, error) {
+		return r.fetch(buildpack)
+	})
+}
+
+func (r RemoteFetcher) fetch(buildpack RemoteBuildpack) (FetchResult, error) {
+	release, err := r.release(buildpack)
+	if err != nil {
+		return FetchResult{}, &ResolveError{Buildpack: buildpack, Err: err}
+	}
+
+	buildpackCacheDir := r.variantDir(buildpack)
+
+	cachedEntry, exist, err := r.buildpackCache.Get(r.key(buildpack))
+	if err != nil {
+		return FetchResult{}, &CacheError{Buildpack: buildpack, Err: err}
+	}
+
+	result := FetchResult{
+		URI:           cachedEntry.URI,
+		Version:       release.TagName,
+		CachedVersion: cachedEntry.Version,
+		Reason:        r.fetchReason(release, cachedEntry, exist),
+	}
+
+	if r.publisher != nil {
+		result.URI = cachedEntry.Reference
+	}
+
+	if result.Reason != FetchReasonCached {
+		path := r.artifactPath(buildpack, release.TagName)
+
+		err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err != nil {
+			return FetchResult{}, &CacheError{Buildpack: buildpack, Err: err}
+		}
+
+		asset, useAsset := r.selectAsset(buildpack, release)
+
+		var published string
+		reused := r.reuseOutput && !useAsset && validArtifact(cachedEntry, path)
+		if !reused {
+			// The artifact at path may be the one the cache points at, so it
+			// is only replaced once the new one is complete and checked
+			staged, discard, err := stagingPath(path)
 			if err != nil {
-				return "", err
+				return FetchResult{}, &CacheError{Buildpack: buildpack, Err: err}
 			}
-		} else {
-			bundle, err = r.gitReleaseFetcher.GetReleaseAsset(release.Assets[0])
+			defer discard()
+
+			result.Files, result.ResolvedURL, err = r.download(buildpack, release, asset, useAsset, staged)
 			if err != nil {
-				return "", err
+				return FetchResult{}, err
 			}
-		}
 
-		path = filepath.Join(buildpackCacheDir, fmt.Sprintf("%s.tgz", release.TagName))
+			if useAsset {
+				published, err = r.verifyPublishedDigest(release, asset, staged)
+				if err != nil {
+					return FetchResult{}, &DownloadError{Buildpack: buildpack, Err: err}
+				}
+			}
 
-		if missingReleaseArtifacts || buildpack.Offline {
-			downloadDir, err := r.fileSystem.TempDir("", buildpack.Repo)
+			err = os.Rename(staged, path)
 			if err != nil {
-				return "", err
+				return FetchResult{}, &CacheError{Buildpack: buildpack, Err: err}
+			}
+
+			if r.fsync {
+				err = syncPath(path)
+				if err != nil {
+					return FetchResult{}, &CacheError{Buildpack: buildpack, Err: fmt.Errorf("failed to sync %s: %w", path, err)}
+				}
 			}
-			defer os.RemoveAll(downloadDir)
+		}
 
-			err = vacation.NewArchive(bundle).StripComponents(1).Decompress(downloadDir)
+		var identity string
+		if r.identityKeys {
+			identity, path, err = r.shareByIdentity(buildpack, release, path)
 			if err != nil {
-				return "", err
+				return FetchResult{}, err
 			}
+		}
+
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return FetchResult{}, &CacheError{Buildpack: buildpack, Err: err}
+		}
 
-			err = r.packager.Execute(downloadDir, path, release.TagName, buildpack.Offline)
+		if r.cacheMetadata {
+			err = r.saveMetadata(buildpack, release.TagName, path)
 			if err != nil {
-				return "", err
+				return FetchResult{}, &ExtractError{Buildpack: buildpack, Err: err}
 			}
+		}
 
-		} else {
-			file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		result.OptionalAssets, result.Warnings = r.downloadOptionalAssets(buildpack, release, buildpackCacheDir)
+
+		if r.checksumFile {
+			err = os.WriteFile(path+".sha256", []byte(fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))), 0644)
+			if err != nil {
+				return FetchResult{}, &CacheError{Buildpack: buildpack, Err: err}
+			}
+		}
+
+		entry := CacheEntry{
+			Version:         release.TagName,
+			URI:             path,
+			FetchedAt:       r.clock.Now(),
+			PublishedAt:     release.PublishedAt,
+			SHA256:          sum,
+			PublishedSHA256: published,
+		}
+
+		result.URI = path
+
+		if r.publisher != nil {
+			tag := release.TagName
+			if buildpack.Offline {
+				tag = fmt.Sprintf("%s-cached", tag)
+			}
+
+			entry.Reference, err = r.publisher.Publish(path, fmt.Sprintf("%s/%s/%s:%s", r.registry, buildpack.Org, buildpack.Repo, tag))
 			if err != nil {
-				return "", err
+				return FetchResult{}, fmt.Errorf("failed to publish buildpack: %w", err)
+			}
+
+			result.URI = entry.Reference
+		}
+
+		err = r.buildpackCache.Set(r.key(buildpack), entry)
+		if err != nil {
+			if reused && r.tolerateReadOnly {
+				return result, nil
 			}
-			defer file.Close()
+			return FetchResult{}, &CacheError{Buildpack: buildpack, Err: err}
+		}
 
-			_, err = io.Copy(file, bundle)
+		if identity != "" {
+			err = r.buildpackCache.Set(identity, entry)
 			if err != nil {
-				return "", err
+				return FetchResult{}, &CacheError{Buildpack: buildpack, Err: err}
 			}
 		}
+		result.Fetched = true
+	} else if recorder, ok := r.buildpackCache.(UsageRecorder); ok {
+		recorder.RecordUse(r.key(buildpack))
+	}
 
-		err = r.buildpackCache.Set(key, CacheEntry{
-			Version: release.TagName,
-			URI:     path,
-		})
+	return result, nil
+}
 
+// CheckUpdates reports for every buildpack whether Fetch would download it
+// again, without downloading, packaging or changing the cache. A buildpack
+// is Missing when it has no cached artifact, UpToDate when Fetch would use
+// the cached one and Outdated otherwise, which includes an expired or
+// corrupted artifact of the latest version.
+func (r RemoteFetcher) CheckUpdates(buildpacks []RemoteBuildpack) ([]UpdateStatus, error) {
+	var statuses []UpdateStatus
+	for _, buildpack := range buildpacks {
+		release, err := r.forHost(buildpack).release(buildpack)
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+
+		cachedEntry, exist, err := r.buildpackCache.Get(r.key(buildpack))
+		if err != nil {
+			return nil, err
+		}
+
+		status := UpdateStatus{
+			Buildpack:     buildpack,
+			CachedVersion: cachedEntry.Version,
+			LatestVersion: release.TagName,
+			State:         Outdated,
+			Reason:        r.fetchReason(release, cachedEntry, exist),
 		}
 
+		switch status.Reason {
+		case FetchReasonCached:
+			status.State = UpToDate
+		case FetchReasonNotCached, FetchReasonFileMissing:
+			status.State = Missing
+		}
+
+		statuses = append(statuses, status)
 	}
 
-	return path, nil
+	return statuses, nil
+}
+
+// IsStale reports whether Fetch would download the uncached or cached
+// variant of a buildpack again, without downloading, packaging or changing
+// the cache.
+func (r RemoteFetcher) IsStale(buildpack RemoteBuildpack, cached bool) (bool, error) {
+	r = r.forHost(buildpack)
+	buildpack.Offline = cached
+
+	release, err := r.release(buildpack)
+	if err != nil {
+		return false, &ResolveError{Buildpack: buildpack, Err: err}
+	}
+
+	cachedEntry, exist, err := r.buildpackCache.Get(r.key(buildpack))
+	if err != nil {
+		return false, &CacheError{Buildpack: buildpack, Err: err}
+	}
+
+	return r.fetchReason(release, cachedEntry, exist) != FetchReasonCached, nil
+}
+
+// AvailableVersions lists the tags of every published release of a
+// buildpack, newest first, without downloading anything. Pre-releases are
+// left out when the fetcher rejects them.
+func (r RemoteFetcher) AvailableVersions(buildpack RemoteBuildpack) ([]string, error) {
+	r = r.forHost(buildpack)
+	releases, err := r.gitReleaseFetcher.ListReleases(r.context(), buildpack.Org, buildpack.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, release := range releases {
+		if release.Draft || (r.rejectPrerelease && release.Prerelease) {
+			continue
+		}
+
+		versions = append(versions, release.TagName)
+	}
+
+	SortVersions(versions)
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+
+	return versions, nil
+}
+
+// VerifyCached checks that the cached artifact for the uncached or cached
+// variant of a buildpack exists and still matches the digest recorded when
+// it was fetched.
+func (r RemoteFetcher) VerifyCached(buildpack RemoteBuildpack, cached bool) error {
+	buildpack.Offline = cached
+	key := r.key(buildpack)
+
+	entry, exist, err := r.buildpackCache.Get(key)
+	if err != nil {
+		return err
+	}
+
+	if !exist {
+		if entry.URI == "" {
+			return fmt.Errorf("no cache entry for %s", key)
+		}
+		return fmt.Errorf("cached artifact for %s is missing: %s", key, entry.URI)
+	}
+
+	if entry.SHA256 == "" {
+		return fmt.Errorf("cache entry for %s has no recorded digest", key)
+	}
+
+	sum, err := fileSHA256(entry.URI)
+	if err != nil {
+		return fmt.Errorf("failed to hash cached artifact for %s: %w", key, err)
+	}
+
+	if sum != entry.SHA256 {
+		return fmt.Errorf("cached artifact for %s does not match its recorded digest: expected %s, got %s", key, entry.SHA256, sum)
+	}
+
+	return nil
+}
+
+// download fetches the asset or source tarball of a release and stores the
+// artifact at path, packaging it first when it is built from source. path is
+// a staging path that the caller discards when download fails.
+func (r RemoteFetcher) download(buildpack RemoteBuildpack, release github.Release, asset github.ReleaseAsset, useAsset bool, path string) ([]ArchiveEntry, string, error) {
+	if !useAsset && r.sources != nil {
+		if dir, ok := r.sources.take(sourceKey(buildpack, release)); ok {
+			defer os.RemoveAll(dir)
+			return nil, "", r.packSource(buildpack, release, dir, path)
+		}
+	}
+
+	var (
+		bundle      io.ReadCloser
+		resolvedURL string
+		err         error
+	)
+
+	if !useAsset {
+		bundle, resolvedURL, err = r.getTarball(buildpack, release)
+		if err != nil {
+			return nil, "", &DownloadError{Buildpack: buildpack, Err: err}
+		}
+	} else {
+		resolvedURL = asset.URL
+		bundle, err = r.gitReleaseFetcher.GetReleaseAsset(r.context(), asset)
+		if err != nil {
+			return nil, "", &DownloadError{Buildpack: buildpack, Err: err}
+		}
+	}
+	defer bundle.Close()
+
+	if r.progress != nil {
+		bundle = &progressReader{ReadCloser: bundle, total: downloadSize(bundle), progress: r.progress}
+	}
+	bundle = contextReader{ctx: r.context(), ReadCloser: bundle}
+
+	var content io.Reader = bundle
+	if len(r.acceptedFormats) > 0 {
+		content, err = checkArchiveFormat(bundle, r.acceptedFormats)
+		if err != nil {
+			return nil, "", &DownloadError{Buildpack: buildpack, Err: err}
+		}
+	}
+
+	var lister *archiveLister
+	if r.listFiles {
+		content, lister = newArchiveLister(content)
+		defer lister.Finish()
+	}
+
+	counter := &countingReader{reader: content}
+	content = counter
+
+	if !useAsset {
+		downloadDir, err := r.fileSystem.TempDir("", tempPrefix(buildpack))
+		if err != nil {
+			return nil, "", &ExtractError{Buildpack: buildpack, Err: err}
+		}
+
+		keep := false
+		defer func() {
+			if !keep {
+				os.RemoveAll(downloadDir)
+			}
+		}()
+
+		if r.extractWorkers > 1 {
+			err = ExtractTarball(content, downloadDir, 1, r.extractWorkers)
+		} else {
+			err = vacation.NewArchive(content).StripComponents(1).Decompress(downloadDir)
+		}
+		if err == nil {
+			_, err = io.Copy(io.Discard, content)
+		}
+		if counter.n < r.minSize {
+			return nil, "", &DownloadError{Buildpack: buildpack, Err: fmt.Errorf("source tarball of %s is %d bytes, below the minimum of %d", release.TagName, counter.n, r.minSize)}
+		}
+		if err != nil {
+			return nil, "", &ExtractError{Buildpack: buildpack, Err: err}
+		}
+
+		if r.modeMask != 0 {
+			err = maskModes(downloadDir, r.modeMask)
+			if err != nil {
+				return nil, "", &ExtractError{Buildpack: buildpack, Err: err}
+			}
+		}
+
+		err = verifyFiles(downloadDir, buildpack.ExpectedFiles, buildpack.StrictFiles)
+		if err != nil {
+			return nil, "", &ExtractError{Buildpack: buildpack, Err: err}
+		}
+
+		if r.requireBuildpack {
+			_, err = os.Stat(filepath.Join(downloadDir, "buildpack.toml"))
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil, "", &ExtractError{Buildpack: buildpack, Err: fmt.Errorf("source tarball of %s does not contain a buildpack.toml", release.TagName)}
+				}
+				return nil, "", &ExtractError{Buildpack: buildpack, Err: err}
+			}
+		}
+
+		err = r.packSource(buildpack, release, downloadDir, path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if r.sources != nil {
+			r.sources.put(sourceKey(buildpack, release), downloadDir)
+			keep = true
+		}
+	} else {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return nil, "", &CacheError{Buildpack: buildpack, Err: err}
+		}
+		defer file.Close()
+
+		_, err = io.Copy(file, content)
+		if err != nil {
+			return nil, "", &DownloadError{Buildpack: buildpack, Err: err}
+		}
+
+		if counter.n < r.minSize {
+			return nil, "", &DownloadError{Buildpack: buildpack, Err: fmt.Errorf("asset of %s is %d bytes, below the minimum of %d", release.TagName, counter.n, r.minSize)}
+		}
+
+		if r.requireBuildpack {
+			found, err := containsBuildpackTOML(path)
+			if err != nil {
+				return nil, "", &ExtractError{Buildpack: buildpack, Err: err}
+			}
+
+			if !found {
+				return nil, "", &ExtractError{Buildpack: buildpack, Err: fmt.Errorf("asset of %s does not contain a buildpack.toml", release.TagName)}
+			}
+		}
+	}
+
+	if lister == nil {
+		return nil, resolvedURL, nil
+	}
+
+	files, err := lister.Finish()
+	if err != nil {
+		return nil, "", &ExtractError{Buildpack: buildpack, Err: fmt.Errorf("failed to list archive: %w", err)}
+	}
+
+	return files, resolvedURL, nil
+}
+
+func (r RemoteFetcher) downloadOptionalAssets(buildpack RemoteBuildpack, release github.Release, dir string) (map[string]string, []string) {
+	if len(r.optionalAssets) == 0 {
+		return nil, nil
+	}
+
+	var (
+		paths    = map[string]string{}
+		warnings []string
+	)
+
+	for _, name := range r.optionalAssets {
+		var (
+			asset github.ReleaseAsset
+			found bool
+		)
+		for _, a := range release.Assets {
+			if a.Name == name {
+				asset, found = a, true
+				break
+			}
+		}
+
+		if !found {
+			warnings = append(warnings, fmt.Sprintf("release %s of %s/%s has no optional asset %s", release.TagName, buildpack.Org, buildpack.Repo, name))
+			continue
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s", release.TagName, filepath.Base(name)))
+		err := r.copyAsset(asset, path)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("skipped optional asset %s of %s: %s", name, release.TagName, err))
+			continue
+		}
+
+		paths[name] = path
+	}
+
+	return paths, warnings
+}
+
+func (r RemoteFetcher) copyAsset(asset github.ReleaseAsset, path string) error {
+	content, err := r.gitReleaseFetcher.GetReleaseAsset(r.context(), asset)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, content)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// verifyPublishedDigest compares the asset downloaded to path with the
+// digest the release publishes for it and returns that digest.
+func (r RemoteFetcher) verifyPublishedDigest(release github.Release, asset github.ReleaseAsset, path string) (string, error) {
+	published, err := r.publishedDigest(release, asset)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the published digest of %s: %w", asset.Name, err)
+	}
+
+	if published == "" {
+		return "", nil
+	}
+
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+
+	if sum != published {
+		return "", fmt.Errorf("asset %s of release %s does not match its published digest: expected %s, got %s", asset.Name, release.TagName, published, sum)
+	}
+
+	return published, nil
+}
+
+// publishedDigest returns the sha256 digest of asset from a <name>.sha256
+// asset of the release or, failing that, from a line of the release notes
+// that mentions the asset. It returns an empty string when neither has one.
+func (r RemoteFetcher) publishedDigest(release github.Release, asset github.ReleaseAsset) (string, error) {
+	if asset.Name == "" {
+		return "", nil
+	}
+
+	for _, a := range release.Assets {
+		if a.Name != asset.Name+".sha256" {
+			continue
+		}
+
+		content, err := r.gitReleaseFetcher.GetReleaseAsset(r.context(), a)
+		if err != nil {
+			return "", err
+		}
+		defer content.Close()
+
+		checksum, err := io.ReadAll(io.LimitReader(content, 4096))
+		if err != nil {
+			return "", err
+		}
+
+		fields := strings.Fields(string(checksum))
+		if len(fields) == 0 || !isSHA256(fields[0]) {
+			return "", fmt.Errorf("%s does not contain a sha256 digest", a.Name)
+		}
+
+		return strings.ToLower(fields[0]), nil
+	}
+
+	for _, line := range strings.Split(release.Body, "\n") {
+		if !strings.Contains(line, asset.Name) {
+			continue
+		}
+
+		for _, field := range strings.FieldsFunc(line, func(c rune) bool {
+			return !strings.ContainsRune("0123456789abcdefABCDEF", c)
+		}) {
+			if isSHA256(field) {
+				return strings.ToLower(field), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+func isSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// packSource packages the extracted source in dir into path.
+func (r RemoteFetcher) packSource(buildpack RemoteBuildpack, release github.Release, dir, path string) error {
+	err := r.pack(dir, path, release.TagName, buildpack.Offline)
+	if err != nil {
+		return &PackageError{Buildpack: buildpack, Err: err}
+	}
+
+	_, err = os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &PackageError{Buildpack: buildpack, Err: fmt.Errorf("packager did not produce %s", path)}
+		}
+		return &PackageError{Buildpack: buildpack, Err: err}
+	}
+
+	return nil
+}
+
+// GetAsset downloads the release asset called assetName as it is, without
+// packaging, and caches it under a key of its own. It suits repositories
+// whose cached and uncached artifacts can only be told apart by name.
+func (r RemoteFetcher) GetAsset(buildpack RemoteBuildpack, assetName string) (string, error) {
+	r = r.forHost(buildpack)
+	release, err := r.release(buildpack)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		asset github.ReleaseAsset
+		found bool
+	)
+	for _, a := range release.Assets {
+		if a.Name == assetName {
+			asset, found = a, true
+			break
+		}
+	}
+
+	if !found {
+		return "", fmt.Errorf("release %s of %s/%s has no asset named %s", release.TagName, buildpack.Org, buildpack.Repo, assetName)
+	}
+
+	key := namespacedKey(r.namespace, fmt.Sprintf("%s:asset:%s", buildpack.UncachedKey, assetName))
+
+	cachedEntry, exist, err := r.buildpackCache.Get(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Asset artifacts keep their upstream name, so only the version and age
+	// of the entry and the presence of its file decide whether it is still
+	// good
+	if exist && cachedEntry.Version == release.TagName && (r.ttl <= 0 || r.clock.Now().Sub(cachedEntry.FetchedAt) <= r.ttl) {
+		_, err = os.Stat(cachedEntry.URI)
+		if err == nil {
+			return cachedEntry.URI, nil
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	assetDir := filepath.Join(r.buildpackDir(buildpack), "assets")
+	err = os.MkdirAll(assetDir, os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+
+	bundle, err := r.gitReleaseFetcher.GetReleaseAsset(r.context(), asset)
+	if err != nil {
+		return "", err
+	}
+	defer bundle.Close()
+
+	path := filepath.Join(assetDir, filepath.Base(assetName))
+	staged, discard, err := stagingPath(path)
+	if err != nil {
+		return "", err
+	}
+	defer discard()
+
+	file, err := os.Create(staged)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	n, err := io.Copy(file, bundle)
+	if err != nil {
+		return "", err
+	}
+
+	if n < r.minSize {
+		return "", fmt.Errorf("asset %s of %s is %d bytes, below the minimum of %d", assetName, release.TagName, n, r.minSize)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(staged, path)
+	if err != nil {
+		return "", err
+	}
+
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+
+	err = r.buildpackCache.Set(key, CacheEntry{
+		Version:     release.TagName,
+		URI:         path,
+		FetchedAt:   r.clock.Now(),
+		PublishedAt: release.PublishedAt,
+		SHA256:      sum,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Stream returns the release asset for the uncached or cached variant of a
+// buildpack straight from GitHub, without reading or writing the cache.
+// Variants that would have to be packaged from source cannot be streamed.
+func (r RemoteFetcher) Stream(buildpack RemoteBuildpack, cached bool) (io.ReadCloser, error) {
+	r = r.forHost(buildpack)
+	buildpack.Offline = cached
+
+	release, err := r.release(buildpack)
+	if err != nil {
+		return nil, err
+	}
+
+	asset, useAsset := r.selectAsset(buildpack, release)
+	if !useAsset {
+		return nil, fmt.Errorf("release %s of %s/%s has no asset to stream, it would have to be packaged from source", release.TagName, buildpack.Org, buildpack.Repo)
+	}
+
+	bundle, err := r.gitReleaseFetcher.GetReleaseAsset(r.context(), asset)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.acceptedFormats) == 0 {
+		return bundle, nil
+	}
+
+	content, err := checkArchiveFormat(bundle, r.acceptedFormats)
+	if err != nil {
+		bundle.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{content, bundle}, nil
+}
+
+// Metadata returns the buildpack.toml of the release buildpack resolves to.
+// It is read from the metadata saved by WithMetadataCache when there is
+// some, and otherwise streamed from the release asset or source tarball,
+// reading no further than the buildpack.toml.
+func (r RemoteFetcher) Metadata(buildpack RemoteBuildpack) ([]byte, error) {
+	r = r.forHost(buildpack)
+	release, err := r.release(buildpack)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataPath := r.metadataPath(buildpack, release.TagName)
+	content, err := os.ReadFile(metadataPath)
+	if err == nil {
+		return content, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var bundle io.ReadCloser
+	asset, useAsset := r.selectAsset(buildpack, release)
+	if useAsset {
+		bundle, err = r.gitReleaseFetcher.GetReleaseAsset(r.context(), asset)
+	} else {
+		var tarballURL string
+		tarballURL, err = r.tarballURL(buildpack, release)
+		if err != nil {
+			return nil, err
+		}
+		bundle, err = r.gitReleaseFetcher.GetReleaseTarball(r.context(), tarballURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer bundle.Close()
+
+	content, err = readBuildpackTOML(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read buildpack.toml of %s/%s %s: %w", buildpack.Org, buildpack.Repo, release.TagName, err)
+	}
+
+	if r.cacheMetadata {
+		err = os.MkdirAll(filepath.Dir(metadataPath), os.ModePerm)
+		if err != nil {
+			return nil, err
+		}
+
+		err = os.WriteFile(metadataPath, content, 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return content, nil
+}
+
+// saveMetadata copies the buildpack.toml of the artifact at path to the
+// metadata path of tag.
+func (r RemoteFetcher) saveMetadata(buildpack RemoteBuildpack, tag, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	content, err := readBuildpackTOML(file)
+	if err != nil {
+		return fmt.Errorf("failed to read buildpack.toml of %s: %w", path, err)
+	}
+
+	return os.WriteFile(r.metadataPath(buildpack, tag), content, 0644)
+}
+
+func (r RemoteFetcher) pack(buildpackDir, output, version string, cached bool) error {
+	backoff := r.packBackoff
+	for attempt := 0; ; attempt++ {
+		err := r.packager.Execute(r.context(), buildpackDir, output, version, cached)
+		if err == nil || attempt >= r.packRetries {
+			return err
+		}
+
+		if r.packTransient != nil && !r.packTransient(err) {
+			return err
+		}
+
+		err = sleep(r.context(), backoff)
+		if err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// validArtifact reports whether entry records the artifact at path and the
+// artifact still matches the recorded digest.
+func validArtifact(entry CacheEntry, path string) bool {
+	if entry.URI != path || entry.SHA256 == "" {
+		return false
+	}
+
+	sum, err := fileSHA256(path)
+	return err == nil && sum == entry.SHA256
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// progressReader reports the bytes read through it to progress.
+type progressReader struct {
+	io.ReadCloser
+	downloaded int64
+	total      int64
+	progress   func(downloaded, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.downloaded += int64(n)
+		p.progress(p.downloaded, p.total)
+	}
+
+	return n, err
+}
+
+// downloadSize returns the size the server reported for a download, or -1
+// when it is unknown.
+func downloadSize(content io.ReadCloser) int64 {
+	if download, ok := content.(*github.Download); ok {
+		return download.Size
+	}
+
+	return -1
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// tempPrefix names temporary directories after the buildpack they belong to
+// so that leftovers can be traced back to it.
+func tempPrefix(buildpack RemoteBuildpack) string {
+	name := fmt.Sprintf("%s-%s-", buildpack.Org, buildpack.Repo)
+
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// stagingPath returns a path in a new temporary directory next to path, so
+// that an artifact can be written and checked in full before it is renamed
+// over the one at path. The returned function removes the directory.
+func stagingPath(path string) (string, func(), error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), fmt.Sprintf(".%s-", filepath.Base(path)))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return filepath.Join(dir, filepath.Base(path)), func() { os.RemoveAll(dir) }, nil
+}
+
+// syncPath flushes the file at path and the directory entry that names it.
+func syncPath(path string) error {
+	for _, p := range []string{path, filepath.Dir(path)} {
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+
+		err = file.Sync()
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func maskModes(dir string, mask os.FileMode) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		mode := info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if mode&mask == 0 {
+			return nil
+		}
+
+		return os.Chmod(path, mode&^mask)
+	})
+}
+
+func verifyFiles(dir string, expected []string, strict bool) error {
+	if len(expected) == 0 {
+		return nil
+	}
+
+	var missing []string
+	for _, file := range expected {
+		_, err := os.Stat(filepath.Join(dir, file))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				missing = append(missing, file)
+				continue
+			}
+			return err
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("extracted buildpack is missing expected files: %s", strings.Join(missing, ", "))
+	}
+
+	if !strict {
+		return nil
+	}
+
+	allowed := map[string]bool{}
+	for _, file := range expected {
+		allowed[filepath.Clean(file)] = true
+	}
+
+	var unexpected []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if !allowed[rel] {
+			unexpected = append(unexpected, rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(unexpected) > 0 {
+		return fmt.Errorf("extracted buildpack contains unexpected files: %s", strings.Join(unexpected, ", "))
+	}
+
+	return nil
+}
+
+func (r RemoteFetcher) fetchReason(release github.Release, cachedEntry CacheEntry, exist bool) FetchReason {
+	switch {
+	case !exist && cachedEntry.URI != "":
+		return FetchReasonFileMissing
+	case !exist:
+		return FetchReasonNotCached
+	case release.TagName != cachedEntry.Version:
+		return FetchReasonVersionChanged
+
+	// Artifacts are named after the tag they were fetched for, so an entry
+	// whose file name disagrees with its version has been tampered with or
+	// was only partially migrated and cannot be trusted
+	case !artifactNamedFor(cachedEntry.URI, cachedEntry.Version):
+		return FetchReasonMismatched
+	case r.ttl > 0 && r.clock.Now().Sub(cachedEntry.FetchedAt) > r.ttl:
+		return FetchReasonExpired
+	case r.publisher != nil && cachedEntry.Reference == "":
+		return FetchReasonUnpublished
+	}
+
+	if !r.skipCachedCheck {
+		_, err := os.Stat(cachedEntry.URI)
+		if err != nil {
+			return FetchReasonFileMissing
+		}
+
+		if cachedEntry.SHA256 != "" && !validArtifact(cachedEntry, cachedEntry.URI) {
+			return FetchReasonCorrupted
+		}
+	}
+
+	return FetchReasonCached
+}
+
+// artifactNamedFor reports whether uri is named after version in either the
+// flat or the versioned layout.
+func artifactNamedFor(uri, version string) bool {
+	if filepath.Base(uri) == "buildpack.tgz" {
+		return filepath.Base(filepath.Dir(uri)) == filepath.Base(version)
+	}
+
+	return filepath.Base(uri) == filepath.Base(fmt.Sprintf("%s.tgz", version))
+}
+
+func (r RemoteFetcher) selectAsset(buildpack RemoteBuildpack, release github.Release) (github.ReleaseAsset, bool) {
+	policy := buildpack.AssetPolicy
+	if policy == "" {
+		policy = r.assetPolicy
+	}
+
+	if policy == AssetPolicyAlwaysSource || len(release.Assets) == 0 {
+		return github.ReleaseAsset{}, false
+	}
+
+	if !buildpack.Offline {
+		return release.Assets[0], true
+	}
+
+	if policy == AssetPolicySourceIfNoCachedAsset {
+		for _, asset := range release.Assets {
+			if strings.Contains(asset.Name, "cached") {
+				return asset, true
+			}
+		}
+	}
+
+	return github.ReleaseAsset{}, false
+}
+
+// getTarball downloads the source tarball of release and returns the url it
+// was downloaded from.
+func (r RemoteFetcher) getTarball(buildpack RemoteBuildpack, release github.Release) (io.ReadCloser, string, error) {
+	tarballURL, err := r.tarballURL(buildpack, release)
+	if err != nil {
+		return nil, "", err
+	}
+
+	backoff := r.tarballBackoff
+	for attempt := 0; ; attempt++ {
+		var bundle io.ReadCloser
+		bundle, err = r.gitReleaseFetcher.GetReleaseTarball(r.context(), tarballURL)
+		if !isNotFound(err) {
+			return bundle, tarballURL, err
+		}
+
+		if attempt >= r.tarballRetries {
+			break
+		}
+
+		err = sleep(r.context(), backoff)
+		if err != nil {
+			return nil, "", err
+		}
+		backoff *= 2
+	}
+
+	if r.tarballEndpoint != "" {
+		fallbackURL := r.fallbackTarballURL(buildpack, release)
+		if fallbackURL != tarballURL {
+			bundle, err := r.gitReleaseFetcher.GetReleaseTarball(r.context(), fallbackURL)
+			if !isNotFound(err) {
+				return bundle, fallbackURL, err
+			}
+		}
+	}
+
+	return nil, "", fmt.Errorf("source tarball of release %s of %s/%s was not found at %s, check that the release and its tag still exist: %w", release.TagName, buildpack.Org, buildpack.Repo, tarballURL, err)
+}
+
+func isNotFound(err error) bool {
+	var statusErr *github.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+func (r RemoteFetcher) tarballURL(buildpack RemoteBuildpack, release github.Release) (string, error) {
+	if release.TarballURL != "" {
+		return release.TarballURL, nil
+	}
+
+	if r.tarballEndpoint == "" {
+		return "", fmt.Errorf("release %s of %s/%s has no source tarball url", release.TagName, buildpack.Org, buildpack.Repo)
+	}
+
+	return r.fallbackTarballURL(buildpack, release), nil
+}
+
+func (r RemoteFetcher) fallbackTarballURL(buildpack RemoteBuildpack, release github.Release) string {
+	return fmt.Sprintf("%s/repos/%s/%s/tarball/%s", r.tarballEndpoint, buildpack.Org, buildpack.Repo, release.TagName)
+}
+
+func (r RemoteFetcher) release(buildpack RemoteBuildpack) (github.Release, error) {
+	var (
+		release github.Release
+		err     error
+	)
+
+	if r.pinned != nil {
+		release, ok := r.pinned[pinnedKey(buildpack)]
+		if !ok {
+			return github.Release{}, fmt.Errorf("%s/%s is not part of the snapshot", buildpack.Org, buildpack.Repo)
+		}
+
+		return release, nil
+	}
+
+	switch {
+	case buildpack.ReleaseID != 0:
+		release, err = r.gitReleaseFetcher.GetByID(r.context(), buildpack.Org, buildpack.Repo, buildpack.ReleaseID)
+	case buildpack.Constraint != "":
+		release, err = r.highestSatisfying(buildpack)
+	case r.releasePredicate != nil:
+		release, err = r.latestMatching(buildpack)
+	default:
+		release, err = r.gitReleaseFetcher.Get(r.context(), buildpack.Org, buildpack.Repo)
+	}
+	if err != nil {
+		return github.Release{}, err
+	}
+
+	if r.verifyRepository && !releaseMatchesRepository(release, buildpack.Org, buildpack.Repo) {
+		return github.Release{}, fmt.Errorf("release %s does not belong to %s/%s: %q", release.TagName, buildpack.Org, buildpack.Repo, release.HTMLURL)
+	}
+
+	if r.rejectPrerelease && release.Prerelease {
+		return github.Release{}, fmt.Errorf("release %s of %s/%s is a pre-release", release.TagName, buildpack.Org, buildpack.Repo)
+	}
+
+	return release, nil
+}
+
+func (r RemoteFetcher) latestMatching(buildpack RemoteBuildpack) (github.Release, error) {
+	releases, err := r.gitReleaseFetcher.ListReleases(r.context(), buildpack.Org, buildpack.Repo)
+	if err != nil {
+		return github.Release{}, err
+	}
+
+	for _, release := range releases {
+		if !release.Draft && r.releasePredicate(release) {
+			return release, nil
+		}
+	}
+
+	return github.Release{}, fmt.Errorf("no release of %s/%s satisfies the release predicate", buildpack.Org, buildpack.Repo)
+}
+
+// highestSatisfying returns the release with the highest tag that satisfies
+// the constraint of the buildpack. Drafts and tags that are not semver are
+// skipped.
+func (r RemoteFetcher) highestSatisfying(buildpack RemoteBuildpack) (github.Release, error) {
+	constraint, err := semver.NewConstraint(buildpack.Constraint)
+	if err != nil {
+		return github.Release{}, fmt.Errorf("invalid version constraint %q for %s/%s: %w", buildpack.Constraint, buildpack.Org, buildpack.Repo, err)
+	}
+
+	releases, err := r.gitReleaseFetcher.ListReleases(r.context(), buildpack.Org, buildpack.Repo)
+	if err != nil {
+		return github.Release{}, err
+	}
+
+	highest := r.highestRelease(releases, constraint)
+	if highest == nil && r.tagFallback {
+		lister, ok := r.tagLister()
+		if !ok {
+			return github.Release{}, fmt.Errorf("cannot fall back to the tags of %s/%s: the release fetcher cannot list tags", buildpack.Org, buildpack.Repo)
+		}
+
+		tags, err := lister.ListTags(r.context(), buildpack.Org, buildpack.Repo)
+		if err != nil {
+			return github.Release{}, err
+		}
+
+		highest = r.highestRelease(tags, constraint)
+	}
+
+	if highest == nil {
+		return github.Release{}, fmt.Errorf("no release of %s/%s satisfies the version constraint %q", buildpack.Org, buildpack.Repo, buildpack.Constraint)
+	}
+
+	return *highest, nil
+}
+
+// highestRelease returns the release with the highest semver tag that
+// satisfies constraint, or nil when there is none.
+func (r RemoteFetcher) highestRelease(releases []github.Release, constraint *semver.Constraints) *github.Release {
+	var (
+		highest *github.Release
+		version *semver.Version
+	)
+	for i, release := range releases {
+		if release.Draft || (r.rejectPrerelease && release.Prerelease) {
+			continue
+		}
+
+		v, err := semver.NewVersion(release.TagName)
+		if err != nil || !constraint.Check(v) {
+			continue
+		}
+
+		if highest == nil || v.GreaterThan(version) {
+			highest = &releases[i]
+			version = v
+		}
+	}
+
+	return highest
+}
+
+// tagLister returns the release fetcher as a GitTagLister.
+func (r RemoteFetcher) tagLister() (GitTagLister, bool) {
+	lister, ok := r.gitReleaseFetcher.(GitTagLister)
+	return lister, ok
+}
+
+func releaseMatchesRepository(release github.Release, org, repo string) bool {
+	uri, err := url.Parse(release.HTMLURL)
+	if err != nil {
+		return false
+	}
+
+	segments := strings.Split(strings.Trim(uri.Path, "/"), "/")
+	if len(segments) < 2 {
+		return false
+	}
+
+	return strings.EqualFold(segments[0], org) && strings.EqualFold(segments[1], repo)
+}
+
+// buildpackDir is the directory the uncached artifacts of a buildpack are
+// stored in. Buildpacks on hosts other than github.com are kept beneath a
+// directory named after the host, so that the same org/repo on two hosts
+// never share an artifact, and buildpacks pinned to a release id or a
+// constraint beneath a directory of their own, so that replacing the latest
+// artifact never removes one of theirs.
+func (r RemoteFetcher) buildpackDir(buildpack RemoteBuildpack) string {
+	cacheDir := r.buildpackCache.Dir()
+	if buildpack.CacheDir != "" {
+		cacheDir = buildpack.CacheDir
+	}
+
+	dir := filepath.Join(cacheDir, r.namespace)
+	if !isGitHubHost(buildpack.Host) {
+		dir = filepath.Join(dir, strings.ReplaceAll(strings.ToLower(buildpack.Host), ":", "_"))
+	}
+
+	dir = filepath.Join(dir, buildpack.Org, buildpack.Repo)
+	if buildpack.ReleaseID != 0 {
+		dir = filepath.Join(dir, fmt.Sprintf("%d", buildpack.ReleaseID))
+	}
+
+	if buildpack.Constraint != "" {
+		dir = filepath.Join(dir, constraintDir(buildpack.Constraint))
+	}
+
+	return dir
+}
+
+// constraintDir names the directory of the artifacts of a constraint by a
+// short digest of it, since constraints like >=1.0 <2.0 are not valid file
+// names everywhere.
+func constraintDir(constraint string) string {
+	sum := sha256.Sum256([]byte(constraint))
+	return "constraint-" + hex.EncodeToString(sum[:6])
+}
+
+// shareByIdentity returns the id@version cache key of the artifact at path
+// and the path of the artifact to keep for it, which is an artifact already
+// cached under that key when there is a suitable one.
+func (r RemoteFetcher) shareByIdentity(buildpack RemoteBuildpack, release github.Release, path string) (string, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", &CacheError{Buildpack: buildpack, Err: err}
+	}
+	defer file.Close()
+
+	content, err := readBuildpackTOML(file)
+	if err != nil {
+		return "", "", &ExtractError{Buildpack: buildpack, Err: fmt.Errorf("failed to read buildpack.toml of %s: %w", path, err)}
+	}
+
+	var config struct {
+		Buildpack struct {
+			ID      string `toml:"id"`
+			Version string `toml:"version"`
+		} `toml:"buildpack"`
+	}
+
+	_, err = toml.Decode(string(content), &config)
+	if err != nil {
+		return "", "", &ExtractError{Buildpack: buildpack, Err: fmt.Errorf("failed to decode buildpack.toml of %s: %w", path, err)}
+	}
+
+	if config.Buildpack.ID == "" || config.Buildpack.Version == "" {
+		return "", "", &ExtractError{Buildpack: buildpack, Err: fmt.Errorf("buildpack.toml of %s does not declare an id and version", path)}
+	}
+
+	identity := fmt.Sprintf("%s@%s", config.Buildpack.ID, config.Buildpack.Version)
+	if buildpack.Offline {
+		identity = fmt.Sprintf("%s:cached", identity)
+	}
+	identity = namespacedKey(r.namespace, identity)
+
+	existing, ok, err := r.buildpackCache.Get(identity)
+	if err != nil {
+		return "", "", &CacheError{Buildpack: buildpack, Err: err}
+	}
+
+	if !ok || existing.URI == path || !artifactNamedFor(existing.URI, release.TagName) {
+		return identity, path, nil
+	}
+
+	file.Close()
+	err = os.Remove(path)
+	if err != nil {
+		return "", "", &CacheError{Buildpack: buildpack, Err: err}
+	}
+
+	return identity, existing.URI, nil
+}
+
+// variantDir is the directory the artifacts of the variant of buildpack
+// requested by its Offline flag are stored in.
+func (r RemoteFetcher) variantDir(buildpack RemoteBuildpack) string {
+	dir := r.buildpackDir(buildpack)
+	if buildpack.Offline {
+		dir = filepath.Join(dir, cachedVariantDir(r.cachedDir))
+	}
+
+	return dir
+}
+
+func (r RemoteFetcher) artifactPath(buildpack RemoteBuildpack, tag string) string {
+	if r.versionedLayout {
+		return filepath.Join(r.variantDir(buildpack), tag, "buildpack.tgz")
+	}
+
+	return filepath.Join(r.variantDir(buildpack), fmt.Sprintf("%s.tgz", tag))
+}
+
+func (r RemoteFetcher) metadataPath(buildpack RemoteBuildpack, tag string) string {
+	if r.versionedLayout {
+		return filepath.Join(r.variantDir(buildpack), tag, "buildpack.toml")
+	}
+
+	return filepath.Join(r.variantDir(buildpack), fmt.Sprintf("%s.buildpack.toml", tag))
+}
+
+// forHost returns a copy of the fetcher that uses the release fetcher
+// registered for the host of buildpack, if there is one.
+func (r RemoteFetcher) forHost(buildpack RemoteBuildpack) RemoteFetcher {
+	fetcher, ok := r.hostFetchers[strings.ToLower(buildpack.Host)]
+	if ok {
+		r.gitReleaseFetcher = fetcher
+	}
+
+	return r
+}
+
+// context returns the context of the fetch in progress.
+func (r RemoteFetcher) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+
+	return r.ctx
+}
+
+func (r RemoteFetcher) key(buildpack RemoteBuildpack) string {
+	if buildpack.Offline {
+		return namespacedKey(r.namespace, cachedVariantKey(buildpack.UncachedKey, buildpack.CachedKey, r.cachedSuffix))
+	}
+
+	return namespacedKey(r.namespace, buildpack.UncachedKey)
+}
+
+func cachedVariantKey(uncachedKey, cachedKey, suffix string) string {
+	if suffix == "" {
+		return cachedKey
+	}
+
+	return uncachedKey + suffix
+}
+
+func cachedVariantDir(dir string) string {
+	if dir == "" {
+		return "cached"
+	}
+
+	return dir
+}
+
+func namespacedKey(namespace, key string) string {
+	if namespace == "" {
+		return key
+	}
+
+	return fmt.Sprintf("%s/%s", namespace, key)
 }
@@ -4,11 +4,17 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	gocontext "context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ForestEckhardt/freezer"
 	"github.com/ForestEckhardt/freezer/fakes"
@@ -33,6 +39,7 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 		packager          *fakes.Packager
 		fileSystem        freezer.FileSystem
 		remoteFetcher     freezer.RemoteFetcher
+		cachedPath        string
 	)
 
 	it.Before(func() {
@@ -67,6 +74,10 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 		gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(buffer)
 
 		packager = &fakes.Packager{}
+		packager.ExecuteCall.Stub = func(_ gocontext.Context, _, output, _ string, _ bool) error {
+			return os.WriteFile(output, []byte("some-packaged-buildpack"), 0644)
+		}
+
 		buildpackCache = &fakes.BuildpackCache{}
 		buildpackCache.DirCall.Stub = func() string {
 			return cacheDir
@@ -80,6 +91,11 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 		tmpDir, err = os.MkdirTemp("", "tmpDir")
 		Expect(err).NotTo(HaveOccurred())
 
+		// Cache hits check that the cached artifact still exists
+		cachedPath = filepath.Join(tmpDir, "some-path", "some-tag.tgz")
+		Expect(os.MkdirAll(filepath.Dir(cachedPath), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(cachedPath, []byte("some-cached-buildpack"), 0644)).To(Succeed())
+
 		downloadDir, err = os.MkdirTemp(tmpDir, "downloadDir")
 		Expect(err).NotTo(HaveOccurred())
 
@@ -101,7 +117,7 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 			it.Before(func() {
 				buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
 					Version: "some-tag",
-					URI:     "keep-this-uri",
+					URI:     cachedPath,
 				}
 			})
 
@@ -116,7 +132,103 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 
 				Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
 
-				Expect(uri).To(Equal("keep-this-uri"))
+				Expect(uri).To(Equal(cachedPath))
+			})
+
+			context("when a ttl is configured", func() {
+				var (
+					clock     *fakes.Clock
+					fetchedAt time.Time
+				)
+
+				it.Before(func() {
+					fetchedAt = time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+						Version:   "some-tag",
+						URI:       cachedPath,
+						FetchedAt: fetchedAt,
+					}
+
+					clock = &fakes.Clock{}
+					remoteFetcher = remoteFetcher.WithClock(clock).WithTTL(time.Hour)
+
+					Expect(os.MkdirAll(filepath.Join(cacheDir, "some-org", "some-repo"), os.ModePerm)).To(Succeed())
+				})
+
+				context("and the entry is within the ttl", func() {
+					it.Before(func() {
+						clock.NowCall.Returns.Time = fetchedAt.Add(30 * time.Minute)
+					})
+
+					it("keeps the cached buildpack", func() {
+						uri, err := remoteFetcher.Get(remoteBuildpack)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+						Expect(uri).To(Equal(cachedPath))
+					})
+				})
+
+				context("and the entry has outlived the ttl", func() {
+					it.Before(func() {
+						clock.NowCall.Returns.Time = fetchedAt.Add(2 * time.Hour)
+					})
+
+					it("refetches the buildpack and records when it was fetched", func() {
+						uri, err := remoteFetcher.Get(remoteBuildpack)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+
+						Expect(buildpackCache.SetCall.CallCount).To(Equal(1))
+						Expect(buildpackCache.SetCall.Receives.CachedEntry).To(Equal(freezer.CacheEntry{
+							Version:   "some-tag",
+							URI:       filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz"),
+							FetchedAt: fetchedAt.Add(2 * time.Hour),
+							SHA256:    buildpackCache.SetCall.Receives.CachedEntry.SHA256,
+						}))
+
+						Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+					})
+				})
+			})
+
+			context("when the release has a publish date", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release.PublishedAt = time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+					buildpackCache.GetCall.Returns.Bool = false
+				})
+
+				it("records it on the cache entry", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(buildpackCache.SetCall.Receives.CachedEntry.PublishedAt).To(Equal(time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)))
+				})
+			})
+
+			context("when the cached artifact's file name does not match the recorded version", func() {
+				it.Before(func() {
+					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+						Version: "some-tag",
+						URI:     "some-path/some-other-tag.tgz",
+					}
+
+					Expect(os.MkdirAll(filepath.Join(cacheDir, "some-org", "some-repo"), os.ModePerm)).To(Succeed())
+				})
+
+				it("treats the entry as a miss and refetches the buildpack", func() {
+					uri, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+
+					Expect(buildpackCache.SetCall.CallCount).To(Equal(1))
+					Expect(buildpackCache.SetCall.Receives.CachedEntry.URI).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+
+					Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+				})
 			})
 		})
 
@@ -175,7 +287,8 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 						Expect(gitReleaseFetcher.GetReleaseTarballCall.Receives.Url).To(Equal("some-tarball-url"))
 
 						Expect(packager.ExecuteCall.Receives.BuildpackDir).To(Equal(downloadDir))
-						Expect(packager.ExecuteCall.Receives.Output).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "cached", "some-tag.tgz")))
+						Expect(filepath.Base(packager.ExecuteCall.Receives.Output)).To(Equal("some-tag.tgz"))
+						Expect(filepath.Dir(filepath.Dir(packager.ExecuteCall.Receives.Output))).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "cached")))
 						Expect(packager.ExecuteCall.Receives.Version).To(Equal("some-tag"))
 						Expect(packager.ExecuteCall.Receives.Cached).To(BeTrue())
 
@@ -218,7 +331,7 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 
 					Expect(os.MkdirAll(filepath.Join(cacheDir, "some-org", "some-repo"), os.ModePerm)).To(Succeed())
 
-					packager.ExecuteCall.Stub = func(string, string, string, bool) error {
+					packager.ExecuteCall.Stub = func(_ gocontext.Context, _, output, _ string, _ bool) error {
 						content, err := os.ReadFile(filepath.Join(downloadDir, "some-file"))
 						if err != nil {
 							return err
@@ -228,7 +341,7 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 							return errors.New("error during decompression something is broken")
 						}
 
-						return nil
+						return os.WriteFile(output, []byte("some-packaged-buildpack"), 0644)
 					}
 				})
 
@@ -245,7 +358,8 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 						Expect(gitReleaseFetcher.GetReleaseTarballCall.Receives.Url).To(Equal("some-tarball-url"))
 
 						Expect(packager.ExecuteCall.Receives.BuildpackDir).To(Equal(downloadDir))
-						Expect(packager.ExecuteCall.Receives.Output).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+						Expect(filepath.Base(packager.ExecuteCall.Receives.Output)).To(Equal("some-tag.tgz"))
+						Expect(filepath.Dir(filepath.Dir(packager.ExecuteCall.Receives.Output))).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo")))
 						Expect(packager.ExecuteCall.Receives.Version).To(Equal("some-tag"))
 						Expect(packager.ExecuteCall.Receives.Cached).To(BeFalse())
 
@@ -271,7 +385,8 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 						Expect(gitReleaseFetcher.GetReleaseTarballCall.Receives.Url).To(Equal("some-tarball-url"))
 
 						Expect(packager.ExecuteCall.Receives.BuildpackDir).To(Equal(downloadDir))
-						Expect(packager.ExecuteCall.Receives.Output).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "cached", "some-tag.tgz")))
+						Expect(filepath.Base(packager.ExecuteCall.Receives.Output)).To(Equal("some-tag.tgz"))
+						Expect(filepath.Dir(filepath.Dir(packager.ExecuteCall.Receives.Output))).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "cached")))
 						Expect(packager.ExecuteCall.Receives.Version).To(Equal("some-tag"))
 						Expect(packager.ExecuteCall.Receives.Cached).To(BeTrue())
 
@@ -282,164 +397,2976 @@ func testRemoteFetcher(t *testing.T, context spec.G, it spec.S) {
 						Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "cached", "some-tag.tgz")))
 					})
 				})
+
+				context("when the source tarball has entries with names longer than 100 characters", func() {
+					var longDir string
+
+					it.Before(func() {
+						longDir = filepath.Join(strings.Repeat("a", 60), strings.Repeat("b", 60))
+
+						buffer := bytes.NewBuffer(nil)
+						gw := gzip.NewWriter(buffer)
+						tw := tar.NewWriter(gw)
+
+						Expect(tw.WriteHeader(&tar.Header{
+							Name:     filepath.Join("some-dir", longDir, "pax-file"),
+							Mode:     0755,
+							Size:     int64(len("pax content")),
+							Format:   tar.FormatPAX,
+							Typeflag: tar.TypeReg,
+						})).To(Succeed())
+						_, err := tw.Write([]byte(`pax content`))
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(tw.WriteHeader(&tar.Header{
+							Name:     filepath.Join("some-dir", longDir, "gnu-file"),
+							Mode:     0755,
+							Size:     int64(len("gnu content")),
+							Format:   tar.FormatGNU,
+							Typeflag: tar.TypeReg,
+						})).To(Succeed())
+						_, err = tw.Write([]byte(`gnu content`))
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(tw.Close()).To(Succeed())
+						Expect(gw.Close()).To(Succeed())
+
+						gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(buffer)
+
+						packager.ExecuteCall.Stub = func(_ gocontext.Context, _, output, _ string, _ bool) error {
+							for file, expected := range map[string]string{"pax-file": "pax content", "gnu-file": "gnu content"} {
+								content, err := os.ReadFile(filepath.Join(downloadDir, longDir, file))
+								if err != nil {
+									return err
+								}
+
+								if string(content) != expected {
+									return fmt.Errorf("unexpected content in %s: %q", file, content)
+								}
+							}
+
+							return os.WriteFile(output, []byte("some-packaged-buildpack"), 0644)
+						}
+					})
+
+					it("extracts the entries to their full paths", func() {
+						_, err := remoteFetcher.Get(remoteBuildpack)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(packager.ExecuteCall.CallCount).To(Equal(1))
+					})
+				})
+
+				context("when the buildpack lists the files it expects to contain", func() {
+					it.Before(func() {
+						remoteBuildpack.ExpectedFiles = []string{"some-file"}
+					})
+
+					it("packages the buildpack when the files are present", func() {
+						_, err := remoteFetcher.Get(remoteBuildpack)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(packager.ExecuteCall.CallCount).To(Equal(1))
+					})
+
+					context("when a required file is missing", func() {
+						it.Before(func() {
+							remoteBuildpack.ExpectedFiles = []string{"some-file", "buildpack.toml"}
+						})
+
+						it("returns an error", func() {
+							_, err := remoteFetcher.Get(remoteBuildpack)
+							Expect(err).To(MatchError("extracted buildpack is missing expected files: buildpack.toml"))
+
+							Expect(packager.ExecuteCall.CallCount).To(Equal(0))
+							Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+						})
+					})
+
+					context("when the check is strict and an unexpected file is present", func() {
+						it.Before(func() {
+							remoteBuildpack.StrictFiles = true
+
+							buffer := bytes.NewBuffer(nil)
+							gw := gzip.NewWriter(buffer)
+							tw := tar.NewWriter(gw)
+
+							for _, name := range []string{"some-dir/some-file", "some-dir/some-extra-file"} {
+								Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len("some content"))})).To(Succeed())
+								_, err := tw.Write([]byte(`some content`))
+								Expect(err).NotTo(HaveOccurred())
+							}
+
+							Expect(tw.Close()).To(Succeed())
+							Expect(gw.Close()).To(Succeed())
+
+							gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(buffer)
+						})
+
+						it("returns an error", func() {
+							_, err := remoteFetcher.Get(remoteBuildpack)
+							Expect(err).To(MatchError("extracted buildpack contains unexpected files: some-extra-file"))
+
+							Expect(packager.ExecuteCall.CallCount).To(Equal(0))
+						})
+					})
+				})
 			})
 		})
 
-		context("when there is no cache entry", func() {
+		context("when the buildpack is pinned to a release id", func() {
 			it.Before(func() {
+				remoteBuildpack = freezer.NewRemoteBuildpackWithReleaseID("some-org", "some-repo", 12345)
+
+				gitReleaseFetcher.GetByIDCall.Returns.Release = github.Release{
+					ID:      12345,
+					TagName: "some-pinned-tag",
+					Assets: []github.ReleaseAsset{
+						{
+							URL: "some-pinned-url",
+						},
+					},
+				}
+
 				buildpackCache.GetCall.Returns.Bool = false
 			})
 
-			it("fetches the latest buildpack", func() {
+			it("fetches that release and caches it under a key including the id", func() {
 				uri, err := remoteFetcher.Get(remoteBuildpack)
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(gitReleaseFetcher.GetCall.Receives.Org).To(Equal("some-org"))
-				Expect(gitReleaseFetcher.GetCall.Receives.Repo).To(Equal("some-repo"))
-
-				Expect(buildpackCache.GetCall.CallCount).To(Equal(1))
+				Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(0))
+				Expect(gitReleaseFetcher.GetByIDCall.Receives.Org).To(Equal("some-org"))
+				Expect(gitReleaseFetcher.GetByIDCall.Receives.Repo).To(Equal("some-repo"))
+				Expect(gitReleaseFetcher.GetByIDCall.Receives.Id).To(Equal(int64(12345)))
 
 				Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset).To(Equal(github.ReleaseAsset{
-					URL: "some-url",
+					URL: "some-pinned-url",
 				}))
 
-				Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")).To(BeAnExistingFile())
-				file, err := os.Open(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz"))
-				Expect(err).ToNot(HaveOccurred())
-
-				err = vacation.NewArchive(file).Decompress(filepath.Join(cacheDir, "some-org", "some-repo"))
-				Expect(err).ToNot(HaveOccurred())
-
-				content, err := os.ReadFile(filepath.Join(cacheDir, "some-org", "some-repo", "some-file"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(content)).To(Equal("some content"))
-
-				Expect(buildpackCache.SetCall.CallCount).To(Equal(1))
+				Expect(buildpackCache.GetCall.Receives.Key).To(Equal("some-org:some-repo:12345"))
+				Expect(buildpackCache.SetCall.Receives.Key).To(Equal("some-org:some-repo:12345"))
 
-				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "12345", "some-pinned-tag.tgz")))
+				Expect(uri).To(BeAnExistingFile())
 			})
-		})
 
-		context("failure cases", func() {
-			context("when there is a failure in the gitReleaseFetcher get", func() {
+			context("when fetching the release by id fails", func() {
 				it.Before(func() {
-					gitReleaseFetcher.GetCall.Returns.Error = errors.New("unable to get release")
+					gitReleaseFetcher.GetByIDCall.Returns.Error = errors.New("unable to get release by id")
 				})
 
 				it("returns an error", func() {
 					_, err := remoteFetcher.Get(remoteBuildpack)
-					Expect(err).To(MatchError("unable to get release"))
+					Expect(err).To(MatchError("unable to get release by id"))
 				})
 			})
+		})
 
-			context("cache get fails", func() {
-				it.Before(func() {
-					buildpackCache.GetCall.Returns.Error = errors.New("failed get")
-				})
+		context("when the buildpack is pinned to a version constraint", func() {
+			it.Before(func() {
+				remoteBuildpack = freezer.NewRemoteBuildpackWithConstraint("some-org", "some-repo", "~1.2")
+
+				gitReleaseFetcher.ListReleasesCall.Returns.ReleaseSlice = []github.Release{
+					{TagName: "v2.0.0", Assets: []github.ReleaseAsset{{URL: "some-2.0.0-url"}}},
+					{TagName: "v1.2.9", Draft: true, Assets: []github.ReleaseAsset{{URL: "some-draft-url"}}},
+					{TagName: "some-nightly", Assets: []github.ReleaseAsset{{URL: "some-nightly-url"}}},
+					{TagName: "v1.2.3", Assets: []github.ReleaseAsset{{URL: "some-1.2.3-url"}}},
+					{TagName: "v1.2.10", Assets: []github.ReleaseAsset{{URL: "some-1.2.10-url"}}},
+					{TagName: "v1.1.0", Assets: []github.ReleaseAsset{{URL: "some-1.1.0-url"}}},
+				}
 
-				it("returns an error", func() {
-					_, err := remoteFetcher.Get(remoteBuildpack)
-					Expect(err).To(MatchError("failed get"))
-				})
+				buildpackCache.GetCall.Returns.Bool = false
 			})
 
-			context("when getting the release tarball fails", func() {
+			it("fetches the highest release satisfying the constraint", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "constraint-36ea43cdcfda", "v1.2.10.tgz")))
+
+				Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(0))
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset.URL).To(Equal("some-1.2.10-url"))
+
+				Expect(buildpackCache.SetCall.Receives.Key).To(Equal("some-org:some-repo:~1.2"))
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.Version).To(Equal("v1.2.10"))
+			})
+
+			context("when the latest release of the same repo is cached as well", func() {
+				var cacheManager freezer.CacheManager
+
 				it.Before(func() {
-					remoteBuildpack.Offline = true
-					gitReleaseFetcher.GetReleaseTarballCall.Returns.Error = errors.New("unable to get release tarball")
+					cacheManager = freezer.NewCacheManager(cacheDir)
+					Expect(cacheManager.Open()).To(Succeed())
+
+					gitReleaseFetcher.GetReleaseAssetCall.Stub = func(_ gocontext.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+						return io.NopCloser(strings.NewReader(asset.URL)), nil
+					}
+					gitReleaseFetcher.GetCall.Returns.Release = github.Release{TagName: "v1.2.10", Assets: []github.ReleaseAsset{{URL: "some-1.2.10-url"}}}
+
+					remoteFetcher = freezer.NewRemoteFetcher(&cacheManager, gitReleaseFetcher, packager, fileSystem)
 				})
 
-				it("returns an error", func() {
-					_, err := remoteFetcher.Get(remoteBuildpack)
-					Expect(err).To(MatchError("unable to get release tarball"))
+				it.After(func() {
+					Expect(cacheManager.Close()).To(Succeed())
+				})
+
+				it("keeps the artifact of the constraint when the latest moves on", func() {
+					constrained, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+
+					latest, err := remoteFetcher.Get(freezer.NewRemoteBuildpack("some-org", "some-repo"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(latest).NotTo(Equal(constrained))
+
+					gitReleaseFetcher.GetCall.Returns.Release = github.Release{TagName: "v2.0.0", Assets: []github.ReleaseAsset{{URL: "some-2.0.0-url"}}}
+
+					_, err = remoteFetcher.Get(freezer.NewRemoteBuildpack("some-org", "some-repo"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(latest).NotTo(BeAnExistingFile())
+
+					content, err := os.ReadFile(constrained)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal("some-1.2.10-url"))
+
+					result, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Reason).To(Equal(freezer.FetchReasonCached))
 				})
 			})
 
-			context("when getting the release asset fails", func() {
+			context("when no release satisfies the constraint", func() {
 				it.Before(func() {
-					gitReleaseFetcher.GetReleaseAssetCall.Returns.Error = errors.New("unable to get release asset")
+					remoteBuildpack = freezer.NewRemoteBuildpackWithConstraint("some-org", "some-repo", ">=3.0.0")
 				})
 
 				it("returns an error", func() {
 					_, err := remoteFetcher.Get(remoteBuildpack)
-					Expect(err).To(MatchError("unable to get release asset"))
+					Expect(err).To(MatchError(`no release of some-org/some-repo satisfies the version constraint ">=3.0.0"`))
 				})
-			})
 
-			context("when creating a temp directory fails", func() {
-				it.Before(func() {
-					gitReleaseFetcher.GetCall.Returns.Release = github.Release{
-						TagName:    "some-tag",
-						TarballURL: "some-tarball-url",
-					}
+				context("when the tag fallback is enabled", func() {
+					it.Before(func() {
+						fetcher := tagListingFetcher{
+							GitReleaseFetcher: gitReleaseFetcher,
+							tags: []github.Release{
+								{TagName: "v3.1.0", TarballURL: "some-3.1.0-tarball-url"},
+								{TagName: "v3.0.0", TarballURL: "some-3.0.0-tarball-url"},
+								{TagName: "v2.0.0", TarballURL: "some-2.0.0-tarball-url"},
+							},
+						}
+						remoteFetcher = freezer.NewRemoteFetcher(buildpackCache, fetcher, packager, fileSystem).WithTagFallback(true)
+					})
 
-					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
-						Version: "some-other-tag",
-					}
+					it("packages the highest satisfying tag from its source tarball", func() {
+						uri, err := remoteFetcher.Get(remoteBuildpack)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "constraint-c5aff8b43e50", "v3.1.0.tgz")))
 
-					fileSystem = freezer.NewFileSystem(func(string, string) (string, error) {
-						return "", errors.New("failed to create temp directory")
+						Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+						Expect(gitReleaseFetcher.GetReleaseTarballCall.Receives.Url).To(Equal("some-3.1.0-tarball-url"))
+						Expect(packager.ExecuteCall.Receives.Version).To(Equal("v3.1.0"))
+						Expect(buildpackCache.SetCall.Receives.CachedEntry.Version).To(Equal("v3.1.0"))
 					})
 
-					remoteFetcher = freezer.NewRemoteFetcher(buildpackCache, gitReleaseFetcher, packager, fileSystem)
-				})
+					context("when the release fetcher cannot list tags", func() {
+						it.Before(func() {
+							remoteFetcher = freezer.NewRemoteFetcher(buildpackCache, gitReleaseFetcher, packager, fileSystem).WithTagFallback(true)
+						})
 
-				it("returns an error", func() {
-					_, err := remoteFetcher.Get(remoteBuildpack)
-					Expect(err).To(MatchError("failed to create temp directory"))
+						it("returns an error", func() {
+							_, err := remoteFetcher.Get(remoteBuildpack)
+							Expect(err).To(MatchError("cannot fall back to the tags of some-org/some-repo: the release fetcher cannot list tags"))
+						})
+					})
 				})
 			})
 
-			context("when decompression fails", func() {
+			context("when the constraint is invalid", func() {
 				it.Before(func() {
-					gitReleaseFetcher.GetCall.Returns.Release = github.Release{
-						TagName:    "some-tag",
-						TarballURL: "some-tarball-url",
-					}
-
-					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
-						Version: "some-other-tag",
-					}
-					gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(bytes.NewBuffer(nil))
+					remoteBuildpack = freezer.NewRemoteBuildpackWithConstraint("some-org", "some-repo", "not a constraint")
 				})
 
 				it("returns an error", func() {
 					_, err := remoteFetcher.Get(remoteBuildpack)
-					Expect(err).To(MatchError(ContainSubstring("unsupported archive type: text/plain")))
+					Expect(err).To(MatchError(ContainSubstring(`invalid version constraint "not a constraint" for some-org/some-repo`)))
+
+					Expect(gitReleaseFetcher.ListReleasesCall.CallCount).To(Equal(0))
 				})
 			})
+		})
 
-			context("when packing fails", func() {
-				it.Before(func() {
-					gitReleaseFetcher.GetCall.Returns.Release = github.Release{
-						TagName:    "some-tag",
-						TarballURL: "some-tarball-url",
-					}
+		context("when a release fetcher is registered for the host of the buildpack", func() {
+			var hostFetcher *fakes.GitReleaseFetcher
 
-					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
-						Version: "some-other-tag",
-					}
-					packager.ExecuteCall.Returns.Error = errors.New("failed to package buildpack")
+			it.Before(func() {
+				var err error
+				remoteBuildpack, err = freezer.ParseRemoteBuildpack("https://gitlab.example.com/some-org/some-repo")
+				Expect(err).NotTo(HaveOccurred())
+
+				hostFetcher = &fakes.GitReleaseFetcher{}
+				hostFetcher.GetCall.Returns.Release = github.Release{
+					TagName: "some-gitlab-tag",
+					Assets:  []github.ReleaseAsset{{URL: "some-gitlab-url"}},
+				}
+				hostFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-gitlab-asset"))
+
+				buildpackCache.GetCall.Returns.Bool = false
+				remoteFetcher = remoteFetcher.WithHostFetcher("GitLab.example.com", hostFetcher)
+			})
+
+			it("fetches the buildpack through that fetcher", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "gitlab.example.com", "some-org", "some-repo", "some-gitlab-tag.tgz")))
+
+				Expect(hostFetcher.GetCall.Receives.Org).To(Equal("some-org"))
+				Expect(hostFetcher.GetReleaseAssetCall.Receives.Asset.URL).To(Equal("some-gitlab-url"))
+				Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(0))
+
+				Expect(buildpackCache.SetCall.Receives.Key).To(Equal("gitlab.example.com:some-org:some-repo"))
+			})
+
+			it("uses the default fetcher for other hosts", func() {
+				_, err := remoteFetcher.Get(freezer.NewRemoteBuildpack("some-org", "some-repo"))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(1))
+				Expect(hostFetcher.GetCall.CallCount).To(Equal(0))
+			})
+
+			context("when both hosts publish the same tag of the same org/repo", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release.TagName = "some-gitlab-tag"
+					gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-github-asset"))
 				})
 
-				it("returns an error", func() {
-					_, err := remoteFetcher.Get(remoteBuildpack)
-					Expect(err).To(MatchError("failed to package buildpack"))
+				it("keeps the artifact of each host apart", func() {
+					gitlabURI, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+
+					githubURI, err := remoteFetcher.Get(freezer.NewRemoteBuildpack("some-org", "some-repo"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(githubURI).NotTo(Equal(gitlabURI))
+
+					content, err := os.ReadFile(gitlabURI)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal("some-gitlab-asset"))
+
+					content, err = os.ReadFile(githubURI)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal("some-github-asset"))
 				})
 			})
+		})
 
-			context("when setting the new buildpack information failes", func() {
-				it.Before(func() {
-					buildpackCache.SetCall.Returns.Error = errors.New("failed to set new cache entry")
+		context("when the selected release is a pre-release", func() {
+			it.Before(func() {
+				gitReleaseFetcher.GetCall.Returns.Release.TagName = "some-rc-tag"
+				gitReleaseFetcher.GetCall.Returns.Release.Prerelease = true
 
-					Expect(os.MkdirAll(filepath.Join(cacheDir, "some-org", "some-repo"), os.ModePerm)).To(Succeed())
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("fetches it by default", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-rc-tag.tgz")))
+			})
+
+			context("when pre-releases are rejected", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithRejectPrerelease(true)
 				})
 
-				it("returns an error", func() {
+				it("returns an error without downloading anything", func() {
 					_, err := remoteFetcher.Get(remoteBuildpack)
-					Expect(err).To(MatchError("failed to set new cache entry"))
+					Expect(err).To(MatchError("release some-rc-tag of some-org/some-repo is a pre-release"))
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+					Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
 				})
 			})
 		})
-	})
+
+		context("when fetchers sharing a cache use different namespaces", func() {
+			var cache *freezer.MemoryCache
+
+			it.Before(func() {
+				var err error
+				cache, err = freezer.NewMemoryCache()
+				Expect(err).NotTo(HaveOccurred())
+
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader("some-artifact")), nil
+				}
+			})
+
+			it.After(func() {
+				Expect(cache.Close()).To(Succeed())
+			})
+
+			it("keeps their keys and paths apart", func() {
+				suiteA := freezer.NewRemoteFetcher(cache, gitReleaseFetcher, packager, fileSystem).WithNamespace("suite-a")
+				suiteB := freezer.NewRemoteFetcher(cache, gitReleaseFetcher, packager, fileSystem).WithNamespace("suite-b")
+
+				uriA, err := suiteA.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				uriB, err := suiteB.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(uriA).To(Equal(filepath.Join(cache.Dir(), "suite-a", "some-org", "some-repo", "some-tag.tgz")))
+				Expect(uriB).To(Equal(filepath.Join(cache.Dir(), "suite-b", "some-org", "some-repo", "some-tag.tgz")))
+				Expect(uriA).To(BeAnExistingFile())
+				Expect(uriB).To(BeAnExistingFile())
+
+				Expect(cache.List()).To(Equal([]string{"suite-a/some-org:some-repo", "suite-b/some-org:some-repo"}))
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(2))
+			})
+		})
+
+		context("when the release repository is verified", func() {
+			it.Before(func() {
+				remoteFetcher = remoteFetcher.WithVerifyRepository(true)
+
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("fetches a release that belongs to the requested repository", func() {
+				gitReleaseFetcher.GetCall.Returns.Release.HTMLURL = "https://github.com/Some-Org/some-repo/releases/tag/some-tag"
+
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+			})
+
+			context("when the release belongs to a different repository", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release.HTMLURL = "https://github.com/other-org/other-repo/releases/tag/some-tag"
+				})
+
+				it("returns an error without downloading anything", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError(`release some-tag does not belong to some-org/some-repo: "https://github.com/other-org/other-repo/releases/tag/some-tag"`))
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+				})
+
+				it("fetches it when the check is disabled", func() {
+					remoteFetcher = remoteFetcher.WithVerifyRepository(false)
+
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+		})
+
+		context("when a file listing is requested", func() {
+			it.Before(func() {
+				remoteFetcher = remoteFetcher.WithFileListing(true)
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("lists the files of a downloaded asset", func() {
+				result, err := remoteFetcher.Fetch(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Files).To(Equal([]freezer.ArchiveEntry{
+					{Name: "some-file", Size: int64(len("some content"))},
+				}))
+
+				Expect(result.URI).To(BeAnExistingFile())
+			})
+
+			it("lists the files of an extracted source tarball", func() {
+				remoteBuildpack.Offline = true
+
+				result, err := remoteFetcher.Fetch(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Files).To(Equal([]freezer.ArchiveEntry{
+					{Name: "some-file", Size: int64(len("some content"))},
+				}))
+
+				Expect(packager.ExecuteCall.CallCount).To(Equal(1))
+			})
+
+			context("when the download is not an archive", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-artifact"))
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).To(MatchError(ContainSubstring("failed to list archive")))
+				})
+			})
+		})
+
+		context("when accepted archive formats are configured", func() {
+			it.Before(func() {
+				remoteFetcher = remoteFetcher.WithAcceptedFormats(freezer.ArchiveFormatGzip, freezer.ArchiveFormatTar)
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("caches content in an accepted format", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				file, err := os.Open(uri)
+				Expect(err).NotTo(HaveOccurred())
+				defer file.Close()
+
+				Expect(vacation.NewArchive(file).Decompress(tmpDir)).To(Succeed())
+				Expect(filepath.Join(tmpDir, "some-file")).To(BeAnExistingFile())
+			})
+
+			context("when the server returns an HTML page", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("\n<!DOCTYPE html>\n<html><body>Not Found</body></html>"))
+				})
+
+				it("rejects it without caching anything", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("downloaded content is HTML, not an archive"))
+
+					Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")).NotTo(BeAnExistingFile())
+					Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+				})
+			})
+
+			context("when the content is an archive in a format that is not accepted", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithAcceptedFormats(freezer.ArchiveFormatTar)
+				})
+
+				it("rejects it", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("downloaded content is a gzip archive, which is not accepted"))
+				})
+			})
+
+			context("when the content is unknown binary data", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(bytes.NewReader([]byte{0x00, 0x01, 0x02}))
+				})
+
+				it("rejects it", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("downloaded content is not a recognized archive"))
+				})
+			})
+		})
+
+		context("when the buildpack check is enabled", func() {
+			var archive func(names ...string) io.ReadCloser
+
+			it.Before(func() {
+				remoteFetcher = remoteFetcher.WithBuildpackCheck(true)
+				buildpackCache.GetCall.Returns.Bool = false
+
+				archive = func(names ...string) io.ReadCloser {
+					buffer := bytes.NewBuffer(nil)
+					gw := gzip.NewWriter(buffer)
+					tw := tar.NewWriter(gw)
+
+					for _, name := range names {
+						Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len("some content"))})).To(Succeed())
+						_, err := tw.Write([]byte(`some content`))
+						Expect(err).NotTo(HaveOccurred())
+					}
+
+					Expect(tw.Close()).To(Succeed())
+					Expect(gw.Close()).To(Succeed())
+
+					return io.NopCloser(buffer)
+				}
+			})
+
+			it("accepts an asset with a buildpack.toml at its root", func() {
+				gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = archive("buildpack.toml", "bin/build")
+
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("accepts an asset with a buildpack.toml inside a single wrapping directory", func() {
+				gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = archive("some-dir/buildpack.toml", "some-dir/bin/build")
+
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("rejects an asset without a buildpack.toml", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).To(MatchError("asset of some-tag does not contain a buildpack.toml"))
+
+				Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")).NotTo(BeAnExistingFile())
+				Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+			})
+
+			it("rejects an asset whose buildpack.toml is not in its only top-level directory", func() {
+				gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = archive("some-dir/buildpack.toml", "other-dir/some-file")
+
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).To(MatchError("asset of some-tag does not contain a buildpack.toml"))
+			})
+
+			context("when the buildpack is offline", func() {
+				it.Before(func() {
+					remoteBuildpack.Offline = true
+				})
+
+				it("packages a source tarball with a buildpack.toml", func() {
+					gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = archive("some-dir/buildpack.toml")
+
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(packager.ExecuteCall.CallCount).To(Equal(1))
+				})
+
+				it("rejects a source tarball without a buildpack.toml before packaging", func() {
+					gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = archive("some-dir/some-file")
+
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("source tarball of some-tag does not contain a buildpack.toml"))
+					Expect(packager.ExecuteCall.CallCount).To(Equal(0))
+				})
+			})
+		})
+
+		context("when packaging retries are configured", func() {
+			var transient error
+
+			it.Before(func() {
+				transient = errors.New("some transient error")
+				remoteBuildpack.Offline = true
+				buildpackCache.GetCall.Returns.Bool = false
+
+				remoteFetcher = remoteFetcher.WithPackagingRetries(2, time.Millisecond, func(err error) bool {
+					return errors.Is(err, transient)
+				})
+			})
+
+			it("retries a packager that fails once and then succeeds", func() {
+				packager.ExecuteCall.Stub = func(_ gocontext.Context, _, output, _ string, _ bool) error {
+					if packager.ExecuteCall.CallCount == 1 {
+						return transient
+					}
+					return os.WriteFile(output, []byte("some-packaged-buildpack"), 0644)
+				}
+
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uri).To(BeAnExistingFile())
+				Expect(packager.ExecuteCall.CallCount).To(Equal(2))
+			})
+
+			it("gives up once the retries are exhausted", func() {
+				packager.ExecuteCall.Stub = nil
+				packager.ExecuteCall.Returns.Error = transient
+
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).To(MatchError("some transient error"))
+				Expect(packager.ExecuteCall.CallCount).To(Equal(3))
+			})
+
+			it("does not retry errors that are not transient", func() {
+				packager.ExecuteCall.Stub = nil
+				packager.ExecuteCall.Returns.Error = errors.New("some permanent error")
+
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).To(MatchError("some permanent error"))
+				Expect(packager.ExecuteCall.CallCount).To(Equal(1))
+			})
+		})
+
+		context("when checksum files are requested", func() {
+			it.Before(func() {
+				remoteFetcher = remoteFetcher.WithChecksumFile(true)
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("writes a sidecar with the digest of the cached artifact", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(uri + ".sha256")
+				Expect(err).NotTo(HaveOccurred())
+
+				artifact, err := os.ReadFile(uri)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(string(content)).To(Equal(fmt.Sprintf("%x  some-tag.tgz\n", sha256.Sum256(artifact))))
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.SHA256).To(Equal(fmt.Sprintf("%x", sha256.Sum256(artifact))))
+			})
+		})
+
+		context("when a progress handler is configured", func() {
+			var reports [][2]int64
+
+			it.Before(func() {
+				reports = nil
+				buildpackCache.GetCall.Returns.Bool = false
+
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+					return &github.Download{ReadCloser: io.NopCloser(strings.NewReader("some-asset-content")), Size: 18}, nil
+				}
+
+				remoteFetcher = remoteFetcher.WithProgress(func(downloaded, total int64) {
+					reports = append(reports, [2]int64{downloaded, total})
+				})
+			})
+
+			it("reports the bytes downloaded against the size of the download", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(reports).NotTo(BeEmpty())
+				Expect(reports[len(reports)-1]).To(Equal([2]int64{18, 18}))
+			})
+
+			context("when the size of the download is unknown", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+						return io.NopCloser(strings.NewReader("some-asset-content")), nil
+					}
+				})
+
+				it("reports a total of -1", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(reports[len(reports)-1]).To(Equal([2]int64{18, -1}))
+				})
+			})
+		})
+
+		context("when the release publishes a digest for the asset", func() {
+			var digest string
+
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = func(_ gocontext.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+					if asset.Name == "some-buildpack.tgz.sha256" {
+						return io.NopCloser(strings.NewReader(digest + "  some-buildpack.tgz\n")), nil
+					}
+					return io.NopCloser(strings.NewReader("some-asset-content")), nil
+				}
+
+				gitReleaseFetcher.GetCall.Returns.Release.Assets = []github.ReleaseAsset{
+					{Name: "some-buildpack.tgz", URL: "some-url"},
+					{Name: "some-buildpack.tgz.sha256", URL: "some-checksum-url"},
+				}
+
+				digest = fmt.Sprintf("%x", sha256.Sum256([]byte("some-asset-content")))
+			})
+
+			it("verifies the download against the .sha256 asset and records the digest", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uri).To(BeAnExistingFile())
+
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.SHA256).To(Equal(digest))
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.PublishedSHA256).To(Equal(digest))
+			})
+
+			context("when the digest is in the release notes", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release.Assets = gitReleaseFetcher.GetCall.Returns.Release.Assets[:1]
+					gitReleaseFetcher.GetCall.Returns.Release.Body = fmt.Sprintf("## Checksums\n\n* `some-buildpack.tgz`: sha256:%s\n", strings.ToUpper(digest))
+				})
+
+				it("verifies the download against it", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(buildpackCache.SetCall.Receives.CachedEntry.PublishedSHA256).To(Equal(digest))
+				})
+			})
+
+			context("when the download does not match the digest", func() {
+				it.Before(func() {
+					digest = fmt.Sprintf("%x", sha256.Sum256([]byte("some-other-content")))
+				})
+
+				it("removes the artifact and returns a DownloadError", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError(fmt.Sprintf("asset some-buildpack.tgz of release some-tag does not match its published digest: expected %s, got %x", digest, sha256.Sum256([]byte("some-asset-content")))))
+
+					var downloadErr *freezer.DownloadError
+					Expect(errors.As(err, &downloadErr)).To(BeTrue())
+
+					Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")).NotTo(BeAnExistingFile())
+					Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+				})
+			})
+
+			context("when the .sha256 asset does not contain a digest", func() {
+				it.Before(func() {
+					digest = "not-a-digest"
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("failed to read the published digest of some-buildpack.tgz: some-buildpack.tgz.sha256 does not contain a sha256 digest"))
+				})
+			})
+		})
+
+		context("when a mode mask is configured", func() {
+			var modes map[string]os.FileMode
+
+			it.Before(func() {
+				remoteBuildpack.Offline = true
+				buildpackCache.GetCall.Returns.Bool = false
+
+				buffer := bytes.NewBuffer(nil)
+				gw := gzip.NewWriter(buffer)
+				tw := tar.NewWriter(gw)
+
+				for name, mode := range map[string]int64{
+					"some-dir/setuid-file":  04755,
+					"some-dir/regular-file": 0644,
+				} {
+					Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: int64(len("some content"))})).To(Succeed())
+					_, err := tw.Write([]byte(`some content`))
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				Expect(tw.Close()).To(Succeed())
+				Expect(gw.Close()).To(Succeed())
+
+				gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(buffer)
+
+				modes = map[string]os.FileMode{}
+				packager.ExecuteCall.Stub = func(_ gocontext.Context, dir, output, _ string, _ bool) error {
+					for _, name := range []string{"setuid-file", "regular-file"} {
+						info, err := os.Stat(filepath.Join(dir, name))
+						if err != nil {
+							return err
+						}
+						modes[name] = info.Mode()
+					}
+
+					return os.WriteFile(output, []byte("some-packaged-buildpack"), 0644)
+				}
+
+				remoteFetcher = remoteFetcher.WithModeMask(os.ModeSetuid | os.ModeSetgid | 0007)
+			})
+
+			it("strips the masked bits from extracted files before packaging", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(modes["setuid-file"]).To(Equal(os.FileMode(0750)))
+				Expect(modes["regular-file"]).To(Equal(os.FileMode(0640)))
+			})
+		})
+
+		context("when an extraction concurrency is configured", func() {
+			var contents map[string]string
+
+			it.Before(func() {
+				remoteBuildpack.Offline = true
+				buildpackCache.GetCall.Returns.Bool = false
+
+				buffer := bytes.NewBuffer(nil)
+				gw := gzip.NewWriter(buffer)
+				tw := tar.NewWriter(gw)
+
+				for i := 0; i < 100; i++ {
+					content := fmt.Sprintf("some-content-%d", i)
+					Expect(tw.WriteHeader(&tar.Header{Name: fmt.Sprintf("some-org-some-repo-1234/dir-%d/file-%d", i%10, i), Mode: 0644, Size: int64(len(content))})).To(Succeed())
+					_, err := tw.Write([]byte(content))
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				Expect(tw.Close()).To(Succeed())
+				Expect(gw.Close()).To(Succeed())
+
+				gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(buffer)
+
+				contents = map[string]string{}
+				packager.ExecuteCall.Stub = func(_ gocontext.Context, dir, output, _ string, _ bool) error {
+					for i := 0; i < 100; i++ {
+						name := fmt.Sprintf("dir-%d/file-%d", i%10, i)
+						content, err := os.ReadFile(filepath.Join(dir, name))
+						if err != nil {
+							return err
+						}
+						contents[name] = string(content)
+					}
+
+					return os.WriteFile(output, []byte("some-packaged-buildpack"), 0644)
+				}
+
+				remoteFetcher = remoteFetcher.WithExtractionConcurrency(4)
+			})
+
+			it("extracts every file of the source tarball before packaging", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(contents).To(HaveLen(100))
+				Expect(contents).To(HaveKeyWithValue("dir-3/file-43", "some-content-43"))
+			})
+		})
+
+		context("when the download is empty", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+				gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader(""))
+				gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(strings.NewReader(""))
+			})
+
+			it("rejects an empty asset without caching it", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).To(MatchError("asset of some-tag is 0 bytes, below the minimum of 1"))
+
+				Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")).NotTo(BeAnExistingFile())
+				Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+			})
+
+			it("rejects an empty source tarball before packaging it", func() {
+				remoteBuildpack.Offline = true
+
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).To(MatchError("source tarball of some-tag is 0 bytes, below the minimum of 1"))
+				Expect(packager.ExecuteCall.CallCount).To(Equal(0))
+			})
+
+			context("when a larger minimum size is configured", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithMinimumSize(1024)
+					gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-artifact"))
+				})
+
+				it("rejects assets below it", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("asset of some-tag is 13 bytes, below the minimum of 1024"))
+				})
+			})
+		})
+
+		context("when the cached variant naming is configured", func() {
+			it.Before(func() {
+				remoteFetcher = remoteFetcher.WithCachedVariant(":offline", "offline")
+				remoteBuildpack.Offline = true
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("uses the custom suffix in the key and the path", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(buildpackCache.GetCall.Receives.Key).To(Equal("some-org:some-repo:offline"))
+				Expect(buildpackCache.SetCall.Receives.Key).To(Equal("some-org:some-repo:offline"))
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "offline", "some-tag.tgz")))
+			})
+		})
+
+		context("when output reuse is enabled", func() {
+			var (
+				path  string
+				clock *fakes.Clock
+			)
+
+			it.Before(func() {
+				remoteBuildpack.Offline = true
+				path = filepath.Join(cacheDir, "some-org", "some-repo", "cached", "some-tag.tgz")
+
+				Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(path, []byte("some-packaged-buildpack"), 0644)).To(Succeed())
+
+				clock = &fakes.Clock{}
+				clock.NowCall.Returns.Time = time.Date(2022, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+				buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+					Version:   "some-tag",
+					URI:       path,
+					FetchedAt: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+					SHA256:    fmt.Sprintf("%x", sha256.Sum256([]byte("some-packaged-buildpack"))),
+				}
+
+				remoteFetcher = remoteFetcher.WithClock(clock).WithTTL(time.Hour).WithOutputReuse(true)
+			})
+
+			it("skips packaging when the existing output is valid", func() {
+				result, err := remoteFetcher.Fetch(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Reason).To(Equal(freezer.FetchReasonExpired))
+				Expect(result.URI).To(Equal(path))
+
+				Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(0))
+				Expect(packager.ExecuteCall.CallCount).To(Equal(0))
+
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.FetchedAt).To(Equal(time.Date(2022, time.January, 2, 0, 0, 0, 0, time.UTC)))
+			})
+
+			context("when the cache cannot be written to", func() {
+				it.Before(func() {
+					buildpackCache.SetCall.Returns.Error = errors.New("the cache is read-only")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).To(MatchError("the cache is read-only"))
+				})
+
+				context("when read-only caches are tolerated", func() {
+					it.Before(func() {
+						remoteFetcher = remoteFetcher.WithReadOnlyTolerance(true)
+					})
+
+					it("returns the existing artifact", func() {
+						result, err := remoteFetcher.Fetch(remoteBuildpack)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(result.URI).To(Equal(path))
+						Expect(result.Fetched).To(BeFalse())
+					})
+
+					it("still fails when the artifact had to be downloaded", func() {
+						Expect(os.WriteFile(path, []byte("some-corrupted-buildpack"), 0644)).To(Succeed())
+
+						_, err := remoteFetcher.Fetch(remoteBuildpack)
+						Expect(err).To(MatchError("the cache is read-only"))
+					})
+				})
+			})
+
+			context("when the existing output does not match its digest", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte("some-corrupted-buildpack"), 0644)).To(Succeed())
+				})
+
+				it("packages the buildpack again", func() {
+					_, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(packager.ExecuteCall.CallCount).To(Equal(1))
+				})
+			})
+		})
+
+		context("when optional assets are configured", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+				gitReleaseFetcher.GetCall.Returns.Release.Assets = []github.ReleaseAsset{
+					{URL: "some-url", Name: "some-buildpack.tgz"},
+					{URL: "some-signature-url", Name: "some-buildpack.tgz.sig"},
+				}
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = func(_ gocontext.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader(fmt.Sprintf("content of %s", asset.URL))), nil
+				}
+
+				remoteFetcher = remoteFetcher.WithOptionalAssets("some-buildpack.tgz.sig", "sbom.json")
+			})
+
+			it("downloads the assets that exist and warns about the missing ones", func() {
+				result, err := remoteFetcher.Fetch(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Fetched).To(BeTrue())
+
+				Expect(result.Warnings).To(Equal([]string{"release some-tag of some-org/some-repo has no optional asset sbom.json"}))
+
+				signature := filepath.Join(cacheDir, "some-org", "some-repo", "some-tag-some-buildpack.tgz.sig")
+				Expect(result.OptionalAssets).To(Equal(map[string]string{"some-buildpack.tgz.sig": signature}))
+
+				content, err := os.ReadFile(signature)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("content of some-signature-url"))
+			})
+
+			context("when an optional asset fails to download", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetReleaseAssetCall.Stub = func(_ gocontext.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+						if asset.URL == "some-signature-url" {
+							return nil, errors.New("failed to download")
+						}
+						return io.NopCloser(strings.NewReader("some-artifact")), nil
+					}
+				})
+
+				it("warns instead of failing", func() {
+					result, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Warnings).To(ContainElement("skipped optional asset some-buildpack.tgz.sig of some-tag: failed to download"))
+				})
+			})
+		})
+
+		context("when fsync is enabled", func() {
+			it.Before(func() {
+				remoteFetcher = remoteFetcher.WithFsync(true)
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("caches a downloaded asset", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uri).To(BeAnExistingFile())
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.URI).To(Equal(uri))
+			})
+
+			it("caches a packaged source tarball", func() {
+				remoteBuildpack.Offline = true
+
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := os.ReadFile(uri)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("some-packaged-buildpack"))
+			})
+
+			context("when the artifact cannot be synced", func() {
+				it.Before(func() {
+					packager.ExecuteCall.Stub = func(_ gocontext.Context, _, output, _ string, _ bool) error {
+						err := os.WriteFile(output, []byte("some-packaged-buildpack"), 0644)
+						if err != nil {
+							return err
+						}
+						return os.Chmod(output, 0200)
+					}
+					remoteBuildpack.Offline = true
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError(ContainSubstring("failed to sync")))
+				})
+			})
+		})
+
+		context("when the source tarball is extracted", func() {
+			var pattern string
+
+			it.Before(func() {
+				remoteBuildpack = freezer.NewRemoteBuildpack("some-org", "some/repo name")
+				remoteBuildpack.Offline = true
+				buildpackCache.GetCall.Returns.Bool = false
+
+				fileSystem = freezer.NewFileSystem(func(dir, p string) (string, error) {
+					pattern = p
+					return os.MkdirTemp(tmpDir, p)
+				})
+				remoteFetcher = freezer.NewRemoteFetcher(buildpackCache, gitReleaseFetcher, packager, fileSystem)
+			})
+
+			it("names the temporary directory after the sanitized buildpack", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pattern).To(Equal("some-org-some_repo_name-"))
+			})
+		})
+
+		context("when deduplication is enabled", func() {
+			var (
+				started chan struct{}
+				release chan struct{}
+			)
+
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+
+				started = make(chan struct{})
+				release = make(chan struct{})
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+					close(started)
+					<-release
+					return io.NopCloser(strings.NewReader("some-artifact")), nil
+				}
+
+				remoteFetcher = remoteFetcher.WithDeduplication(true)
+			})
+
+			it("shares one download between concurrent identical fetches", func() {
+				var (
+					wg      sync.WaitGroup
+					uris    = make([]string, 5)
+					errs    = make([]error, 5)
+					fetcher = remoteFetcher
+				)
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					uris[0], errs[0] = fetcher.Get(remoteBuildpack)
+				}()
+				<-started
+
+				for i := 1; i < len(uris); i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						uris[i], errs[i] = fetcher.Get(remoteBuildpack)
+					}(i)
+				}
+
+				// Give the other fetches time to join the one in flight
+				time.Sleep(100 * time.Millisecond)
+				close(release)
+				wg.Wait()
+
+				for i := range uris {
+					Expect(errs[i]).NotTo(HaveOccurred())
+					Expect(uris[i]).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+				}
+
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+				Expect(buildpackCache.SetCall.CallCount).To(Equal(1))
+			})
+		})
+
+		context("when a release predicate is configured", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+				remoteBuildpack.Offline = true
+
+				gitReleaseFetcher.ListReleasesCall.Returns.ReleaseSlice = []github.Release{
+					{TagName: "some-draft-tag", Draft: true, Assets: []github.ReleaseAsset{{Name: "some-buildpack-cached.tgz", URL: "some-draft-url"}}},
+					{TagName: "some-newer-tag", Assets: []github.ReleaseAsset{{Name: "some-buildpack.tgz", URL: "some-newer-url"}}},
+					{TagName: "some-older-tag", Assets: []github.ReleaseAsset{{Name: "some-buildpack-cached.tgz", URL: "some-older-url"}}},
+				}
+
+				remoteFetcher = remoteFetcher.
+					WithAssetPolicy(freezer.AssetPolicySourceIfNoCachedAsset).
+					WithReleasePredicate(func(release github.Release) bool {
+						for _, asset := range release.Assets {
+							if strings.HasSuffix(asset.Name, "-cached.tgz") {
+								return true
+							}
+						}
+						return false
+					})
+			})
+
+			it("skips to the newest release the predicate accepts", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "cached", "some-older-tag.tgz")))
+
+				Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(0))
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset.URL).To(Equal("some-older-url"))
+			})
+
+			context("when no release satisfies the predicate", func() {
+				it.Before(func() {
+					gitReleaseFetcher.ListReleasesCall.Returns.ReleaseSlice = gitReleaseFetcher.ListReleasesCall.Returns.ReleaseSlice[:2]
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("no release of some-org/some-repo satisfies the release predicate"))
+				})
+			})
+		})
+
+		context("when the versioned layout is enabled", func() {
+			var cacheManager freezer.CacheManager
+
+			it.Before(func() {
+				cacheManager = freezer.NewCacheManager(cacheDir).WithHistory(true)
+				Expect(cacheManager.Open()).To(Succeed())
+
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader("some-artifact")), nil
+				}
+
+				remoteFetcher = freezer.NewRemoteFetcher(&cacheManager, gitReleaseFetcher, packager, fileSystem).
+					WithVersionedLayout(true)
+			})
+
+			it("keeps a directory for every fetched version", func() {
+				first, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(first).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag", "buildpack.tgz")))
+
+				gitReleaseFetcher.GetCall.Returns.Release.TagName = "some-other-tag"
+				second, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(second).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-other-tag", "buildpack.tgz")))
+
+				Expect(first).To(BeAnExistingFile())
+				Expect(second).To(BeAnExistingFile())
+
+				result, err := remoteFetcher.Fetch(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Reason).To(Equal(freezer.FetchReasonCached))
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(2))
+
+				current, ok, err := cacheManager.Get("some-org:some-repo")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				Expect(current.URI).To(Equal(second))
+
+				history := cacheManager.History("some-org:some-repo")
+				Expect(history).To(HaveLen(1))
+				Expect(history[0].URI).To(Equal(first))
+
+				Expect(cacheManager.Close()).To(Succeed())
+			})
+		})
+
+		context("when the cache records the use of its entries", func() {
+			var (
+				cacheManager freezer.CacheManager
+				clock        *fakes.Clock
+			)
+
+			it.Before(func() {
+				clock = &fakes.Clock{}
+				clock.NowCall.Returns.Time = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+				cacheManager = freezer.NewCacheManager(cacheDir).WithClock(clock)
+				Expect(cacheManager.Open()).To(Succeed())
+
+				remoteFetcher = freezer.NewRemoteFetcher(&cacheManager, gitReleaseFetcher, packager, fileSystem)
+			})
+
+			it.After(func() {
+				Expect(cacheManager.Close()).To(Succeed())
+			})
+
+			it("records a use on every cache hit", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cacheManager.Cache["some-org:some-repo"].LastUsedAt).To(BeZero())
+
+				clock.NowCall.Returns.Time = time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+				result, err := remoteFetcher.Fetch(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Reason).To(Equal(freezer.FetchReasonCached))
+				Expect(cacheManager.Cache["some-org:some-repo"].LastUsedAt).To(Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)))
+			})
+		})
+
+		context("when identity keys are enabled", func() {
+			var cacheManager freezer.CacheManager
+
+			it.Before(func() {
+				cacheManager = freezer.NewCacheManager(cacheDir)
+				Expect(cacheManager.Open()).To(Succeed())
+
+				buffer := bytes.NewBuffer(nil)
+				gw := gzip.NewWriter(buffer)
+				tw := tar.NewWriter(gw)
+
+				content := []byte(`[buildpack]
+id = "some-buildpack-id"
+version = "1.2.3"
+`)
+				Expect(tw.WriteHeader(&tar.Header{Name: "buildpack.toml", Mode: 0644, Size: int64(len(content))})).To(Succeed())
+				_, err := tw.Write(content)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(tw.Close()).To(Succeed())
+				Expect(gw.Close()).To(Succeed())
+
+				artifact := buffer.Bytes()
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(artifact)), nil
+				}
+
+				remoteFetcher = freezer.NewRemoteFetcher(&cacheManager, gitReleaseFetcher, packager, fileSystem).
+					WithIdentityKeys(true)
+			})
+
+			it.After(func() {
+				Expect(cacheManager.Close()).To(Succeed())
+			})
+
+			it("shares one cache entry between repositories of the same buildpack", func() {
+				first, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(first).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+
+				mirror := freezer.NewRemoteBuildpack("some-mirror-org", "some-mirror-repo")
+				second, err := remoteFetcher.Get(mirror)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(second).To(Equal(first))
+				Expect(filepath.Join(cacheDir, "some-mirror-org", "some-mirror-repo", "some-tag.tgz")).NotTo(BeAnExistingFile())
+
+				entry, ok, err := cacheManager.Get("some-buildpack-id@1.2.3")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				Expect(entry.URI).To(Equal(first))
+				Expect(cacheManager.Cache[mirror.UncachedKey].URI).To(Equal(first))
+
+				result, err := remoteFetcher.Fetch(mirror)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Reason).To(Equal(freezer.FetchReasonCached))
+			})
+
+			context("when the artifact does not declare an id and version", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetReleaseAssetCall.Stub = nil
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError(ContainSubstring("archive does not contain a buildpack.toml")))
+				})
+			})
+		})
+
+		context("when source reuse is enabled", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+
+				remoteFetcher = remoteFetcher.
+					WithAssetPolicy(freezer.AssetPolicyAlwaysSource).
+					WithSourceReuse(true)
+			})
+
+			it("packages the other variant from the source it already extracted", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(downloadDir).To(BeADirectory())
+
+				remoteBuildpack.Offline = true
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "cached", "some-tag.tgz")))
+
+				Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(1))
+				Expect(packager.ExecuteCall.CallCount).To(Equal(2))
+				Expect(packager.ExecuteCall.Receives.BuildpackDir).To(Equal(downloadDir))
+				Expect(packager.ExecuteCall.Receives.Cached).To(BeTrue())
+				Expect(downloadDir).NotTo(BeADirectory())
+			})
+
+			it("removes unused sources when they are discarded", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(downloadDir).To(BeADirectory())
+
+				Expect(remoteFetcher.DiscardSources()).To(Succeed())
+				Expect(downloadDir).NotTo(BeADirectory())
+			})
+		})
+
+		context("when the buildpack overrides the cache directory", func() {
+			var overrideDir string
+
+			it.Before(func() {
+				var err error
+				overrideDir, err = os.MkdirTemp("", "override")
+				Expect(err).NotTo(HaveOccurred())
+
+				remoteBuildpack.CacheDir = overrideDir
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it.After(func() {
+				Expect(os.RemoveAll(overrideDir)).To(Succeed())
+			})
+
+			it("stores the artifact in the override directory", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(uri).To(Equal(filepath.Join(overrideDir, "some-org", "some-repo", "some-tag.tgz")))
+				Expect(uri).To(BeAnExistingFile())
+				Expect(filepath.Join(cacheDir, "some-org")).NotTo(BeADirectory())
+
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.URI).To(Equal(uri))
+			})
+		})
+
+		context("when a release has assets but no tarball url", func() {
+			it.Before(func() {
+				gitReleaseFetcher.GetCall.Returns.Release.TarballURL = ""
+
+				remoteBuildpack.Offline = true
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("returns an error without requesting an empty url", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).To(MatchError("release some-tag of some-org/some-repo has no source tarball url"))
+
+				Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(0))
+			})
+
+			context("when a tarball fallback is configured", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithTarballFallback("https://api.example.com/")
+				})
+
+				it("requests the tarball endpoint for the tag", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.Receives.Url).To(Equal("https://api.example.com/repos/some-org/some-repo/tarball/some-tag"))
+					Expect(packager.ExecuteCall.CallCount).To(Equal(1))
+				})
+			})
+		})
+
+		context("when the source tarball is not found", func() {
+			var notFound error
+
+			it.Before(func() {
+				remoteBuildpack.Offline = true
+				buildpackCache.GetCall.Returns.Bool = false
+
+				notFound = &github.StatusError{StatusCode: 404, Status: "404 Not Found"}
+				gitReleaseFetcher.GetReleaseTarballCall.Stub = func(_ gocontext.Context, url string) (io.ReadCloser, error) {
+					if url == "some-tarball-url" {
+						return nil, notFound
+					}
+					return gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser, nil
+				}
+			})
+
+			it("returns an error pointing at the release", func() {
+				_, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).To(MatchError("source tarball of release some-tag of some-org/some-repo was not found at some-tarball-url, check that the release and its tag still exist: unexpected response status: 404 Not Found"))
+				Expect(errors.Is(err, notFound)).To(BeTrue())
+
+				Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(1))
+			})
+
+			context("when not found tarballs are retried", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithTarballNotFoundRetries(2, time.Millisecond)
+				})
+
+				it("retries before giving up", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError(ContainSubstring("was not found at some-tarball-url")))
+
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(3))
+				})
+
+				it("recovers from a transient 404", func() {
+					gitReleaseFetcher.GetReleaseTarballCall.Stub = func(gocontext.Context, string) (io.ReadCloser, error) {
+						if gitReleaseFetcher.GetReleaseTarballCall.CallCount == 1 {
+							return nil, notFound
+						}
+						return gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser, nil
+					}
+
+					result, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.ResolvedURL).To(Equal("some-tarball-url"))
+
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(2))
+				})
+			})
+
+			context("when a tarball fallback is configured", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithTarballFallback("https://api.example.com")
+				})
+
+				it("downloads the tarball from the tarball endpoint for the tag", func() {
+					result, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.ResolvedURL).To(Equal("https://api.example.com/repos/some-org/some-repo/tarball/some-tag"))
+
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(2))
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.Receives.Url).To(Equal(result.ResolvedURL))
+					Expect(packager.ExecuteCall.CallCount).To(Equal(1))
+				})
+			})
+		})
+
+		context("when an asset policy is configured", func() {
+			it.Before(func() {
+				gitReleaseFetcher.GetCall.Returns.Release.Assets = []github.ReleaseAsset{
+					{URL: "some-url", Name: "some-buildpack.tgz"},
+					{URL: "some-cached-url", Name: "some-buildpack-cached.tgz"},
+				}
+
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			context("when the policy prefers artifacts", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithAssetPolicy(freezer.AssetPolicyPreferArtifact)
+				})
+
+				it("uses the first asset for uncached buildpacks", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset.URL).To(Equal("some-url"))
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(0))
+				})
+
+				it("builds cached buildpacks from source", func() {
+					remoteBuildpack.Offline = true
+
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(1))
+					Expect(packager.ExecuteCall.CallCount).To(Equal(1))
+				})
+			})
+
+			context("when the policy is to always use source", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithAssetPolicy(freezer.AssetPolicyAlwaysSource)
+				})
+
+				it("builds uncached buildpacks from source", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(1))
+					Expect(packager.ExecuteCall.Receives.Cached).To(BeFalse())
+				})
+			})
+
+			context("when the policy is to use source only without a cached asset", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithAssetPolicy(freezer.AssetPolicySourceIfNoCachedAsset)
+					remoteBuildpack.Offline = true
+				})
+
+				it("uses the cached asset for cached buildpacks", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset.URL).To(Equal("some-cached-url"))
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(0))
+					Expect(packager.ExecuteCall.CallCount).To(Equal(0))
+				})
+
+				it("builds from source when there is no cached asset", func() {
+					gitReleaseFetcher.GetCall.Returns.Release.Assets = gitReleaseFetcher.GetCall.Returns.Release.Assets[:1]
+
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(1))
+				})
+			})
+
+			context("when the buildpack sets its own policy", func() {
+				it.Before(func() {
+					remoteFetcher = remoteFetcher.WithAssetPolicy(freezer.AssetPolicyPreferArtifact)
+					remoteBuildpack.AssetPolicy = freezer.AssetPolicyAlwaysSource
+				})
+
+				it("overrides the fetcher policy", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(1))
+				})
+			})
+		})
+
+		context("when a publisher is configured", func() {
+			var publisher *fakes.Publisher
+
+			it.Before(func() {
+				publisher = &fakes.Publisher{}
+				publisher.PublishCall.Returns.String = "registry.local/some-org/some-repo@sha256:some-digest"
+
+				remoteFetcher = remoteFetcher.WithPublisher(publisher, "registry.local")
+
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("pushes the fetched buildpack and records the reference", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).ToNot(HaveOccurred())
+
+				path := filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")
+				Expect(publisher.PublishCall.Receives.Path).To(Equal(path))
+				Expect(publisher.PublishCall.Receives.Reference).To(Equal("registry.local/some-org/some-repo:some-tag"))
+
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.URI).To(Equal(path))
+				Expect(buildpackCache.SetCall.Receives.CachedEntry.Reference).To(Equal("registry.local/some-org/some-repo@sha256:some-digest"))
+
+				Expect(uri).To(Equal("registry.local/some-org/some-repo@sha256:some-digest"))
+			})
+
+			context("when the cached buildpack has already been published", func() {
+				it.Before(func() {
+					buildpackCache.GetCall.Returns.Bool = true
+					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+						Version:   "some-tag",
+						URI:       cachedPath,
+						Reference: "registry.local/some-org/some-repo@sha256:some-digest",
+					}
+				})
+
+				it("returns the recorded reference without pushing again", func() {
+					uri, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(publisher.PublishCall.CallCount).To(Equal(0))
+					Expect(uri).To(Equal("registry.local/some-org/some-repo@sha256:some-digest"))
+				})
+			})
+
+			context("when the buildpack should be cached", func() {
+				it.Before(func() {
+					remoteBuildpack.Offline = true
+				})
+
+				it("pushes it under a cached tag", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(publisher.PublishCall.Receives.Reference).To(Equal("registry.local/some-org/some-repo:some-tag-cached"))
+				})
+			})
+
+			context("when publishing fails", func() {
+				it.Before(func() {
+					publisher.PublishCall.Returns.Error = errors.New("unauthorized")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("failed to publish buildpack: unauthorized"))
+
+					Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+				})
+			})
+		})
+
+		context("when there is no cache entry", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+			})
+
+			it("fetches the latest buildpack", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(gitReleaseFetcher.GetCall.Receives.Org).To(Equal("some-org"))
+				Expect(gitReleaseFetcher.GetCall.Receives.Repo).To(Equal("some-repo"))
+
+				Expect(buildpackCache.GetCall.CallCount).To(Equal(1))
+
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset).To(Equal(github.ReleaseAsset{
+					URL: "some-url",
+				}))
+
+				Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")).To(BeAnExistingFile())
+				file, err := os.Open(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz"))
+				Expect(err).ToNot(HaveOccurred())
+
+				err = vacation.NewArchive(file).Decompress(filepath.Join(cacheDir, "some-org", "some-repo"))
+				Expect(err).ToNot(HaveOccurred())
+
+				content, err := os.ReadFile(filepath.Join(cacheDir, "some-org", "some-repo", "some-file"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("some content"))
+
+				Expect(buildpackCache.SetCall.CallCount).To(Equal(1))
+
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when there is a failure in the gitReleaseFetcher get", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Error = errors.New("unable to get release")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("unable to get release"))
+				})
+			})
+
+			context("cache get fails", func() {
+				it.Before(func() {
+					buildpackCache.GetCall.Returns.Error = errors.New("failed get")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("failed get"))
+				})
+			})
+
+			context("when getting the release tarball fails", func() {
+				it.Before(func() {
+					remoteBuildpack.Offline = true
+					gitReleaseFetcher.GetReleaseTarballCall.Returns.Error = errors.New("unable to get release tarball")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("unable to get release tarball"))
+				})
+			})
+
+			context("when getting the release asset fails", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetReleaseAssetCall.Returns.Error = errors.New("unable to get release asset")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("unable to get release asset"))
+				})
+			})
+
+			context("when creating a temp directory fails", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release = github.Release{
+						TagName:    "some-tag",
+						TarballURL: "some-tarball-url",
+					}
+
+					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+						Version: "some-other-tag",
+					}
+
+					fileSystem = freezer.NewFileSystem(func(string, string) (string, error) {
+						return "", errors.New("failed to create temp directory")
+					})
+
+					remoteFetcher = freezer.NewRemoteFetcher(buildpackCache, gitReleaseFetcher, packager, fileSystem)
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("failed to create temp directory"))
+				})
+			})
+
+			context("when decompression fails", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release = github.Release{
+						TagName:    "some-tag",
+						TarballURL: "some-tarball-url",
+					}
+
+					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+						Version: "some-other-tag",
+					}
+					gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(bytes.NewReader([]byte{0x00, 0x01, 0x02}))
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError(ContainSubstring("unsupported archive type: application/octet-stream")))
+				})
+			})
+
+			context("when packing fails", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release = github.Release{
+						TagName:    "some-tag",
+						TarballURL: "some-tarball-url",
+					}
+
+					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+						Version: "some-other-tag",
+					}
+					packager.ExecuteCall.Stub = nil
+					packager.ExecuteCall.Returns.Error = errors.New("failed to package buildpack")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("failed to package buildpack"))
+				})
+			})
+
+			context("when packing succeeds without producing the output file", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release = github.Release{
+						TagName:    "some-tag",
+						TarballURL: "some-tarball-url",
+					}
+
+					buildpackCache.GetCall.Returns.Bool = false
+					packager.ExecuteCall.Stub = nil
+				})
+
+				it("returns an error without recording an entry", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError(And(HavePrefix("packager did not produce"), HaveSuffix("some-tag.tgz"))))
+					Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")).NotTo(BeAnExistingFile())
+
+					Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+				})
+			})
+
+			context("when setting the new buildpack information failes", func() {
+				it.Before(func() {
+					buildpackCache.SetCall.Returns.Error = errors.New("failed to set new cache entry")
+
+					Expect(os.MkdirAll(filepath.Join(cacheDir, "some-org", "some-repo"), os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Get(remoteBuildpack)
+					Expect(err).To(MatchError("failed to set new cache entry"))
+				})
+			})
+		})
+	})
+
+	context("GetAll", func() {
+		var buildpacks []freezer.RemoteBuildpack
+
+		it.Before(func() {
+			buildpackCache.GetCall.Returns.Bool = false
+
+			buildpacks = []freezer.RemoteBuildpack{
+				freezer.NewRemoteBuildpack("some-org", "some-repo"),
+				freezer.NewRemoteBuildpack("some-org", "failing-repo"),
+				freezer.NewRemoteBuildpack("some-org", "other-repo"),
+			}
+
+			gitReleaseFetcher.GetCall.Stub = func(_ gocontext.Context, org, repo string) (github.Release, error) {
+				if repo == "failing-repo" {
+					return github.Release{}, errors.New("failed to get release")
+				}
+				return github.Release{TagName: "some-tag", Assets: []github.ReleaseAsset{{URL: fmt.Sprintf("some-%s-url", repo)}}}, nil
+			}
+			gitReleaseFetcher.GetReleaseAssetCall.Stub = func(_ gocontext.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(asset.URL)), nil
+			}
+		})
+
+		it("fetches every buildpack and names the ones that failed", func() {
+			uris, err := remoteFetcher.GetAll(buildpacks, false)
+			Expect(err).To(MatchError("failed to fetch 1 buildpack(s):\n  some-org/failing-repo: failed to get release"))
+
+			var batchErr *freezer.BatchError
+			Expect(errors.As(err, &batchErr)).To(BeTrue())
+			Expect(batchErr.Failures).To(HaveLen(1))
+			Expect(batchErr.Failures[0].Buildpack.Repo).To(Equal("failing-repo"))
+			Expect(batchErr.Failures[0].Buildpack.Offline).To(BeFalse())
+
+			var resolveErr *freezer.ResolveError
+			Expect(errors.As(batchErr.Failures[0].Err, &resolveErr)).To(BeTrue())
+
+			Expect(uris).To(Equal([]string{
+				filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz"),
+				"",
+				filepath.Join(cacheDir, "some-org", "other-repo", "some-tag.tgz"),
+			}))
+
+			content, err := os.ReadFile(uris[2])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("some-other-repo-url"))
+
+			Expect(buildpackCache.SetCall.CallCount).To(Equal(2))
+		})
+
+		it("runs no more than the configured number of fetches at once", func() {
+			var (
+				mutex   sync.Mutex
+				running int
+				most    int
+			)
+
+			gitReleaseFetcher.GetCall.Stub = nil
+			gitReleaseFetcher.GetReleaseAssetCall.Stub = nil
+			buildpackCache.GetCall.Returns.Bool = true
+			buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{Version: "some-tag", URI: cachedPath}
+
+			fetcher := freezer.NewRemoteFetcher(buildpackCache, releaseFetcherFunc(func(ctx gocontext.Context, org, repo string) (github.Release, error) {
+				mutex.Lock()
+				running++
+				if running > most {
+					most = running
+				}
+				mutex.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mutex.Lock()
+				running--
+				mutex.Unlock()
+
+				return gitReleaseFetcher.Get(ctx, org, repo)
+			}), packager, fileSystem).WithConcurrency(2)
+
+			var many []freezer.RemoteBuildpack
+			for i := 0; i < 6; i++ {
+				many = append(many, freezer.NewRemoteBuildpack("some-org", fmt.Sprintf("repo-%d", i)))
+			}
+
+			_, err := fetcher.GetAll(many, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(most).To(Equal(2))
+			Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(6))
+			Expect(buildpackCache.GetCall.Receives.Key).To(HaveSuffix(":cached"))
+		})
+	})
+
+	context("GetWithContext", func() {
+		var (
+			ctx    gocontext.Context
+			cancel gocontext.CancelFunc
+		)
+
+		it.Before(func() {
+			ctx, cancel = gocontext.WithCancel(gocontext.Background())
+			buildpackCache.GetCall.Returns.Bool = false
+		})
+
+		it.After(func() {
+			cancel()
+		})
+
+		it("fetches the buildpack like Get", func() {
+			uri, err := remoteFetcher.GetWithContext(ctx, remoteBuildpack)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+			Expect(uri).To(BeAnExistingFile())
+
+			Expect(gitReleaseFetcher.GetCall.Receives.Ctx).To(Equal(ctx))
+			Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Ctx).To(Equal(ctx))
+		})
+
+		context("when the context is already cancelled", func() {
+			it.Before(func() {
+				cancel()
+			})
+
+			it("returns the context error without resolving the release", func() {
+				_, err := remoteFetcher.GetWithContext(ctx, remoteBuildpack)
+				Expect(errors.Is(err, gocontext.Canceled)).To(BeTrue())
+
+				Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the context is cancelled during the download", func() {
+			it.Before(func() {
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+					return io.NopCloser(io.MultiReader(strings.NewReader("some-partial-content"), readerFunc(func([]byte) (int, error) {
+						cancel()
+						return 0, nil
+					}), strings.NewReader("some-more-content"))), nil
+				}
+			})
+
+			it("removes the partial artifact and returns the context error", func() {
+				_, err := remoteFetcher.GetWithContext(ctx, remoteBuildpack)
+				Expect(errors.Is(err, gocontext.Canceled)).To(BeTrue())
+
+				var downloadErr *freezer.DownloadError
+				Expect(errors.As(err, &downloadErr)).To(BeTrue())
+
+				Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")).NotTo(BeAnExistingFile())
+				Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+			})
+		})
+	})
+
+	context("Fetch", func() {
+		var clock *fakes.Clock
+
+		it.Before(func() {
+			clock = &fakes.Clock{}
+			clock.NowCall.Returns.Time = time.Date(2022, time.January, 1, 2, 0, 0, 0, time.UTC)
+			remoteFetcher = remoteFetcher.WithClock(clock).WithTTL(time.Hour)
+
+			Expect(os.MkdirAll(filepath.Join(cacheDir, "some-org", "some-repo"), os.ModePerm)).To(Succeed())
+		})
+
+		context("when the cached buildpack is still valid", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+					Version:   "some-tag",
+					URI:       cachedPath,
+					FetchedAt: time.Date(2022, time.January, 1, 1, 30, 0, 0, time.UTC),
+				}
+			})
+
+			it("reports that the cached buildpack was kept", func() {
+				result, err := remoteFetcher.Fetch(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(freezer.FetchResult{
+					URI:           cachedPath,
+					Version:       "some-tag",
+					CachedVersion: "some-tag",
+					Fetched:       false,
+					Reason:        freezer.FetchReasonCached,
+				}))
+
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the buildpack is refetched", func() {
+			var (
+				entry freezer.CacheEntry
+				exist bool
+			)
+
+			it.Before(func() {
+				buildpackCache.GetCall.Stub = func(string) (freezer.CacheEntry, bool, error) {
+					return entry, exist, nil
+				}
+			})
+
+			for _, c := range []struct {
+				name   string
+				entry  freezer.CacheEntry
+				exist  bool
+				reason freezer.FetchReason
+			}{
+				{
+					name:   "there is no cache entry",
+					reason: freezer.FetchReasonNotCached,
+				},
+				{
+					name:   "the cached file is missing",
+					entry:  freezer.CacheEntry{Version: "some-tag", URI: "some-path/some-tag.tgz"},
+					reason: freezer.FetchReasonFileMissing,
+				},
+				{
+					name:   "the version has changed",
+					entry:  freezer.CacheEntry{Version: "some-old-tag", URI: "some-path/some-old-tag.tgz"},
+					exist:  true,
+					reason: freezer.FetchReasonVersionChanged,
+				},
+				{
+					name:   "the file name does not match the version",
+					entry:  freezer.CacheEntry{Version: "some-tag", URI: "some-path/some-other-tag.tgz", FetchedAt: time.Date(2022, time.January, 1, 1, 30, 0, 0, time.UTC)},
+					exist:  true,
+					reason: freezer.FetchReasonMismatched,
+				},
+				{
+					name:   "the ttl has expired",
+					entry:  freezer.CacheEntry{Version: "some-tag", URI: "some-path/some-tag.tgz"},
+					exist:  true,
+					reason: freezer.FetchReasonExpired,
+				},
+			} {
+				c := c
+
+				context(fmt.Sprintf("because %s", c.name), func() {
+					it.Before(func() {
+						entry = c.entry
+						exist = c.exist
+					})
+
+					it(fmt.Sprintf("reports %s", c.reason), func() {
+						result, err := remoteFetcher.Fetch(remoteBuildpack)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(result).To(Equal(freezer.FetchResult{
+							URI:           filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz"),
+							Version:       "some-tag",
+							CachedVersion: c.entry.Version,
+							Fetched:       true,
+							Reason:        c.reason,
+							ResolvedURL:   "some-url",
+						}))
+
+						Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+						Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset.URL).To(Equal(result.ResolvedURL))
+						Expect(buildpackCache.SetCall.CallCount).To(Equal(1))
+					})
+				})
+			}
+		})
+
+		context("when the refetch of an expired artifact fails", func() {
+			var path string
+
+			it.Before(func() {
+				path = filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")
+				Expect(os.WriteFile(path, []byte("some-cached-content"), 0600)).To(Succeed())
+
+				buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{Version: "some-tag", URI: path}
+				buildpackCache.GetCall.Returns.Bool = true
+
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+					return io.NopCloser(io.MultiReader(strings.NewReader("some-partial-content"), readerFunc(func([]byte) (int, error) {
+						return 0, errors.New("connection reset")
+					}))), nil
+				}
+			})
+
+			it("keeps the cached artifact intact", func() {
+				_, err := remoteFetcher.Fetch(remoteBuildpack)
+				Expect(err).To(MatchError("connection reset"))
+
+				content, err := os.ReadFile(path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(Equal("some-cached-content"))
+
+				files, err := os.ReadDir(filepath.Dir(path))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(HaveLen(1))
+
+				Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+			})
+
+			context("when the download does not match its published digest", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release.Assets = []github.ReleaseAsset{
+						{Name: "some-buildpack.tgz", URL: "some-url"},
+					}
+					gitReleaseFetcher.GetCall.Returns.Release.Body = fmt.Sprintf("some-buildpack.tgz: %x", sha256.Sum256([]byte("some-other-content")))
+					gitReleaseFetcher.GetReleaseAssetCall.Stub = nil
+				})
+
+				it("keeps the cached artifact intact", func() {
+					_, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).To(MatchError(ContainSubstring("does not match its published digest")))
+
+					content, err := os.ReadFile(path)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal("some-cached-content"))
+				})
+			})
+		})
+
+		context("when a cached artifact is checked on a cache hit", func() {
+			var path string
+
+			it.Before(func() {
+				path = filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")
+				Expect(os.WriteFile(path, []byte("some-cached-content"), 0600)).To(Succeed())
+
+				buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+					Version:   "some-tag",
+					URI:       path,
+					SHA256:    fmt.Sprintf("%x", sha256.Sum256([]byte("some-cached-content"))),
+					FetchedAt: time.Date(2022, time.January, 1, 1, 30, 0, 0, time.UTC),
+				}
+				buildpackCache.GetCall.Returns.Bool = true
+			})
+
+			it("keeps an intact artifact", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uri).To(Equal(path))
+
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+				Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+			})
+
+			context("when the cached artifact was deleted", func() {
+				it.Before(func() {
+					Expect(os.Remove(path)).To(Succeed())
+				})
+
+				it("fetches the buildpack again", func() {
+					result, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Fetched).To(BeTrue())
+					Expect(result.Reason).To(Equal(freezer.FetchReasonFileMissing))
+
+					Expect(path).To(BeAnExistingFile())
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+					Expect(buildpackCache.SetCall.CallCount).To(Equal(1))
+				})
+			})
+
+			context("when the cached artifact no longer matches its digest", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte("some-altered-content"), 0600)).To(Succeed())
+				})
+
+				it("fetches the buildpack again", func() {
+					result, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Fetched).To(BeTrue())
+					Expect(result.Reason).To(Equal(freezer.FetchReasonCorrupted))
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+					Expect(buildpackCache.SetCall.CallCount).To(Equal(1))
+				})
+			})
+
+			context("when the check is turned off", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(path, []byte("some-altered-content"), 0600)).To(Succeed())
+					remoteFetcher = remoteFetcher.WithCachedArtifactCheck(false)
+				})
+
+				it("returns the cached artifact as it is", func() {
+					result, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Fetched).To(BeFalse())
+					Expect(result.URI).To(Equal(path))
+
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+				})
+			})
+		})
+
+		context("when the buildpack is packaged from source", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+				remoteBuildpack.Offline = true
+			})
+
+			it("reports the source tarball url it downloaded", func() {
+				result, err := remoteFetcher.Fetch(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.ResolvedURL).To(Equal("some-tarball-url"))
+				Expect(gitReleaseFetcher.GetReleaseTarballCall.Receives.Url).To(Equal(result.ResolvedURL))
+			})
+
+			context("when the tarball url falls back to the tarball endpoint", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Release.TarballURL = ""
+					remoteFetcher = remoteFetcher.WithTarballFallback("https://api.example.com")
+				})
+
+				it("reports the fallback url", func() {
+					result, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.ResolvedURL).To(Equal("https://api.example.com/repos/some-org/some-repo/tarball/some-tag"))
+					Expect(gitReleaseFetcher.GetReleaseTarballCall.Receives.Url).To(Equal(result.ResolvedURL))
+				})
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the release cannot be fetched", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Returns.Error = errors.New("unable to get release")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.Fetch(remoteBuildpack)
+					Expect(err).To(MatchError("unable to get release"))
+				})
+			})
+		})
+	})
+
+	context("failure stages", func() {
+		it.Before(func() {
+			buildpackCache.GetCall.Returns.Bool = false
+		})
+
+		it("returns a ResolveError when the release cannot be resolved", func() {
+			gitReleaseFetcher.GetCall.Returns.Error = errors.New("failed to get release")
+
+			_, err := remoteFetcher.Fetch(remoteBuildpack)
+			Expect(err).To(MatchError("failed to get release"))
+
+			var stageErr *freezer.ResolveError
+			Expect(errors.As(err, &stageErr)).To(BeTrue())
+			Expect(stageErr.Buildpack).To(Equal(remoteBuildpack))
+			Expect(stageErr.Stage()).To(Equal(freezer.StageResolve))
+		})
+
+		it("passes a rate limit error through unchanged", func() {
+			rateLimitErr := &github.RateLimitError{Org: "some-org", Repo: "some-repo", Reset: time.Unix(1700000000, 0)}
+			gitReleaseFetcher.GetCall.Returns.Error = rateLimitErr
+
+			_, err := remoteFetcher.Get(remoteBuildpack)
+			Expect(err).To(MatchError(rateLimitErr.Error()))
+
+			var target *github.RateLimitError
+			Expect(errors.As(err, &target)).To(BeTrue())
+			Expect(target).To(BeIdenticalTo(rateLimitErr))
+		})
+
+		it("returns a DownloadError when the asset cannot be downloaded", func() {
+			gitReleaseFetcher.GetReleaseAssetCall.Returns.Error = errors.New("failed to download")
+
+			_, err := remoteFetcher.Fetch(remoteBuildpack)
+			Expect(err).To(MatchError("failed to download"))
+
+			var stageErr *freezer.DownloadError
+			Expect(errors.As(err, &stageErr)).To(BeTrue())
+			Expect(stageErr.Stage()).To(Equal(freezer.StageDownload))
+		})
+
+		it("returns an ExtractError when the source tarball cannot be extracted", func() {
+			remoteBuildpack.Offline = true
+			gitReleaseFetcher.GetReleaseTarballCall.Returns.ReadCloser = io.NopCloser(bytes.NewReader([]byte{0x00, 0x01, 0x02}))
+
+			_, err := remoteFetcher.Fetch(remoteBuildpack)
+			Expect(err).To(MatchError(ContainSubstring("unsupported archive type")))
+
+			var stageErr *freezer.ExtractError
+			Expect(errors.As(err, &stageErr)).To(BeTrue())
+			Expect(stageErr.Stage()).To(Equal(freezer.StageExtract))
+		})
+
+		it("returns a PackageError when packaging fails", func() {
+			remoteBuildpack.Offline = true
+			packager.ExecuteCall.Stub = nil
+			packager.ExecuteCall.Returns.Error = errors.New("failed to package")
+
+			_, err := remoteFetcher.Fetch(remoteBuildpack)
+			Expect(err).To(MatchError("failed to package"))
+
+			var stageErr *freezer.PackageError
+			Expect(errors.As(err, &stageErr)).To(BeTrue())
+			Expect(stageErr.Stage()).To(Equal(freezer.StagePackage))
+		})
+
+		it("returns a CacheError when the entry cannot be saved", func() {
+			buildpackCache.SetCall.Returns.Error = errors.New("failed to set")
+
+			_, err := remoteFetcher.Fetch(remoteBuildpack)
+			Expect(err).To(MatchError("failed to set"))
+
+			var stageErr *freezer.CacheError
+			Expect(errors.As(err, &stageErr)).To(BeTrue())
+			Expect(stageErr.Stage()).To(Equal(freezer.StageCache))
+		})
+	})
+
+	context("VerifyCached", func() {
+		var artifact string
+
+		it.Before(func() {
+			buildpackCache.GetCall.Returns.Bool = false
+			gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-artifact"))
+
+			var err error
+			artifact, err = remoteFetcher.Get(remoteBuildpack)
+			Expect(err).NotTo(HaveOccurred())
+
+			buildpackCache.GetCall.Returns.CacheEntry = buildpackCache.SetCall.Receives.CachedEntry
+			buildpackCache.GetCall.Returns.Bool = true
+		})
+
+		it("succeeds when the artifact matches its recorded digest", func() {
+			Expect(buildpackCache.GetCall.Returns.CacheEntry.SHA256).To(Equal("c704db7cd00fee1032391ccef39a80d2236db81a5361e80a5ecdf0c58633dbc4"))
+			Expect(remoteFetcher.VerifyCached(remoteBuildpack, false)).To(Succeed())
+		})
+
+		it("looks up the requested variant", func() {
+			Expect(remoteFetcher.VerifyCached(remoteBuildpack, true)).To(Succeed())
+			Expect(buildpackCache.GetCall.Receives.Key).To(Equal("some-org:some-repo:cached"))
+		})
+
+		context("failure cases", func() {
+			context("when the artifact has been corrupted", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(artifact, []byte("some-corrupted-artifact"), 0644)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					err := remoteFetcher.VerifyCached(remoteBuildpack, false)
+					Expect(err).To(MatchError(ContainSubstring("cached artifact for some-org:some-repo does not match its recorded digest")))
+				})
+			})
+
+			context("when the artifact is missing", func() {
+				it.Before(func() {
+					buildpackCache.GetCall.Returns.Bool = false
+				})
+
+				it("returns an error", func() {
+					err := remoteFetcher.VerifyCached(remoteBuildpack, false)
+					Expect(err).To(MatchError(fmt.Sprintf("cached artifact for some-org:some-repo is missing: %s", artifact)))
+				})
+			})
+
+			context("when there is no cache entry", func() {
+				it.Before(func() {
+					buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{}
+					buildpackCache.GetCall.Returns.Bool = false
+				})
+
+				it("returns an error", func() {
+					err := remoteFetcher.VerifyCached(remoteBuildpack, false)
+					Expect(err).To(MatchError("no cache entry for some-org:some-repo"))
+				})
+			})
+
+			context("when the entry has no recorded digest", func() {
+				it.Before(func() {
+					buildpackCache.GetCall.Returns.CacheEntry.SHA256 = ""
+				})
+
+				it("returns an error", func() {
+					err := remoteFetcher.VerifyCached(remoteBuildpack, false)
+					Expect(err).To(MatchError("cache entry for some-org:some-repo has no recorded digest"))
+				})
+			})
+
+			context("when the cache lookup fails", func() {
+				it.Before(func() {
+					buildpackCache.GetCall.Returns.Error = errors.New("failed get")
+				})
+
+				it("returns an error", func() {
+					err := remoteFetcher.VerifyCached(remoteBuildpack, false)
+					Expect(err).To(MatchError("failed get"))
+				})
+			})
+		})
+	})
+
+	context("IsStale", func() {
+		it.Before(func() {
+			buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+				Version: "some-tag",
+				URI:     cachedPath,
+			}
+		})
+
+		it("returns false when the cached entry is in sync", func() {
+			stale, err := remoteFetcher.IsStale(remoteBuildpack, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stale).To(BeFalse())
+
+			Expect(buildpackCache.GetCall.Receives.Key).To(Equal("some-org:some-repo:cached"))
+		})
+
+		it("returns true when a newer release exists without fetching it", func() {
+			gitReleaseFetcher.GetCall.Returns.Release.TagName = "some-newer-tag"
+
+			stale, err := remoteFetcher.IsStale(remoteBuildpack, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stale).To(BeTrue())
+
+			Expect(buildpackCache.GetCall.Receives.Key).To(Equal("some-org:some-repo"))
+			Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+			Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(0))
+			Expect(packager.ExecuteCall.CallCount).To(Equal(0))
+			Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+		})
+
+		context("when the release cannot be resolved", func() {
+			it.Before(func() {
+				gitReleaseFetcher.GetCall.Returns.Error = errors.New("failed to get release")
+			})
+
+			it("returns an error", func() {
+				_, err := remoteFetcher.IsStale(remoteBuildpack, false)
+				Expect(err).To(MatchError("failed to get release"))
+			})
+		})
+	})
+
+	context("AvailableVersions", func() {
+		it.Before(func() {
+			gitReleaseFetcher.ListReleasesCall.Returns.ReleaseSlice = []github.Release{
+				{TagName: "v1.2.0"},
+				{TagName: "v1.10.0"},
+				{TagName: "v2.0.0-rc.1", Prerelease: true},
+				{TagName: "v3.0.0", Draft: true},
+				{TagName: "v1.9.1"},
+			}
+		})
+
+		it("lists the release tags newest first without drafts", func() {
+			versions, err := remoteFetcher.AvailableVersions(remoteBuildpack)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(Equal([]string{"v2.0.0-rc.1", "v1.10.0", "v1.9.1", "v1.2.0"}))
+
+			Expect(gitReleaseFetcher.ListReleasesCall.Receives.Org).To(Equal("some-org"))
+			Expect(gitReleaseFetcher.ListReleasesCall.Receives.Repo).To(Equal("some-repo"))
+			Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+			Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(0))
+		})
+
+		context("when pre-releases are rejected", func() {
+			it.Before(func() {
+				remoteFetcher = remoteFetcher.WithRejectPrerelease(true)
+			})
+
+			it("lists only stable releases", func() {
+				versions, err := remoteFetcher.AvailableVersions(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(versions).To(Equal([]string{"v1.10.0", "v1.9.1", "v1.2.0"}))
+			})
+		})
+
+		context("when listing releases fails", func() {
+			it.Before(func() {
+				gitReleaseFetcher.ListReleasesCall.Returns.Error = errors.New("failed to list releases")
+			})
+
+			it("returns an error", func() {
+				_, err := remoteFetcher.AvailableVersions(remoteBuildpack)
+				Expect(err).To(MatchError("failed to list releases"))
+			})
+		})
+	})
+
+	context("GetAsset", func() {
+		it.Before(func() {
+			buildpackCache.GetCall.Returns.Bool = false
+			gitReleaseFetcher.GetCall.Returns.Release = github.Release{
+				TagName: "some-tag",
+				Assets: []github.ReleaseAsset{
+					{URL: "some-url", Name: "some-buildpack.tgz"},
+					{URL: "some-cached-url", Name: "some-buildpack-cached.tgz"},
+				},
+			}
+			gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-cached-artifact"))
+		})
+
+		it("downloads the named asset and caches it under its own key", func() {
+			uri, err := remoteFetcher.GetAsset(remoteBuildpack, "some-buildpack-cached.tgz")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "assets", "some-buildpack-cached.tgz")))
+
+			content, err := os.ReadFile(uri)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("some-cached-artifact"))
+
+			Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset).To(Equal(github.ReleaseAsset{URL: "some-cached-url", Name: "some-buildpack-cached.tgz"}))
+			Expect(packager.ExecuteCall.CallCount).To(Equal(0))
+
+			Expect(buildpackCache.GetCall.Receives.Key).To(Equal("some-org:some-repo:asset:some-buildpack-cached.tgz"))
+			Expect(buildpackCache.SetCall.Receives.Key).To(Equal("some-org:some-repo:asset:some-buildpack-cached.tgz"))
+			Expect(buildpackCache.SetCall.Receives.CachedEntry.Version).To(Equal("some-tag"))
+			Expect(buildpackCache.SetCall.Receives.CachedEntry.URI).To(Equal(uri))
+		})
+
+		context("when the asset is already cached at the release version", func() {
+			var path string
+
+			it.Before(func() {
+				path = filepath.Join(cacheDir, "some-org", "some-repo", "assets", "some-buildpack-cached.tgz")
+				Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(path, []byte("some-previous-artifact"), 0600)).To(Succeed())
+
+				buildpackCache.GetCall.Returns.Bool = true
+				buildpackCache.GetCall.Returns.CacheEntry = freezer.CacheEntry{
+					Version: "some-tag",
+					URI:     path,
+				}
+			})
+
+			it("returns the cached artifact", func() {
+				uri, err := remoteFetcher.GetAsset(remoteBuildpack, "some-buildpack-cached.tgz")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uri).To(Equal(path))
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+			})
+
+			context("when the cached file is missing", func() {
+				it.Before(func() {
+					Expect(os.Remove(path)).To(Succeed())
+				})
+
+				it("downloads the asset again", func() {
+					uri, err := remoteFetcher.GetAsset(remoteBuildpack, "some-buildpack-cached.tgz")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(uri).To(Equal(path))
+
+					content, err := os.ReadFile(uri)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal("some-cached-artifact"))
+					Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+				})
+			})
+
+			context("when the cached entry has expired and the download fails", func() {
+				it.Before(func() {
+					clock := &fakes.Clock{}
+					clock.NowCall.Returns.Time = time.Date(2022, time.January, 1, 2, 0, 0, 0, time.UTC)
+					remoteFetcher = remoteFetcher.WithClock(clock).WithTTL(time.Hour)
+
+					gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(io.MultiReader(strings.NewReader("some-partial"), readerFunc(func([]byte) (int, error) {
+						return 0, errors.New("connection reset")
+					})))
+				})
+
+				it("keeps the cached file", func() {
+					_, err := remoteFetcher.GetAsset(remoteBuildpack, "some-buildpack-cached.tgz")
+					Expect(err).To(MatchError("connection reset"))
+
+					content, err := os.ReadFile(path)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(content)).To(Equal("some-previous-artifact"))
+
+					files, err := os.ReadDir(filepath.Dir(path))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(files).To(HaveLen(1))
+				})
+			})
+		})
+
+		context("when the release has no asset with the name", func() {
+			it("returns an error", func() {
+				_, err := remoteFetcher.GetAsset(remoteBuildpack, "missing.tgz")
+				Expect(err).To(MatchError("release some-tag of some-org/some-repo has no asset named missing.tgz"))
+			})
+		})
+	})
+
+	context("Stream", func() {
+		it.Before(func() {
+			gitReleaseFetcher.GetReleaseAssetCall.Returns.ReadCloser = io.NopCloser(strings.NewReader("some-artifact"))
+		})
+
+		it("streams the release asset without touching the cache", func() {
+			stream, err := remoteFetcher.Stream(remoteBuildpack, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := io.ReadAll(stream)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stream.Close()).To(Succeed())
+			Expect(string(content)).To(Equal("some-artifact"))
+
+			Expect(gitReleaseFetcher.GetReleaseAssetCall.Receives.Asset).To(Equal(github.ReleaseAsset{URL: "some-url"}))
+			Expect(buildpackCache.GetCall.CallCount).To(Equal(0))
+			Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+
+			files, err := os.ReadDir(cacheDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(BeEmpty())
+		})
+
+		context("when the cached variant would have to be packaged", func() {
+			it("returns an error", func() {
+				_, err := remoteFetcher.Stream(remoteBuildpack, true)
+				Expect(err).To(MatchError("release some-tag of some-org/some-repo has no asset to stream, it would have to be packaged from source"))
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when the asset is not in an accepted format", func() {
+			it.Before(func() {
+				remoteFetcher = remoteFetcher.WithAcceptedFormats(freezer.ArchiveFormatGzip)
+			})
+
+			it("returns an error", func() {
+				_, err := remoteFetcher.Stream(remoteBuildpack, false)
+				Expect(err).To(MatchError("downloaded content is not a recognized archive"))
+			})
+		})
+	})
+
+	context("Metadata", func() {
+		var artifact []byte
+
+		it.Before(func() {
+			buffer := bytes.NewBuffer(nil)
+			gw := gzip.NewWriter(buffer)
+			tw := tar.NewWriter(gw)
+
+			content := []byte(`[buildpack]
+id = "some-org/some-buildpack"
+`)
+			Expect(tw.WriteHeader(&tar.Header{Name: "buildpack.toml", Mode: 0644, Size: int64(len(content))})).To(Succeed())
+			_, err := tw.Write(content)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(tw.Close()).To(Succeed())
+			Expect(gw.Close()).To(Succeed())
+			artifact = buffer.Bytes()
+
+			gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(artifact)), nil
+			}
+		})
+
+		it("streams the buildpack.toml from the release", func() {
+			content, err := remoteFetcher.Metadata(remoteBuildpack)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`id = "some-org/some-buildpack"`))
+
+			Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+			Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.buildpack.toml")).NotTo(BeAnExistingFile())
+		})
+
+		context("when metadata is cached", func() {
+			it.Before(func() {
+				buildpackCache.GetCall.Returns.Bool = false
+				remoteFetcher = remoteFetcher.WithMetadataCache(true)
+			})
+
+			it("saves the buildpack.toml next to the fetched artifact and reads it from there", func() {
+				uri, err := remoteFetcher.Get(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(uri).To(Equal(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.tgz")))
+
+				metadataPath := filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.buildpack.toml")
+				Expect(metadataPath).To(BeAnExistingFile())
+
+				Expect(os.Remove(uri)).To(Succeed())
+
+				content, err := remoteFetcher.Metadata(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`id = "some-org/some-buildpack"`))
+
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+			})
+
+			it("caches metadata it had to stream", func() {
+				_, err := remoteFetcher.Metadata(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = remoteFetcher.Metadata(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(1))
+				Expect(filepath.Join(cacheDir, "some-org", "some-repo", "some-tag.buildpack.toml")).To(BeAnExistingFile())
+			})
+		})
+
+		context("when the release has no buildpack.toml", func() {
+			it.Before(func() {
+				gitReleaseFetcher.GetReleaseAssetCall.Stub = nil
+			})
+
+			it("returns an error", func() {
+				_, err := remoteFetcher.Metadata(remoteBuildpack)
+				Expect(err).To(MatchError("failed to read buildpack.toml of some-org/some-repo some-tag: archive does not contain a buildpack.toml"))
+			})
+		})
+	})
+
+	context("CheckUpdates", func() {
+		var inSyncPath, expiredPath string
+
+		it.Before(func() {
+			inSyncPath = filepath.Join(tmpDir, "in-sync-repo", "in-sync-repo-latest.tgz")
+			expiredPath = filepath.Join(tmpDir, "expired-repo", "expired-repo-latest.tgz")
+			for _, path := range []string{inSyncPath, expiredPath} {
+				Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(path, []byte("some-artifact"), 0644)).To(Succeed())
+			}
+
+			gitReleaseFetcher.GetCall.Stub = func(_ gocontext.Context, org, repo string) (github.Release, error) {
+				return github.Release{TagName: fmt.Sprintf("%s-latest", repo)}, nil
+			}
+
+			buildpackCache.GetCall.Stub = func(key string) (freezer.CacheEntry, bool, error) {
+				switch key {
+				case "some-org:in-sync-repo":
+					return freezer.CacheEntry{Version: "in-sync-repo-latest", URI: inSyncPath, FetchedAt: time.Now()}, true, nil
+				case "some-org:expired-repo":
+					return freezer.CacheEntry{Version: "expired-repo-latest", URI: expiredPath, FetchedAt: time.Now().Add(-48 * time.Hour)}, true, nil
+				case "some-org:out-of-sync-repo":
+					return freezer.CacheEntry{Version: "some-old-tag", URI: "some-uri"}, true, nil
+				default:
+					return freezer.CacheEntry{}, false, nil
+				}
+			}
+
+			remoteFetcher = remoteFetcher.WithTTL(24 * time.Hour)
+		})
+
+		it("reports the state of each buildpack without downloading anything", func() {
+			inSync := freezer.NewRemoteBuildpack("some-org", "in-sync-repo")
+			outOfSync := freezer.NewRemoteBuildpack("some-org", "out-of-sync-repo")
+			missing := freezer.NewRemoteBuildpack("some-org", "missing-repo")
+			expired := freezer.NewRemoteBuildpack("some-org", "expired-repo")
+
+			statuses, err := remoteFetcher.CheckUpdates([]freezer.RemoteBuildpack{inSync, outOfSync, missing, expired})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statuses).To(Equal([]freezer.UpdateStatus{
+				{
+					Buildpack:     inSync,
+					CachedVersion: "in-sync-repo-latest",
+					LatestVersion: "in-sync-repo-latest",
+					State:         freezer.UpToDate,
+					Reason:        freezer.FetchReasonCached,
+				},
+				{
+					Buildpack:     outOfSync,
+					CachedVersion: "some-old-tag",
+					LatestVersion: "out-of-sync-repo-latest",
+					State:         freezer.Outdated,
+					Reason:        freezer.FetchReasonVersionChanged,
+				},
+				{
+					Buildpack:     missing,
+					LatestVersion: "missing-repo-latest",
+					State:         freezer.Missing,
+					Reason:        freezer.FetchReasonNotCached,
+				},
+				{
+					Buildpack:     expired,
+					CachedVersion: "expired-repo-latest",
+					LatestVersion: "expired-repo-latest",
+					State:         freezer.Outdated,
+					Reason:        freezer.FetchReasonExpired,
+				},
+			}))
+
+			for _, status := range statuses {
+				stale, err := remoteFetcher.IsStale(status.Buildpack, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(stale).To(Equal(status.State != freezer.UpToDate))
+			}
+
+			Expect(gitReleaseFetcher.GetReleaseAssetCall.CallCount).To(Equal(0))
+			Expect(gitReleaseFetcher.GetReleaseTarballCall.CallCount).To(Equal(0))
+			Expect(buildpackCache.SetCall.CallCount).To(Equal(0))
+		})
+
+		context("failure cases", func() {
+			context("when the release cannot be fetched", func() {
+				it.Before(func() {
+					gitReleaseFetcher.GetCall.Stub = nil
+					gitReleaseFetcher.GetCall.Returns.Error = errors.New("unable to get release")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.CheckUpdates([]freezer.RemoteBuildpack{remoteBuildpack})
+					Expect(err).To(MatchError("unable to get release"))
+				})
+			})
+
+			context("when the cache lookup fails", func() {
+				it.Before(func() {
+					buildpackCache.GetCall.Stub = nil
+					buildpackCache.GetCall.Returns.Error = errors.New("failed get")
+				})
+
+				it("returns an error", func() {
+					_, err := remoteFetcher.CheckUpdates([]freezer.RemoteBuildpack{remoteBuildpack})
+					Expect(err).To(MatchError("failed get"))
+				})
+			})
+		})
+	})
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+// releaseFetcherFunc is a GitReleaseFetcher that only resolves the latest
+// release.
+type releaseFetcherFunc func(ctx gocontext.Context, org, repo string) (github.Release, error)
+
+func (f releaseFetcherFunc) Get(ctx gocontext.Context, org, repo string) (github.Release, error) {
+	return f(ctx, org, repo)
+}
+
+func (f releaseFetcherFunc) GetByID(ctx gocontext.Context, org, repo string, id int64) (github.Release, error) {
+	return github.Release{}, errors.New("not implemented")
+}
+
+func (f releaseFetcherFunc) ListReleases(ctx gocontext.Context, org, repo string) ([]github.Release, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f releaseFetcherFunc) GetReleaseAsset(ctx gocontext.Context, asset github.ReleaseAsset) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f releaseFetcherFunc) GetReleaseTarball(ctx gocontext.Context, url string) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+// tagListingFetcher adds a GitTagLister to a fake release fetcher.
+type tagListingFetcher struct {
+	*fakes.GitReleaseFetcher
+	tags []github.Release
+}
+
+func (f tagListingFetcher) ListTags(ctx gocontext.Context, org, repo string) ([]github.Release, error) {
+	return f.tags, nil
 }
@@ -0,0 +1,61 @@
+package freezer
+
+import (
+	"fmt"
+
+	"github.com/ForestEckhardt/freezer/github"
+)
+
+// SnapshotFetcher is a RemoteFetcher whose buildpacks are locked to the
+// releases that were latest when the snapshot was taken, so that a release
+// published partway through a run does not change what the run fetches.
+type SnapshotFetcher struct {
+	fetcher RemoteFetcher
+}
+
+// Snapshot resolves the release of every buildpack once and returns a
+// fetcher that only ever fetches those releases. Buildpacks that were not
+// part of the snapshot cannot be fetched through it.
+func (r RemoteFetcher) Snapshot(buildpacks []RemoteBuildpack) (SnapshotFetcher, error) {
+	r.pinned = nil
+
+	pinned := map[string]github.Release{}
+	for _, buildpack := range buildpacks {
+		key := pinnedKey(buildpack)
+		if _, ok := pinned[key]; ok {
+			continue
+		}
+
+		release, err := r.forHost(buildpack).release(buildpack)
+		if err != nil {
+			return SnapshotFetcher{}, err
+		}
+
+		pinned[key] = release
+	}
+
+	r.pinned = pinned
+
+	return SnapshotFetcher{fetcher: r}, nil
+}
+
+func (s SnapshotFetcher) Get(buildpack RemoteBuildpack) (string, error) {
+	return s.fetcher.Get(buildpack)
+}
+
+func (s SnapshotFetcher) Fetch(buildpack RemoteBuildpack) (FetchResult, error) {
+	return s.fetcher.Fetch(buildpack)
+}
+
+// Version returns the tag the buildpack was locked to.
+func (s SnapshotFetcher) Version(buildpack RemoteBuildpack) (string, bool) {
+	release, ok := s.fetcher.pinned[pinnedKey(buildpack)]
+	return release.TagName, ok
+}
+
+// pinnedKey ignores Offline so that both variants of a buildpack share
+// their snapshot, but keeps the host and constraint so that buildpacks that
+// resolve to different releases of the same repo do not.
+func pinnedKey(buildpack RemoteBuildpack) string {
+	return fmt.Sprintf("%s/%s/%s/%d/%s", buildpack.Host, buildpack.Org, buildpack.Repo, buildpack.ReleaseID, buildpack.Constraint)
+}
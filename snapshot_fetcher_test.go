@@ -0,0 +1,190 @@
+package freezer_test
+
+import (
+	gocontext "context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/ForestEckhardt/freezer/fakes"
+	"github.com/ForestEckhardt/freezer/github"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testSnapshotFetcher(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		cacheDir string
+
+		gitReleaseFetcher *fakes.GitReleaseFetcher
+		buildpackCache    *fakes.BuildpackCache
+		remoteBuildpack   freezer.RemoteBuildpack
+		remoteFetcher     freezer.RemoteFetcher
+	)
+
+	it.Before(func() {
+		var err error
+
+		cacheDir, err = os.MkdirTemp("", "cache")
+		Expect(err).NotTo(HaveOccurred())
+
+		gitReleaseFetcher = &fakes.GitReleaseFetcher{}
+		gitReleaseFetcher.GetCall.Returns.Release = github.Release{
+			TagName: "some-tag",
+			Assets: []github.ReleaseAsset{
+				{
+					URL: "some-url",
+				},
+			},
+		}
+		gitReleaseFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("some-artifact")), nil
+		}
+
+		buildpackCache = &fakes.BuildpackCache{}
+		buildpackCache.DirCall.Stub = func() string {
+			return cacheDir
+		}
+
+		remoteBuildpack = freezer.NewRemoteBuildpack("some-org", "some-repo")
+
+		remoteFetcher = freezer.NewRemoteFetcher(buildpackCache, gitReleaseFetcher, &fakes.Packager{}, freezer.NewFileSystem(os.MkdirTemp))
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(cacheDir)).To(Succeed())
+	})
+
+	context("Snapshot", func() {
+		it("keeps fetching the snapshot version after upstream moves", func() {
+			snapshot, err := remoteFetcher.Snapshot([]freezer.RemoteBuildpack{remoteBuildpack})
+			Expect(err).NotTo(HaveOccurred())
+
+			version, ok := snapshot.Version(remoteBuildpack)
+			Expect(ok).To(BeTrue())
+			Expect(version).To(Equal("some-tag"))
+
+			result, err := snapshot.Fetch(remoteBuildpack)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Version).To(Equal("some-tag"))
+
+			gitReleaseFetcher.GetCall.Returns.Release.TagName = "some-newer-tag"
+
+			result, err = snapshot.Fetch(remoteBuildpack)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Version).To(Equal("some-tag"))
+			Expect(buildpackCache.SetCall.Receives.CachedEntry.Version).To(Equal("some-tag"))
+
+			Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(1))
+		})
+
+		it("does not change the fetcher it was taken from", func() {
+			_, err := remoteFetcher.Snapshot([]freezer.RemoteBuildpack{remoteBuildpack})
+			Expect(err).NotTo(HaveOccurred())
+
+			gitReleaseFetcher.GetCall.Returns.Release.TagName = "some-newer-tag"
+
+			result, err := remoteFetcher.Fetch(remoteBuildpack)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Version).To(Equal("some-newer-tag"))
+		})
+
+		context("when a release fetcher is registered for the host of the buildpack", func() {
+			var hostFetcher *fakes.GitReleaseFetcher
+
+			it.Before(func() {
+				var err error
+				remoteBuildpack, err = freezer.ParseRemoteBuildpack("https://gitlab.example.com/some-org/some-repo")
+				Expect(err).NotTo(HaveOccurred())
+
+				hostFetcher = &fakes.GitReleaseFetcher{}
+				hostFetcher.GetCall.Returns.Release = github.Release{
+					TagName: "some-gitlab-tag",
+					Assets:  []github.ReleaseAsset{{URL: "some-gitlab-url"}},
+				}
+				hostFetcher.GetReleaseAssetCall.Stub = func(gocontext.Context, github.ReleaseAsset) (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader("some-gitlab-artifact")), nil
+				}
+
+				remoteFetcher = remoteFetcher.WithHostFetcher("gitlab.example.com", hostFetcher)
+			})
+
+			it("resolves the snapshot through that fetcher", func() {
+				snapshot, err := remoteFetcher.Snapshot([]freezer.RemoteBuildpack{remoteBuildpack})
+				Expect(err).NotTo(HaveOccurred())
+
+				version, ok := snapshot.Version(remoteBuildpack)
+				Expect(ok).To(BeTrue())
+				Expect(version).To(Equal("some-gitlab-tag"))
+
+				result, err := snapshot.Fetch(remoteBuildpack)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Version).To(Equal("some-gitlab-tag"))
+
+				Expect(hostFetcher.GetCall.CallCount).To(Equal(1))
+				Expect(hostFetcher.GetReleaseAssetCall.Receives.Asset.URL).To(Equal("some-gitlab-url"))
+				Expect(gitReleaseFetcher.GetCall.CallCount).To(Equal(0))
+			})
+		})
+
+		context("when constraints on the same repo resolve to different releases", func() {
+			it.Before(func() {
+				gitReleaseFetcher.ListReleasesCall.Returns.ReleaseSlice = []github.Release{
+					{TagName: "v2.0.1", Assets: []github.ReleaseAsset{{URL: "some-v2-url"}}},
+					{TagName: "v1.2.3", Assets: []github.ReleaseAsset{{URL: "some-v1-url"}}},
+				}
+			})
+
+			it("keeps a snapshot for each constraint", func() {
+				v1 := freezer.NewRemoteBuildpackWithConstraint("some-org", "some-repo", "~1.2")
+				v2 := freezer.NewRemoteBuildpackWithConstraint("some-org", "some-repo", "~2.0")
+
+				snapshot, err := remoteFetcher.Snapshot([]freezer.RemoteBuildpack{v1, v2})
+				Expect(err).NotTo(HaveOccurred())
+
+				version, ok := snapshot.Version(v1)
+				Expect(ok).To(BeTrue())
+				Expect(version).To(Equal("v1.2.3"))
+
+				version, ok = snapshot.Version(v2)
+				Expect(ok).To(BeTrue())
+				Expect(version).To(Equal("v2.0.1"))
+
+				result, err := snapshot.Fetch(v1)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Version).To(Equal("v1.2.3"))
+
+				result, err = snapshot.Fetch(v2)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Version).To(Equal("v2.0.1"))
+			})
+		})
+
+		context("when a buildpack is not part of the snapshot", func() {
+			it("returns an error", func() {
+				snapshot, err := remoteFetcher.Snapshot([]freezer.RemoteBuildpack{remoteBuildpack})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = snapshot.Get(freezer.NewRemoteBuildpack("some-org", "other-repo"))
+				Expect(err).To(MatchError("some-org/other-repo is not part of the snapshot"))
+			})
+		})
+
+		context("when resolving a release fails", func() {
+			it.Before(func() {
+				gitReleaseFetcher.GetCall.Returns.Error = errors.New("failed to get release")
+			})
+
+			it("returns an error", func() {
+				_, err := remoteFetcher.Snapshot([]freezer.RemoteBuildpack{remoteBuildpack})
+				Expect(err).To(MatchError("failed to get release"))
+			})
+		})
+	})
+}
@@ -0,0 +1,36 @@
+package freezer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// CompareVersions orders two release versions by semver precedence. A
+// leading "v" is accepted, pre-releases sort before their release and build
+// metadata is ignored. Versions that are not semver sort before all valid
+// versions and are ordered lexically among themselves. It returns -1, 0 or
+// 1 like strings.Compare.
+func CompareVersions(a, b string) int {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+
+	return va.Compare(vb)
+}
+
+// SortVersions sorts versions in ascending order of CompareVersions.
+func SortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return CompareVersions(versions[i], versions[j]) < 0
+	})
+}
@@ -0,0 +1,50 @@
+package freezer_test
+
+import (
+	"testing"
+
+	"github.com/ForestEckhardt/freezer"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testVersion(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("CompareVersions", func() {
+		it("orders versions by semver precedence", func() {
+			for _, c := range []struct {
+				a, b     string
+				expected int
+			}{
+				{"1.0.0", "1.0.0", 0},
+				{"v1.0.0", "1.0.0", 0},
+				{"1.0.0+build.1", "1.0.0+build.2", 0},
+				{"1.0.0-rc.1", "1.0.0", -1},
+				{"1.0.0", "1.0.0-rc.1", 1},
+				{"1.9.0", "1.10.0", -1},
+				{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+				{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+				{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+				{"1.0.0-beta", "1.0.0-beta.2", -1},
+				{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+				{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+				{"some-tag", "1.0.0", -1},
+				{"1.0.0", "some-tag", 1},
+				{"other-tag", "some-tag", -1},
+			} {
+				Expect(freezer.CompareVersions(c.a, c.b)).To(Equal(c.expected), "comparing %s to %s", c.a, c.b)
+			}
+		})
+	})
+
+	context("SortVersions", func() {
+		it("sorts versions in ascending precedence", func() {
+			versions := []string{"v1.10.0", "1.0.0", "1.0.0-rc.1", "some-tag", "1.2.0+build.5", "1.0.0-beta.11", "1.0.0-beta.2"}
+			freezer.SortVersions(versions)
+
+			Expect(versions).To(Equal([]string{"some-tag", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.2.0+build.5", "v1.10.0"}))
+		})
+	})
+}